@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"splendor-go/engine"
+	"time"
+)
+
+const (
+	LevelEasy   = "easy"
+	LevelMedium = "medium"
+	LevelHard   = "hard"
+	LevelMCTS   = "mcts"
+)
+
+var (
+	Levels = []string{LevelEasy, LevelMedium, LevelHard, LevelMCTS}
+)
+
+// Config 机器人的难度和思考预算，预算只对 mcts 有效，为 0 时使用默认值
+// Seed 不为 0 时机器人的随机选择可以重现，mcts 还需不限制思考时间
+type Config struct {
+	Level    string        `json:"level"`
+	Playouts int           `json:"playouts,omitempty"`
+	Budget   time.Duration `json:"budget,omitempty"`
+	Seed     int64         `json:"-"`
+}
+
+// UnmarshalJSON 兼容只保存难度字符串的旧格式
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var level string
+	if err := json.Unmarshal(data, &level); err == nil {
+		*c = Config{Level: level}
+		return nil
+	}
+	type config Config
+	return json.Unmarshal(data, (*config)(c))
+}
+
+// Bot 为轮到的玩家选择一个完整回合
+// 机器人只使用该玩家能看到的信息：桌面、宝石、贵族、所有玩家公开的状态和自己预定的卡牌，不读取牌堆和其他玩家暗中预定的卡牌
+type Bot interface {
+	ChooseMove(g *engine.Game, pid int) engine.Move
+}
+
+// New 按配置创建机器人
+func New(cfg Config) (Bot, error) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	switch cfg.Level {
+	case LevelEasy:
+		return &RandomBot{rng: rng}, nil
+	case LevelMedium:
+		return &GreedyBot{rng: rng}, nil
+	case LevelHard:
+		return &LookaheadBot{rng: rng}, nil
+	case LevelMCTS:
+		b := &MCTSBot{Playouts: cfg.Playouts, Budget: cfg.Budget, Depth: DefaultDepth, rng: rng}
+		if b.Playouts <= 0 {
+			b.Playouts = DefaultPlayouts
+		}
+		if b.Budget <= 0 {
+			b.Budget = DefaultBudget
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown bot level: %s", cfg.Level)
+}
+
+// RandomBot 随机选择合法的回合，尽量不浪费回合
+type RandomBot struct {
+	rng *rand.Rand
+}
+
+// ChooseMove 随机选择，能买卡时优先买卡
+func (b *RandomBot) ChooseMove(g *engine.Game, pid int) engine.Move {
+	moves := g.LegalMoves(pid)
+	if len(moves) == 0 {
+		return engine.Move{Type: engine.MovePass}
+	}
+	buys := make([]engine.Move, 0)
+	for _, m := range moves {
+		if m.Type == engine.MoveBuy {
+			buys = append(buys, m)
+		}
+	}
+	if len(buys) > 0 && b.rng.Intn(2) == 0 {
+		return buys[b.rng.Intn(len(buys))]
+	}
+	return moves[b.rng.Intn(len(moves))]
+}
+
+// GreedyBot 选择执行后局面评分最高的回合
+type GreedyBot struct {
+	rng *rand.Rand
+}
+
+// ChooseMove 只看一步，评分相同时随机选择
+func (b *GreedyBot) ChooseMove(g *engine.Game, pid int) engine.Move {
+	return best(b.rng, g, pid, func(pos *position) float64 {
+		return pos.evaluate(g)
+	})
+}
+
+// LookaheadBot 在一步评分的基础上，考虑自己下一回合能达到的最好局面，以及对手下一回合的威胁
+type LookaheadBot struct {
+	rng *rand.Rand
+}
+
+// ChooseMove 向前看自己的两个回合和对手的一个回合
+func (b *LookaheadBot) ChooseMove(g *engine.Game, pid int) engine.Move {
+	return best(b.rng, g, pid, func(pos *position) float64 {
+		return lookahead(g, pid, pos)
+	})
+}
+
+// lookahead 计入下一回合和对手威胁的局面评分
+func lookahead(g *engine.Game, pid int, pos *position) float64 {
+	if pos.finishing(g) {
+		return pos.evaluate(g)
+	}
+	score := pos.evaluate(g)
+	// 下一回合能达到的最好局面，打折计入
+	next := score
+	for _, follow := range pos.followUps(g) {
+		next = max(next, follow.evaluate(g))
+	}
+	score += 0.5 * (next - score)
+	return score - pos.threat(g, pid)
+}
+
+// best 返回评分最高的合法回合
+func best(rng *rand.Rand, g *engine.Game, pid int, score func(pos *position) float64) engine.Move {
+	moves := g.LegalMoves(pid)
+	if len(moves) == 0 {
+		return engine.Move{Type: engine.MovePass}
+	}
+	p := g.Players[pid]
+	var chosen engine.Move
+	var bestScore float64
+	ties := 0
+	for i, m := range moves {
+		pos := newPosition(g, p)
+		pos.apply(g, m)
+		s := score(pos)
+		if i == 0 || s > bestScore+1e-9 {
+			chosen, bestScore, ties = m, s, 1
+		} else if s > bestScore-1e-9 {
+			// 蓄水池抽样，评分相同的回合等概率选择
+			ties++
+			if rng.Intn(ties) == 0 {
+				chosen = m
+			}
+		}
+	}
+	return chosen
+}
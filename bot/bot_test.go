@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"os"
+	"splendor-go/engine"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 测试在 bot 目录中运行
+	engine.CardFile = "../resources/cards.json"
+	os.Exit(m.Run())
+}
+
+// newGame 创建并开始一局 n 名玩家的游戏
+func newGame(t *testing.T, seed int64, rules engine.Rules, n int) *engine.Game {
+	t.Helper()
+	g := engine.NewGame(seed, rules)
+	for i := 0; i < n; i++ {
+		g.AddPlayer("p")
+	}
+	if !g.StartGame() {
+		t.Fatal("the game can't start")
+	}
+	return g
+}
+
+// TestBotMovesAreValid 每个难度的机器人在各种规则下选择的回合都能通过校验
+func TestBotMovesAreValid(t *testing.T) {
+	variants := []struct {
+		name  string
+		rules engine.Rules
+	}{
+		{"standard", engine.Rules{}},
+		{"cities", engine.Rules{Cities: true}},
+		{"orient", engine.Rules{Orient: true}},
+		{"outposts", engine.Rules{Outposts: true}},
+		{"strongholds", engine.Rules{Strongholds: true}},
+	}
+	levels := []struct {
+		cfg   Config
+		seeds int64
+		turns int
+	}{
+		{Config{Level: LevelEasy}, 3, 200},
+		{Config{Level: LevelMedium}, 5, 200},
+		{Config{Level: LevelHard}, 1, 200},
+		{Config{Level: LevelMCTS, Playouts: 20}, 1, 30},
+	}
+	for _, l := range levels {
+		for _, v := range variants {
+			t.Run(l.cfg.Level+"/"+v.name, func(t *testing.T) {
+				for seed := int64(1); seed <= l.seeds; seed++ {
+					cfg := l.cfg
+					cfg.Seed = seed
+					b, err := New(cfg)
+					if err != nil {
+						t.Fatal(err)
+					}
+					g := newGame(t, seed, v.rules, 3)
+					for turn := 0; turn < l.turns && g.State == engine.PlayingState; turn++ {
+						pid := g.ActivePlayerId
+						m := b.ChooseMove(g.Clone(), pid)
+						if err := g.ValidateMove(pid, m); err != nil {
+							t.Fatalf("seed %d turn %d: move %s rejected: %v", seed, turn, m.Key(), err)
+						} else if err := g.ApplyMove(pid, m); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestBotsWithoutLegalMoves 回合进行到一半时，每个难度的机器人都只能放弃
+func TestBotsWithoutLegalMoves(t *testing.T) {
+	for _, level := range Levels {
+		t.Run(level, func(t *testing.T) {
+			g := newGame(t, 1, engine.Rules{}, 2)
+			pid := g.ActivePlayerId
+			if res := g.Act(pid, engine.Action{Type: engine.ActionTake, Target: "W"}); res != nil && res.Error != "" {
+				t.Fatal(res.Error)
+			}
+			b, err := New(Config{Level: level, Playouts: 20, Seed: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m := b.ChooseMove(g, pid); m.Type != engine.MovePass {
+				t.Fatalf("got %s, want a pass", m.Key())
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"splendor-go/engine"
+	"strings"
+)
+
+var (
+	colorNames = map[string]string{
+		"W": "white",
+		"B": "blue",
+		"G": "green",
+		"R": "red",
+		"K": "black",
+	}
+)
+
+// Hint 给玩家的一条建议，评分与 hard 机器人相同，越高越好
+type Hint struct {
+	Move    engine.Move
+	Score   float64
+	Reasons []string
+}
+
+// NobleProgress 玩家距离访问贵族还缺少的发展卡
+type NobleProgress struct {
+	Noble   *engine.Noble
+	Missing map[string]int
+}
+
+// CityProgress 玩家距离获得城市还缺少的分数和发展卡
+type CityProgress struct {
+	City    *engine.City
+	Points  int
+	Missing map[string]int
+}
+
+// Analysis 玩家当前的局面：现在能买的卡牌、再拿一次宝石就能买的卡牌、贵族和城市的进度
+type Analysis struct {
+	Affordable []*engine.DevCard
+	OneTurn    []*engine.DevCard
+	Nobles     []NobleProgress
+	Cities     []CityProgress
+}
+
+// Analyze 分析玩家能看到的桌面卡牌和自己预定的卡牌
+func Analyze(g *engine.Game, pid int) *Analysis {
+	p := g.Players[pid]
+	a := &Analysis{
+		Affordable: make([]*engine.DevCard, 0),
+		OneTurn:    make([]*engine.DevCard, 0),
+		Nobles:     make([]NobleProgress, 0),
+		Cities:     make([]CityProgress, 0),
+	}
+	for _, card := range newPosition(g, p).candidates(g) {
+		missing := p.Shortfall(card)
+		total := 0
+		for _, n := range missing {
+			total += n
+		}
+		if total <= p.Golds {
+			a.Affordable = append(a.Affordable, card)
+		} else if total-oneTake(g, missing) <= p.Golds {
+			a.OneTurn = append(a.OneTurn, card)
+		}
+	}
+	for _, n := range g.Nobles {
+		a.Nobles = append(a.Nobles, NobleProgress{Noble: n, Missing: p.NobleShortfall(n)})
+	}
+	sort.SliceStable(a.Nobles, func(i, j int) bool {
+		return countSum(a.Nobles[i].Missing) < countSum(a.Nobles[j].Missing)
+	})
+	for _, c := range g.Cities {
+		points, missing := p.CityShortfall(c)
+		a.Cities = append(a.Cities, CityProgress{City: c, Points: points, Missing: missing})
+	}
+	sort.SliceStable(a.Cities, func(i, j int) bool {
+		return a.Cities[i].Points+countSum(a.Cities[i].Missing) < a.Cities[j].Points+countSum(a.Cities[j].Missing)
+	})
+	return a
+}
+
+// oneTake 拿一次宝石最多能补上的缺口
+func oneTake(g *engine.Game, missing map[string]int) int {
+	different := 0
+	same := 0
+	for c, n := range missing {
+		if g.Gems[c] > 0 {
+			different++
+		}
+		if g.Gems[c] >= 4 {
+			same = max(same, min(2, n))
+		}
+	}
+	return max(min(3, different), same)
+}
+
+// Suggest 列出玩家评分最高的 n 个回合和理由，只有支付、丢弃或贵族不同的回合只保留评分最高的一个
+func Suggest(g *engine.Game, pid, n int) []Hint {
+	p := g.Players[pid]
+	before := newPosition(g, p)
+	baseThreat := before.threat(g, pid)
+	hints := make([]Hint, 0)
+	for _, m := range g.LegalMoves(pid) {
+		pos := newPosition(g, p)
+		pos.apply(g, m)
+		hints = append(hints, Hint{
+			Move:    m,
+			Score:   lookahead(g, pid, pos),
+			Reasons: explain(g, pid, m, before, pos, baseThreat),
+		})
+	}
+	sort.SliceStable(hints, func(i, j int) bool {
+		return hints[i].Score > hints[j].Score
+	})
+	result := make([]Hint, 0, n)
+	seen := make(map[string]bool)
+	for _, h := range hints {
+		key := engine.Move{Type: h.Move.Type, Gems: h.Move.Gems, Card: h.Move.Card, Level: h.Move.Level}.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, h)
+		if len(result) == n {
+			break
+		}
+	}
+	return result
+}
+
+// explain 用简短的句子说明回合的作用
+func explain(g *engine.Game, pid int, m engine.Move, before, after *position, baseThreat float64) []string {
+	reasons := make([]string, 0)
+	switch m.Type {
+	case engine.MoveBuy:
+		card := g.CardMap[m.Card]
+		from := ""
+		if before.tableCard(g, m.Card) == nil {
+			from = " from your reserve"
+		}
+		reasons = append(reasons, fmt.Sprintf("Buy a level %d %s card%s worth %d points",
+			card.Level, cardColor(card), from, card.Points))
+		if reason := abilityReason(g, card, m.Choice); reason != "" {
+			reasons = append(reasons, reason)
+		}
+		if m.PostGem != "" {
+			reasons = append(reasons, fmt.Sprintf("Take 1 %s from your outpost", colorNames[m.PostGem]))
+		}
+	case engine.MoveStronghold:
+		card := g.CardMap[m.Card]
+		reasons = append(reasons, fmt.Sprintf("Guard a level %d %s card with a stronghold",
+			card.Level, cardColor(card)))
+	case engine.MoveRaze:
+		card := g.CardMap[m.Card]
+		reasons = append(reasons, fmt.Sprintf("Raze an opponent's stronghold on a level %d %s card",
+			card.Level, cardColor(card)))
+	case engine.MoveReserve:
+		card := g.CardMap[m.Card]
+		reasons = append(reasons, fmt.Sprintf("Reserve a level %d %s card worth %d points",
+			card.Level, cardColor(card), card.Points))
+	case engine.MoveReservePile:
+		reasons = append(reasons, fmt.Sprintf("Reserve a face-down level %d card", m.Level))
+	case engine.MoveTakeDifferent, engine.MoveTakeSame:
+		reasons = append(reasons, "Take "+gemList(m.Gems))
+	case engine.MovePass:
+		reasons = append(reasons, "No other move is possible")
+	}
+	if (m.Type == engine.MoveReserve || m.Type == engine.MoveReservePile) && after.gems[engine.GoldKey] > before.gems[engine.GoldKey] {
+		reasons = append(reasons, "Gain a gold")
+	}
+	if after.finishing(g) && g.Rules.Cities {
+		reasons = append(reasons, "Claim a city and trigger the end of the game")
+	} else if after.finishing(g) {
+		reasons = append(reasons, fmt.Sprintf("Reach %d points and trigger the end of the game", after.points))
+	}
+	for _, n := range g.Nobles {
+		if after.visited[n.Uuid] {
+			reasons = append(reasons, fmt.Sprintf("Attract a noble for %d points", engine.NoblePoints))
+		} else if was, now := before.missing(n.Cost), after.missing(n.Cost); now < was {
+			reasons = append(reasons, fmt.Sprintf("Get closer to a noble (%d cards left)", now))
+		}
+	}
+	// 下一回合新能买的卡牌，不包括刚买下的
+	ready := 0
+	for _, card := range after.candidates(g) {
+		if card.Uuid != m.Card && after.deficit(card) == 0 && before.deficit(card) > 0 {
+			ready++
+		}
+	}
+	// 说明离哪张卡牌更近了，与 reach 一样兼顾分数和还差的宝石
+	var target *engine.DevCard
+	var targetWorth float64
+	for _, card := range after.candidates(g) {
+		short := after.deficit(card)
+		if short == 0 || short >= before.deficit(card) {
+			continue
+		}
+		worth := (3*float64(card.Points) + 2) / float64((1+short)*(1+short))
+		if target == nil || worth > targetWorth {
+			target, targetWorth = card, worth
+		}
+	}
+	if target != nil && ready == 0 {
+		short := fmt.Sprintf("%d gems", after.deficit(target))
+		if after.deficit(target) == 1 {
+			short = "1 gem"
+		}
+		reasons = append(reasons, fmt.Sprintf("Work toward a level %d %s card worth %d points (%s short)",
+			target.Level, colorNames[target.Color], target.Points, short))
+	}
+	if ready == 1 {
+		reasons = append(reasons, "Make 1 more card affordable next turn")
+	} else if ready > 1 {
+		reasons = append(reasons, fmt.Sprintf("Make %d more cards affordable next turn", ready))
+	}
+	if after.threat(g, pid) < baseThreat && m.Type == engine.MoveStronghold {
+		reasons = append(reasons, "Guard a card an opponent could buy next turn")
+	} else if after.threat(g, pid) < baseThreat {
+		reasons = append(reasons, "Take a card an opponent could buy next turn")
+	}
+	if discards := countSum(m.Discards); discards > 0 {
+		reasons = append(reasons, fmt.Sprintf("Return %s to stay within the gem limit", gemList(m.Discards)))
+	}
+	return reasons
+}
+
+// cardColor 卡牌颜色的名称，join 卡牌没有颜色
+func cardColor(card *engine.DevCard) string {
+	if card.Ability == engine.AbilityJoin {
+		return "joker"
+	}
+	return colorNames[card.Color]
+}
+
+// abilityReason 说明东方卡牌能力的作用
+func abilityReason(g *engine.Game, card *engine.DevCard, choice string) string {
+	switch card.Ability {
+	case engine.AbilityDouble:
+		return fmt.Sprintf("Count as 2 %s cards", colorNames[card.Color])
+	case engine.AbilityJoin:
+		if choice != "" {
+			return fmt.Sprintf("Join it to your %s cards", colorNames[choice])
+		}
+	case engine.AbilityReserveNoble:
+		return "Reserve a noble only you can attract"
+	case engine.AbilityFreeCard:
+		if free := g.CardMap[choice]; free != nil {
+			return fmt.Sprintf("Take a level %d %s card worth %d points for free", free.Level, colorNames[free.Color], free.Points)
+		}
+	case engine.AbilitySacrifice:
+		return fmt.Sprintf("Sacrifice 2 %s bonuses instead of paying gems", colorNames[card.Color])
+	}
+	return ""
+}
+
+// gemList 把宝石数量转换为可读的列表
+func gemList(gems map[string]int) string {
+	parts := make([]string, 0)
+	for _, c := range append(append([]string{}, engine.ColorList...), engine.GoldKey) {
+		if gems[c] == 0 {
+			continue
+		}
+		name := colorNames[c]
+		if c == engine.GoldKey {
+			name = "gold"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", gems[c], name))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func countSum(m map[string]int) int {
+	sum := 0
+	for _, v := range m {
+		sum += v
+	}
+	return sum
+}
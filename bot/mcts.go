@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"math"
+	"math/rand"
+	"splendor-go/engine"
+	"time"
+)
+
+const (
+	DefaultPlayouts = 3000
+	DefaultBudget   = 2 * time.Second
+	DefaultDepth    = 24
+	// explore UCB 公式中探索项的系数
+	explore = 0.7
+	// temperature 把局面评分换算为胜率时的温度，约为一分的评分
+	temperature = 30
+)
+
+// MCTSBot 基于信息集蒙特卡洛树搜索（SO-ISMCTS）的机器人
+// 每次模拟前从自己的视角重新洗混看不到的卡牌，所有模拟共用一棵以回合为边的树
+type MCTSBot struct {
+	Playouts int           // 每步最多的模拟次数
+	Budget   time.Duration // 每步最多的思考时间
+	Depth    int           // 每次模拟最多走的回合数，之后用局面评分估计结果
+	rng      *rand.Rand
+}
+
+type node struct {
+	move     engine.Move
+	player   int // 执行 move 的玩家
+	parent   *node
+	children map[string]*node
+	visits   int
+	avail    int // 该回合合法的次数
+	reward   float64
+}
+
+// ChooseMove 在次数和时间限制内模拟，返回访问次数最多的回合
+func (b *MCTSBot) ChooseMove(g *engine.Game, pid int) engine.Move {
+	moves := g.LegalMoves(pid)
+	if len(moves) == 0 {
+		return engine.Move{Type: engine.MovePass}
+	} else if len(moves) == 1 {
+		return moves[0]
+	}
+	root := &node{children: make(map[string]*node)}
+	deadline := time.Now().Add(b.Budget)
+	for i := 0; i < b.Playouts; i++ {
+		if b.Budget > 0 && time.Now().After(deadline) {
+			break
+		}
+		state := g.Determinize(pid, b.rng)
+		leaf, err := b.descend(root, state)
+		if err != nil {
+			// 推演出错时放弃这次模拟
+			continue
+		}
+		rewards := b.rollout(state)
+		for n := leaf; n != root; n = n.parent {
+			n.visits++
+			n.reward += rewards[n.player]
+		}
+		root.visits++
+	}
+	var chosen *node
+	for _, child := range root.children {
+		if chosen == nil || child.visits > chosen.visits {
+			chosen = child
+		}
+	}
+	if chosen == nil {
+		return moves[0]
+	}
+	return chosen.move
+}
+
+// descend 沿树选择并扩展一个新节点，同时在 state 上执行经过的回合，返回到达的节点
+// 回合执行失败时删除新扩展的节点并返回错误
+func (b *MCTSBot) descend(n *node, state *engine.Game) (*node, error) {
+	for state.State == engine.PlayingState {
+		mover := state.ActivePlayerId
+		legal := state.LegalMoves(mover)
+		untried := make([]engine.Move, 0)
+		var best *node
+		var bestScore float64
+		for _, m := range legal {
+			child, exists := n.children[m.Key()]
+			if !exists {
+				untried = append(untried, m)
+				continue
+			}
+			child.avail++
+			score := child.reward/float64(child.visits) +
+				explore*math.Sqrt(math.Log(float64(child.avail))/float64(child.visits))
+			if best == nil || score > bestScore {
+				best, bestScore = child, score
+			}
+		}
+		if len(untried) > 0 {
+			m := untried[b.rng.Intn(len(untried))]
+			child := &node{move: m, player: mover, parent: n, children: make(map[string]*node), avail: 1}
+			n.children[m.Key()] = child
+			if err := b.apply(state, mover, m); err != nil {
+				delete(n.children, m.Key())
+				return nil, err
+			}
+			return child, nil
+		}
+		if err := b.apply(state, mover, best.move); err != nil {
+			return nil, err
+		}
+		n = best
+	}
+	return n, nil
+}
+
+// rollout 用简单的策略模拟若干回合，返回每个座位的得分
+func (b *MCTSBot) rollout(state *engine.Game) []float64 {
+	for i := 0; i < b.Depth && state.State == engine.PlayingState; i++ {
+		pid := state.ActivePlayerId
+		// 没有合法回合或执行失败时停止模拟，按当前局面估计结果
+		m, ok := b.playoutMove(state, pid)
+		if !ok || b.apply(state, pid, m) != nil {
+			break
+		}
+	}
+	rewards := make([]float64, state.PlayerNum)
+	if state.State == engine.EndedState {
+		// 平局时并列的玩家平分胜利
+		winners := state.Winners()
+		for _, p := range winners {
+			rewards[p.Id] = 1 / float64(len(winners))
+		}
+		return rewards
+	}
+	// 未结束时按局面评分估计各玩家的胜率
+	scores := make([]float64, state.PlayerNum)
+	top := math.Inf(-1)
+	for i, p := range state.Players[:state.PlayerNum] {
+		scores[i] = newPosition(state, p).evaluate(state)
+		top = max(top, scores[i])
+	}
+	var sum float64
+	for i, s := range scores {
+		rewards[i] = math.Exp((s - top) / temperature)
+		sum += rewards[i]
+	}
+	for i := range rewards {
+		rewards[i] /= sum
+	}
+	return rewards
+}
+
+// playoutMove 模拟时的策略：多数时候买分数最高的卡，否则随机拿宝石
+// 直接构造回合而不枚举所有合法回合，构造的回合不合法时才从合法回合中随机选择，没有合法回合时返回 false
+func (b *MCTSBot) playoutMove(g *engine.Game, pid int) (engine.Move, bool) {
+	p := g.Players[pid]
+	pos := newPosition(g, p)
+	var buy engine.Move
+	var card *engine.DevCard
+	for _, c := range pos.candidates(g) {
+		if (card == nil || c.Points > card.Points) && pos.deficit(c) == 0 {
+			card = c
+		}
+	}
+	if card != nil && b.rng.Intn(5) > 0 {
+		buy = engine.Move{Type: engine.MoveBuy, Card: card.Uuid, Payment: pos.payment(card)}
+		pos.bonus[card.Color]++
+		// 同时满足多个贵族时选择第一个
+		eligible := make([]string, 0)
+		for _, n := range g.Nobles {
+			if pos.missing(n.Cost) == 0 {
+				eligible = append(eligible, n.Uuid)
+			}
+		}
+		if len(eligible) > 1 {
+			buy.Noble = eligible[0]
+		}
+		if g.ValidateMove(pid, buy) == nil {
+			return buy, true
+		}
+	} else if take, ok := b.randomTake(g, pos); ok && g.ValidateMove(pid, take) == nil {
+		return take, true
+	}
+	moves := g.LegalMoves(pid)
+	if len(moves) == 0 {
+		return engine.Move{}, false
+	}
+	return moves[b.rng.Intn(len(moves))], true
+}
+
+// randomTake 随机拿取不同颜色的宝石，超过上限时随机丢弃
+func (b *MCTSBot) randomTake(g *engine.Game, pos *position) (engine.Move, bool) {
+	available := make([]string, 0, len(engine.ColorList))
+	for _, c := range engine.ColorList {
+		if g.Gems[c] > 0 {
+			available = append(available, c)
+		}
+	}
+	if len(available) == 0 {
+		return engine.Move{}, false
+	}
+	b.rng.Shuffle(len(available), func(i, j int) {
+		available[i], available[j] = available[j], available[i]
+	})
+	m := engine.Move{Type: engine.MoveTakeDifferent, Gems: make(map[string]int)}
+	for _, c := range available[:min(3, len(available))] {
+		m.Gems[c] = 1
+		pos.gems[c]++
+	}
+	// 按固定的颜色顺序列出手中的宝石，相同种子的丢弃结果才能重现
+	held := make([]string, 0)
+	for _, c := range append(append([]string{}, engine.ColorList...), engine.GoldKey) {
+		for i := 0; i < pos.gems[c]; i++ {
+			held = append(held, c)
+		}
+	}
+	if excess := len(held) - g.Rules.MaxGems; excess > 0 {
+		m.Discards = make(map[string]int)
+		b.rng.Shuffle(len(held), func(i, j int) {
+			held[i], held[j] = held[j], held[i]
+		})
+		for _, c := range held[:excess] {
+			m.Discards[c]++
+		}
+	}
+	return m, true
+}
+
+// apply 执行合法的回合，推演中不应出现校验失败，出现时由调用者放弃这一分支
+func (b *MCTSBot) apply(state *engine.Game, pid int, m engine.Move) error {
+	return state.ApplyMove(pid, m)
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"math/rand"
+	"splendor-go/engine"
+	"testing"
+)
+
+// TestRandomTakeIsReproducible 相同种子的机器人丢弃相同的宝石
+func TestRandomTakeIsReproducible(t *testing.T) {
+	g := newGame(t, 1, engine.Rules{}, 2)
+	p := g.Players[g.ActivePlayerId]
+	for _, c := range engine.ColorList {
+		p.Gems[c] = 2
+	}
+	var first string
+	for i := 0; i < 20; i++ {
+		b := &MCTSBot{rng: rand.New(rand.NewSource(1))}
+		m, ok := b.randomTake(g, newPosition(g, p))
+		if !ok || len(m.Discards) == 0 {
+			t.Fatalf("got %s, want a take with discards", m.Key())
+		}
+		if i == 0 {
+			first = m.Key()
+		} else if m.Key() != first {
+			t.Fatalf("got %s, want %s", m.Key(), first)
+		}
+	}
+}
+
+// TestPlayoutWithoutLegalMoves 回合进行到一半时没有完整的合法回合，模拟应停止而不是出错
+func TestPlayoutWithoutLegalMoves(t *testing.T) {
+	g := newGame(t, 1, engine.Rules{}, 2)
+	pid := g.ActivePlayerId
+	if res := g.Act(pid, engine.Action{Type: engine.ActionTake, Target: "W"}); res != nil && res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	b := &MCTSBot{Depth: DefaultDepth, rng: rand.New(rand.NewSource(1))}
+	if m, ok := b.playoutMove(g, pid); ok {
+		t.Fatalf("got %s in the middle of a turn", m.Key())
+	}
+	if rewards := b.rollout(g); len(rewards) != g.PlayerNum {
+		t.Fatalf("got %d rewards, want %d", len(rewards), g.PlayerNum)
+	}
+}
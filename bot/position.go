@@ -0,0 +1,425 @@
+package bot
+
+import (
+	"sort"
+	"splendor-go/engine"
+)
+
+// position 机器人推演中一名玩家的局面，不考虑补牌等未知信息
+type position struct {
+	player   *engine.Player
+	gems     map[string]int // 手中的宝石，包括黄金
+	bonus    map[string]int
+	points   int
+	cards    int
+	reserved []*engine.DevCard
+	hidden   int             // 推演中从牌堆预定的卡牌，内容未知
+	gone     map[string]bool // 推演中已离开桌面的卡牌
+	visited  map[string]bool // 推演中已访问的贵族
+	supply   map[string]int  // 桌面上的宝石，包括黄金
+	guarded  map[string]bool // 推演中放置了自己要塞的卡牌，对手不能买下
+}
+
+func newPosition(g *engine.Game, p *engine.Player) *position {
+	pos := &position{
+		player:   p,
+		gems:     make(map[string]int),
+		bonus:    make(map[string]int),
+		points:   p.Points,
+		reserved: append([]*engine.DevCard{}, p.Reserved...),
+		gone:     make(map[string]bool),
+		visited:  make(map[string]bool),
+		supply:   make(map[string]int),
+		guarded:  make(map[string]bool),
+	}
+	// 有其他玩家要塞的卡牌视为已离开桌面
+	for _, card := range g.TableCards() {
+		if p.Blocked(card) {
+			pos.gone[card.Uuid] = true
+		}
+	}
+	for _, c := range engine.ColorList {
+		pos.gems[c] = p.Gems[c]
+		pos.bonus[c] = p.Bonus(c)
+		pos.cards += len(p.Cards[c])
+		pos.supply[c] = g.Gems[c]
+	}
+	pos.gems[engine.GoldKey] = p.Golds
+	pos.supply[engine.GoldKey] = g.Golds
+	return pos
+}
+
+func (pos *position) clone() *position {
+	c := *pos
+	c.gems = copyMap(pos.gems)
+	c.bonus = copyMap(pos.bonus)
+	c.supply = copyMap(pos.supply)
+	c.reserved = append([]*engine.DevCard{}, pos.reserved...)
+	c.gone = make(map[string]bool)
+	for k, v := range pos.gone {
+		c.gone[k] = v
+	}
+	c.visited = make(map[string]bool)
+	for k, v := range pos.visited {
+		c.visited[k] = v
+	}
+	c.guarded = make(map[string]bool)
+	for k, v := range pos.guarded {
+		c.guarded[k] = v
+	}
+	return &c
+}
+
+// apply 推演执行一个回合，回合需已通过校验
+func (pos *position) apply(g *engine.Game, m engine.Move) {
+	switch m.Type {
+	case engine.MoveTakeDifferent, engine.MoveTakeSame:
+		for c, n := range m.Gems {
+			pos.gems[c] += n
+			pos.supply[c] -= n
+		}
+	case engine.MoveReserve, engine.MoveReservePile:
+		if m.Type == engine.MoveReserve {
+			pos.reserved = append(pos.reserved, pos.tableCard(g, m.Card))
+			pos.gone[m.Card] = true
+		} else {
+			pos.hidden++
+		}
+		if pos.supply[engine.GoldKey] > 0 {
+			pos.gems[engine.GoldKey]++
+			pos.supply[engine.GoldKey]--
+		}
+	case engine.MoveBuy:
+		card := pos.tableCard(g, m.Card)
+		if card != nil {
+			pos.gone[m.Card] = true
+		} else {
+			for i, r := range pos.reserved {
+				if r.Uuid == m.Card {
+					card = r
+					pos.reserved = append(pos.reserved[:i:i], pos.reserved[i+1:]...)
+					break
+				}
+			}
+		}
+		for c, n := range m.Payment {
+			pos.gems[c] -= n
+			pos.supply[c] += n
+		}
+		pos.addCard(g, card, m.Choice)
+		if m.PostGem != "" {
+			pos.gems[m.PostGem]++
+			pos.supply[m.PostGem]--
+		}
+	case engine.MoveStronghold:
+		pos.guarded[m.Card] = true
+	case engine.MoveRaze:
+		// 不考虑同一卡牌上有多个对手的要塞
+		delete(pos.gone, m.Card)
+	}
+	for c, n := range m.Discards {
+		pos.gems[c] -= n
+		pos.supply[c] += n
+	}
+	// 回合结束时访问贵族，未指定时访问唯一满足条件的贵族
+	for _, n := range g.Nobles {
+		if pos.visited[n.Uuid] || (m.Noble != "" && n.Uuid != m.Noble) || pos.missing(n.Cost) > 0 {
+			continue
+		}
+		pos.visited[n.Uuid] = true
+		pos.points += engine.NoblePoints
+		break
+	}
+}
+
+// addCard 推演买下卡牌后的奖励和分数，包括东方卡牌的能力
+func (pos *position) addCard(g *engine.Game, card *engine.DevCard, choice string) {
+	pos.points += card.Points
+	pos.cards++
+	switch card.Ability {
+	case engine.AbilityJoin:
+		// 未指定颜色时只有一种颜色可选，没有颜色时不提供奖励
+		for _, c := range engine.ColorList {
+			if choice == "" && pos.bonus[c] > 0 {
+				choice = c
+			}
+		}
+		if choice != "" {
+			pos.bonus[choice]++
+		}
+		return
+	case engine.AbilitySacrifice:
+		// 与引擎相同，丢弃实际拥有的卡牌，同时失去它们的分数
+		for _, c := range pos.player.Sacrifices(card) {
+			pos.bonus[c.Color] = max(0, pos.bonus[c.Color]-c.Bonus())
+			pos.points -= c.Points
+			pos.cards--
+		}
+	case engine.AbilityFreeCard:
+		if free := g.CardMap[choice]; free != nil {
+			pos.bonus[free.Color]++
+			pos.points += free.Points
+			pos.cards++
+			pos.gone[free.Uuid] = true
+		}
+	}
+	pos.bonus[card.Color] += card.Bonus()
+}
+
+// evaluate 局面评分，越高越好
+func (pos *position) evaluate(g *engine.Game) float64 {
+	if pos.finishing(g) {
+		return 10000 + float64(pos.points)
+	}
+	score := 30 * float64(pos.points)
+	// 奖励使以后的卡牌更便宜
+	for _, c := range engine.ColorList {
+		score += float64(pos.bonus[c]) * (4 + pos.demand(g, c))
+	}
+	// 接近贵族
+	for _, n := range g.Nobles {
+		if !pos.visited[n.Uuid] {
+			score += 24 / float64(1+pos.missing(n.Cost))
+		}
+	}
+	// 接近城市，只有最近的一个有意义
+	var city float64
+	for _, c := range g.Cities {
+		city = max(city, 60/float64(1+pos.missing(c.Cost)+max(0, c.Points-pos.points)))
+	}
+	score += city
+	// 接近能买的卡牌
+	score += pos.reach(g)
+	// 宝石本身，黄金可以代替任何颜色
+	for _, c := range engine.ColorList {
+		score += 0.5 * float64(pos.gems[c])
+	}
+	score += float64(pos.gems[engine.GoldKey])
+	// 预定太多卡牌会占用名额
+	if r := len(pos.reserved) + pos.hidden; r > 2 {
+		score -= 3 * float64(r-2)
+	}
+	return score
+}
+
+// finishing 推演局面是否触发游戏结束：标准规则下达到目标分数，城市模式下满足任意一个城市
+func (pos *position) finishing(g *engine.Game) bool {
+	if !g.Rules.Cities {
+		return pos.points >= g.Rules.WinPoints
+	}
+	for _, c := range g.Cities {
+		if pos.points >= c.Points && pos.missing(c.Cost) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates 推演中可以购买的卡牌：桌面上剩下的和自己预定的
+func (pos *position) candidates(g *engine.Game) []*engine.DevCard {
+	cards := make([]*engine.DevCard, 0)
+	for _, card := range g.TableCards() {
+		if !pos.gone[card.Uuid] {
+			cards = append(cards, card)
+		}
+	}
+	return append(cards, pos.reserved...)
+}
+
+// demand 某种颜色的奖励对卡牌、贵族和城市的需求程度
+func (pos *position) demand(g *engine.Game, color string) float64 {
+	cards := pos.candidates(g)
+	var d float64
+	for _, card := range cards {
+		if card.Cost[color] > pos.bonus[color] {
+			d++
+		}
+	}
+	if len(cards) > 0 {
+		d = 4 * d / float64(len(cards))
+	}
+	for _, n := range g.Nobles {
+		if !pos.visited[n.Uuid] && n.Cost[color] > pos.bonus[color] {
+			d += 1.5
+		}
+	}
+	for _, c := range g.Cities {
+		if c.Cost[color] > pos.bonus[color] {
+			d += 1.5
+		}
+	}
+	return d
+}
+
+// reach 离最值得买的几张卡牌还差多少，差得越少评分越高
+func (pos *position) reach(g *engine.Game) float64 {
+	values := make([]float64, 0)
+	for _, card := range pos.candidates(g) {
+		worth := 3*float64(card.Points) + 2
+		for _, n := range g.Nobles {
+			if !pos.visited[n.Uuid] && n.Cost[card.Color] > pos.bonus[card.Color] {
+				worth++
+				break
+			}
+		}
+		deficit := float64(pos.deficit(card))
+		values = append(values, worth/((1+deficit)*(1+deficit)))
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+	var score float64
+	weight := 1.0
+	for i := 0; i < len(values) && i < 3; i++ {
+		score += weight * values[i]
+		weight /= 2
+	}
+	return score
+}
+
+// missing 距离满足奖励要求还差的数量
+func (pos *position) missing(cost map[string]int) int {
+	n := 0
+	for c, v := range cost {
+		n += max(0, v-pos.bonus[c])
+	}
+	return n
+}
+
+// deficit 买下卡牌还差的宝石数量，已扣除黄金；献祭卡牌还差的是奖励
+func (pos *position) deficit(card *engine.DevCard) int {
+	if card.Ability == engine.AbilitySacrifice {
+		return max(0, 2-pos.bonus[card.Color])
+	} else if card.Ability == engine.AbilityJoin && pos.cards == 0 {
+		return 1
+	}
+	n := 0
+	for _, c := range engine.ColorList {
+		n += max(0, card.Cost[c]-pos.bonus[c]-pos.gems[c])
+	}
+	return max(0, n-pos.gems[engine.GoldKey])
+}
+
+// payment 优先使用宝石的支付方案，买不起时返回 nil
+func (pos *position) payment(card *engine.DevCard) map[string]int {
+	if pos.deficit(card) > 0 {
+		return nil
+	}
+	pay := make(map[string]int)
+	for _, c := range engine.ColorList {
+		need := max(0, card.Cost[c]-pos.bonus[c])
+		gems := min(need, pos.gems[c])
+		if gems > 0 {
+			pay[c] = gems
+		}
+		if need > gems {
+			pay[engine.GoldKey] += need - gems
+		}
+	}
+	return pay
+}
+
+// followUps 推演自己下一回合拿宝石或买卡后的局面，不考虑丢弃宝石
+func (pos *position) followUps(g *engine.Game) []*position {
+	moves := make([]engine.Move, 0)
+	available := make([]string, 0)
+	for _, c := range engine.ColorList {
+		if pos.supply[c] > 0 {
+			available = append(available, c)
+		}
+	}
+	total := 0
+	for _, n := range pos.gems {
+		total += n
+	}
+	n := min(3, len(available))
+	if total+n <= g.Rules.MaxGems {
+		for _, colors := range engine.Combinations(available, n) {
+			gems := make(map[string]int)
+			for _, c := range colors {
+				gems[c] = 1
+			}
+			moves = append(moves, engine.Move{Type: engine.MoveTakeDifferent, Gems: gems})
+		}
+	}
+	if total+2 <= g.Rules.MaxGems {
+		for _, c := range engine.ColorList {
+			if pos.supply[c] >= 4 {
+				moves = append(moves, engine.Move{Type: engine.MoveTakeSame, Gems: map[string]int{c: 2}})
+			}
+		}
+	}
+	for _, card := range pos.candidates(g) {
+		if pay := pos.payment(card); pay != nil {
+			moves = append(moves, engine.Move{Type: engine.MoveBuy, Card: card.Uuid, Payment: pay})
+		}
+	}
+	result := make([]*position, len(moves))
+	for i, m := range moves {
+		result[i] = pos.clone()
+		result[i].apply(g, m)
+	}
+	return result
+}
+
+// threat 推演局面下对手下一回合能得到的分数，对手可能因此触发游戏结束时威胁最大
+func (pos *position) threat(g *engine.Game, pid int) float64 {
+	var threat float64
+	for _, o := range g.Players[:g.PlayerNum] {
+		if o.Id == pid {
+			continue
+		}
+		opponent := newPosition(g, o)
+		opponent.gone = pos.gone
+		if len(pos.guarded) > 0 {
+			opponent.gone = make(map[string]bool)
+			for _, gone := range []map[string]bool{pos.gone, pos.guarded} {
+				for k, v := range gone {
+					opponent.gone[k] = v
+				}
+			}
+		}
+		opponent.reserved = nil
+		gain := 0
+		finishing := false
+		for _, card := range opponent.candidates(g) {
+			if opponent.payment(card) == nil {
+				continue
+			}
+			points := card.Points
+			opponent.bonus[card.Color]++
+			for _, n := range g.Nobles {
+				if !pos.visited[n.Uuid] && opponent.missing(n.Cost) == 0 {
+					points += engine.NoblePoints
+					break
+				}
+			}
+			opponent.points = o.Points + points
+			finishing = finishing || opponent.finishing(g)
+			opponent.bonus[card.Color]--
+			gain = max(gain, points)
+		}
+		opponent.points = o.Points + gain
+		if finishing || opponent.finishing(g) {
+			threat += 60
+		} else {
+			threat += 3 * float64(gain)
+		}
+	}
+	return threat
+}
+
+func copyMap(m map[string]int) map[string]int {
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func (pos *position) tableCard(g *engine.Game, uuid string) *engine.DevCard {
+	for _, card := range g.TableCards() {
+		if card.Uuid == uuid {
+			return card
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package bot
+
+import (
+	"splendor-go/engine"
+	"testing"
+)
+
+// placeSacrifice 把一张献祭卡牌放到桌上，在牌堆中时与桌上的第一张交换
+func placeSacrifice(t *testing.T, g *engine.Game) *engine.DevCard {
+	t.Helper()
+	row, pile := g.OrientTable[2], g.OrientPiles[2]
+	for _, c := range row {
+		if c != nil && c.Ability == engine.AbilitySacrifice {
+			return c
+		}
+	}
+	for i, c := range pile {
+		if c.Ability == engine.AbilitySacrifice {
+			pile[i], row[0] = row[0], c
+			return c
+		}
+	}
+	t.Fatal("no sacrifice card")
+	return nil
+}
+
+// TestSacrificeMatchesEngine 推演献祭后的奖励、分数和卡牌数量与引擎买下后相同
+func TestSacrificeMatchesEngine(t *testing.T) {
+	tests := []struct {
+		name   string
+		double bool // 拥有的卡牌中有一张双倍卡牌
+		single int  // 拥有的普通卡牌数量
+	}{
+		{"three cards", false, 3},
+		{"a double card", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGame(t, 1, engine.Rules{Orient: true}, 2)
+			pid := g.ActivePlayerId
+			p := g.Players[pid]
+			card := placeSacrifice(t, g)
+			// 牌堆中的卡牌已登记，献祭时能够丢弃
+			for _, c := range g.Piles[0] {
+				if c.Color == card.Color && len(p.Cards[c.Color]) < tt.single {
+					p.Cards[c.Color] = append(p.Cards[c.Color], c)
+				}
+			}
+			for _, c := range g.OrientPiles[0] {
+				if tt.double && c.Color == card.Color && c.Ability == engine.AbilityDouble {
+					p.Cards[c.Color] = append(p.Cards[c.Color], c)
+				}
+			}
+			m := engine.Move{Type: engine.MoveBuy, Card: card.Uuid}
+			pos := newPosition(g, p)
+			pos.apply(g, m)
+			if err := g.ApplyMove(pid, m); err != nil {
+				t.Fatal(err)
+			}
+			after := newPosition(g, p)
+			for _, c := range engine.ColorList {
+				if pos.bonus[c] != after.bonus[c] {
+					t.Fatalf("got %d %s bonuses, want %d", pos.bonus[c], c, after.bonus[c])
+				}
+			}
+			if pos.points != after.points || pos.cards != after.cards {
+				t.Fatalf("got %d points and %d cards, want %d and %d", pos.points, pos.cards, after.points, after.cards)
+			}
+		})
+	}
+}
+
+// TestJoinWithoutBonuses 没有可以加入的颜色时不推演任何奖励
+func TestJoinWithoutBonuses(t *testing.T) {
+	g := newGame(t, 1, engine.Rules{Orient: true}, 2)
+	pos := newPosition(g, g.Players[g.ActivePlayerId])
+	pos.addCard(g, &engine.DevCard{Level: 1, Points: 1, Ability: engine.AbilityJoin}, "")
+	if n, ok := pos.bonus[""]; ok {
+		t.Fatalf("got %d bonuses without a color", n)
+	}
+	for _, c := range engine.ColorList {
+		if pos.bonus[c] != 0 {
+			t.Fatalf("got %d %s bonuses", pos.bonus[c], c)
+		}
+	}
+}
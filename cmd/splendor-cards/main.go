@@ -0,0 +1,277 @@
+// splendor-cards 检查卡牌定义文件，打印每个等级的统计，有问题时以非零状态退出
+//
+// 用法示例：
+//
+//	go run ./cmd/splendor-cards
+//	go run ./cmd/splendor-cards my-cards.json
+//	go run ./cmd/splendor-cards -file my-cards.json
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"splendor-go/engine"
+	"strings"
+)
+
+// LevelStat 一个等级中按颜色统计的卡牌，键为 ColorList 中的颜色
+type LevelStat struct {
+	Level  int
+	Cards  map[string]int // 该颜色卡牌的数量
+	Points map[string]int // 该颜色卡牌的分数之和
+	Cost   map[string]int // 该颜色卡牌的价格之和
+	Paid   map[string]int // 该等级所有卡牌对该颜色宝石的需求之和
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 检查参数指定的文件并返回退出状态，参数错误时为 2，文件有问题时为 1
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("splendor-cards", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	file := flags.String("file", engine.CardFile, "card definition file to check, can also be given as the only argument")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	explicit := false
+	flags.Visit(func(f *flag.Flag) {
+		explicit = explicit || f.Name == "file"
+	})
+	if flags.NArg() > 1 {
+		fmt.Fprintf(stderr, "splendor-cards: only one file can be checked, got %s\n", strings.Join(flags.Args(), " "))
+		return 2
+	} else if flags.NArg() == 1 && explicit {
+		fmt.Fprintf(stderr, "splendor-cards: give the file either with -file or as an argument, not both\n")
+		return 2
+	} else if flags.NArg() == 1 {
+		*file = flags.Arg(0)
+	}
+
+	// 与服务器使用同一个加载函数，格式和字段错误在这里报告
+	problems := make([]string, 0)
+	set, err := engine.LoadCardSet(*file)
+	if err != nil {
+		var joined interface{ Unwrap() []error }
+		if errors.As(err, &joined) {
+			for _, e := range joined.Unwrap() {
+				problems = append(problems, e.Error())
+			}
+		} else {
+			problems = append(problems, err.Error())
+		}
+		// 校验失败时仍然统计能读出的卡牌，一次报告所有问题
+		set = readCardSet(*file)
+	}
+
+	if set != nil {
+		stats := levelStats(set)
+		printStats(stdout, set, stats)
+		problems = append(problems, checkBalance(stats)...)
+		problems = append(problems, checkDuplicates(set)...)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(stderr, "\nsplendor-cards: %d problems found in %s:\n", len(problems), *file)
+		for _, p := range problems {
+			fmt.Fprintln(stderr, "  "+p)
+		}
+		return 1
+	}
+	fmt.Fprintln(stdout, "\nOK")
+	return 0
+}
+
+// readCardSet 不经校验读取卡牌定义，文件无法解析时返回 nil
+func readCardSet(path string) *engine.CardSet {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	set := new(engine.CardSet)
+	if json.Unmarshal(data, set) != nil {
+		return nil
+	}
+	return set
+}
+
+// levelStats 按等级和颜色统计卡牌
+func levelStats(set *engine.CardSet) []*LevelStat {
+	stats := make([]*LevelStat, engine.LevelNum)
+	for i := range stats {
+		stats[i] = &LevelStat{
+			Level:  i + 1,
+			Cards:  make(map[string]int),
+			Points: make(map[string]int),
+			Cost:   make(map[string]int),
+			Paid:   make(map[string]int),
+		}
+	}
+	for _, c := range set.Cards {
+		// 等级不正确的卡牌已由校验报告
+		if c.Level < 1 || c.Level > engine.LevelNum {
+			continue
+		}
+		s := stats[c.Level-1]
+		s.Cards[c.Color]++
+		s.Points[c.Color] += c.Points
+		for color, n := range c.Cost {
+			s.Cost[c.Color] += n
+			s.Paid[color] += n
+		}
+	}
+	return stats
+}
+
+// checkBalance 同一等级中每种颜色的卡牌数量、分数、价格和对宝石的需求应当相同
+func checkBalance(stats []*LevelStat) []string {
+	problems := make([]string, 0)
+	for _, s := range stats {
+		for _, check := range []struct {
+			name   string
+			counts map[string]int
+		}{
+			{"cards", s.Cards},
+			{"points", s.Points},
+			{"total cost", s.Cost},
+			{"gems required", s.Paid},
+		} {
+			if !balanced(check.counts) {
+				problems = append(problems, fmt.Sprintf("level %d: %s differ by color: %s",
+					s.Level, check.name, colorCounts(check.counts)))
+			}
+		}
+	}
+	return problems
+}
+
+// checkDuplicates 等级、颜色和价格都相同的卡牌，能力也相同的东方卡牌，以及要求相同的贵族和城市
+func checkDuplicates(set *engine.CardSet) []string {
+	problems := make([]string, 0)
+	cards := make(map[string]string)
+	for _, c := range set.Cards {
+		key := fmt.Sprintf("%d %s %s", c.Level, c.Color, costKey(c.Cost))
+		if prev, exists := cards[key]; exists {
+			problems = append(problems, fmt.Sprintf("card %s duplicates %s: level %d %s costing %s",
+				c.Id, prev, c.Level, c.Color, costKey(c.Cost)))
+		} else {
+			cards[key] = c.Id
+		}
+	}
+	orient := make(map[string]string)
+	for _, c := range set.Orient {
+		key := fmt.Sprintf("%d %s %s %s", c.Level, c.Color, c.Ability, costKey(c.Cost))
+		if prev, exists := orient[key]; exists {
+			problems = append(problems, fmt.Sprintf("orient card %s duplicates %s: level %d %s %s costing %s",
+				c.Id, prev, c.Level, c.Color, c.Ability, costKey(c.Cost)))
+		} else {
+			orient[key] = c.Id
+		}
+	}
+	nobles := make(map[string]string)
+	for _, n := range set.Nobles {
+		key := costKey(n.Cost)
+		if prev, exists := nobles[key]; exists {
+			problems = append(problems, fmt.Sprintf("noble %s duplicates %s: costing %s", n.Id, prev, key))
+		} else {
+			nobles[key] = n.Id
+		}
+	}
+	cities := make(map[string]string)
+	for _, c := range set.Cities {
+		key := fmt.Sprintf("%d %s", c.Points, costKey(c.Cost))
+		if prev, exists := cities[key]; exists {
+			problems = append(problems, fmt.Sprintf("city %s duplicates %s: %d points and %s",
+				c.Id, prev, c.Points, costKey(c.Cost)))
+		} else {
+			cities[key] = c.Id
+		}
+	}
+	return problems
+}
+
+func printStats(w io.Writer, set *engine.CardSet, stats []*LevelStat) {
+	for _, s := range stats {
+		total := 0
+		for _, n := range s.Cards {
+			total += n
+		}
+		fmt.Fprintf(w, "Level %d: %d cards\n", s.Level, total)
+		fmt.Fprintf(w, "  %-6s %6s %6s %6s %6s %6s\n", "color", "cards", "points", "cost", "avg", "needed")
+		for _, c := range engine.ColorList {
+			avg := 0.0
+			if s.Cards[c] > 0 {
+				avg = float64(s.Cost[c]) / float64(s.Cards[c])
+			}
+			fmt.Fprintf(w, "  %-6s %6d %6d %6d %6.1f %6d\n", c, s.Cards[c], s.Points[c], s.Cost[c], avg, s.Paid[c])
+		}
+	}
+	needed := make(map[string]int)
+	for _, n := range set.Nobles {
+		for c, v := range n.Cost {
+			needed[c] += v
+		}
+	}
+	fmt.Fprintf(w, "Nobles: %d, cards needed: %s\n", len(set.Nobles), colorCounts(needed))
+	if len(set.Cities) > 0 {
+		needed = make(map[string]int)
+		minPoints, maxPoints := set.Cities[0].Points, set.Cities[0].Points
+		for _, c := range set.Cities {
+			for color, v := range c.Cost {
+				needed[color] += v
+			}
+			minPoints, maxPoints = min(minPoints, c.Points), max(maxPoints, c.Points)
+		}
+		fmt.Fprintf(w, "Cities: %d, points %d to %d, cards needed: %s\n", len(set.Cities), minPoints, maxPoints, colorCounts(needed))
+	}
+	if len(set.Orient) > 0 {
+		levels := make([]int, engine.LevelNum)
+		abilities := make(map[string]int)
+		for _, c := range set.Orient {
+			if c.Level >= 1 && c.Level <= engine.LevelNum {
+				levels[c.Level-1]++
+			}
+			abilities[c.Ability]++
+		}
+		parts := make([]string, 0, len(engine.Abilities))
+		for _, a := range engine.Abilities {
+			parts = append(parts, fmt.Sprintf("%s=%d", a, abilities[a]))
+		}
+		fmt.Fprintf(w, "Orient: %d cards, by level %v, abilities: %s\n", len(set.Orient), levels, strings.Join(parts, " "))
+	}
+}
+
+func balanced(counts map[string]int) bool {
+	for _, c := range engine.ColorList {
+		if counts[c] != counts[engine.ColorList[0]] {
+			return false
+		}
+	}
+	return true
+}
+
+// colorCounts 按 ColorList 的顺序列出每种颜色的数量，如 "W=8 B=8 G=7 R=8 K=8"
+func colorCounts(counts map[string]int) string {
+	parts := make([]string, 0, len(engine.ColorList))
+	for _, c := range engine.ColorList {
+		parts = append(parts, fmt.Sprintf("%s=%d", c, counts[c]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// costKey 与顺序无关的价格表示，省略为零的颜色
+func costKey(cost map[string]int) string {
+	parts := make([]string, 0, len(cost))
+	for c, n := range cost {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%s%d", c, n))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "+")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"splendor-go/engine"
+	"strings"
+	"testing"
+)
+
+const cardFile = "../../resources/cards.json"
+
+// brokenCardFile 在标准卡牌的基础上制造三个问题：重复的 id、颜色不平衡的等级和卡牌不够摆满桌面的等级
+func brokenCardFile(t *testing.T) string {
+	t.Helper()
+	set := readCardSet(cardFile)
+	if set == nil {
+		t.Fatalf("can't read %s", cardFile)
+	}
+	cards := make([]engine.CardDef, 0)
+	level1, level3 := 0, 0
+	firstId := ""
+	for _, c := range set.Cards {
+		if c.Level == 3 {
+			if level3++; level3 > 2 {
+				continue
+			}
+		} else if c.Level == 1 {
+			// 第一张 1 级卡牌改为另一种颜色，第二张使用第一张的 id
+			if level1++; level1 == 1 {
+				c.Color = engine.ColorList[(indexOf(c.Color)+1)%len(engine.ColorList)]
+				firstId = c.Id
+			} else if level1 == 2 {
+				c.Id = firstId
+			}
+		}
+		cards = append(cards, c)
+	}
+	set.Cards = cards
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cards.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func indexOf(color string) int {
+	for i, c := range engine.ColorList {
+		if c == color {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRun(t *testing.T) {
+	broken := brokenCardFile(t)
+	tests := []struct {
+		name     string
+		args     []string
+		code     int
+		messages []string // 输出中应当包含的内容
+	}{
+		{"valid file", []string{"-file", cardFile}, 0, []string{"OK"}},
+		{"file as an argument", []string{cardFile}, 0, []string{"OK"}},
+		{"every problem", []string{broken}, 1, []string{
+			"is already used by",
+			"level 1: cards differ by color",
+			"cards level 3 has 2 cards, at least 4 are needed",
+		}},
+		{"missing file", []string{"nope.json"}, 1, []string{"nope.json"}},
+		{"two files", []string{cardFile, broken}, 2, []string{"only one file"}},
+		{"flag and argument", []string{"-file", cardFile, broken}, 2, []string{"not both"}},
+		{"unknown flag", []string{"-level", "1"}, 2, []string{"flag provided but not defined"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, &stdout, &stderr)
+			output := stdout.String() + stderr.String()
+			if code != tt.code {
+				t.Fatalf("got exit status %d, want %d:\n%s", code, tt.code, output)
+			}
+			for _, msg := range tt.messages {
+				if !strings.Contains(output, msg) {
+					t.Fatalf("output doesn't mention %q:\n%s", msg, output)
+				}
+			}
+		})
+	}
+}
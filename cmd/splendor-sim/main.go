@@ -0,0 +1,432 @@
+// splendor-sim 不启动服务器，直接用规则引擎进行大量机器人对局并统计结果
+//
+// 用法示例：
+//
+//	go run ./cmd/splendor-sim -games 2000 -seed 1 -bots medium,hard -out result.csv
+//	go run ./cmd/splendor-sim -games 200 -bots hard,mcts:300 -out result.json
+//	go run ./cmd/splendor-sim -bots medium,hard,hard -rules '{"win_points":21}'
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"splendor-go/bot"
+	"splendor-go/engine"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GameResult 一局模拟的结果，座位按加入顺序编号
+type GameResult struct {
+	Game   int      `json:"game"`
+	Seed   int64    `json:"seed"`
+	Bots   []string `json:"bots"`
+	Begin  int      `json:"begin"`  // 先手座位
+	Winner int      `json:"winner"` // 获胜座位，未结束或平局时为 -1
+	Tied   bool     `json:"tied,omitempty"`
+	Turns  int      `json:"turns"` // 所有玩家的回合数之和
+	Rounds int      `json:"rounds"`
+	Points []int    `json:"points"`
+	Cards  []int    `json:"cards"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Rate 次数和比例
+type Rate struct {
+	Games int     `json:"games"`
+	Wins  int     `json:"wins"`
+	Rate  float64 `json:"rate"`
+}
+
+// Summary 所有对局的统计
+type Summary struct {
+	Games        int             `json:"games"`
+	Finished     int             `json:"finished"`
+	Ties         int             `json:"ties"`
+	Failed       int             `json:"failed"`
+	Seats        []Rate          `json:"seats"`  // 按座位的胜率
+	Orders       []Rate          `json:"orders"` // 按行动顺序的胜率，0 为先手
+	Bots         map[string]Rate `json:"bots"`
+	AvgTurns     float64         `json:"avg_turns"`
+	AvgRounds    float64         `json:"avg_rounds"`
+	MinRounds    int             `json:"min_rounds"`
+	MaxRounds    int             `json:"max_rounds"`
+	AvgPoints    float64         `json:"avg_points"`
+	AvgWinPoints float64         `json:"avg_win_points"`
+	Points       map[int]int     `json:"points"`     // 所有玩家最终分数的分布
+	WinPoints    map[int]int     `json:"win_points"` // 获胜者最终分数的分布
+	Elapsed      string          `json:"elapsed"`
+}
+
+func main() {
+	games := flag.Int("games", 1000, "number of games to simulate")
+	seed := flag.Int64("seed", 1, "seed of the first game, game i uses seed+i")
+	bots := flag.String("bots", "medium,hard", "comma separated bot levels by seat, e.g. easy,hard or mcts:500:1s")
+	rotate := flag.Bool("rotate", true, "rotate the bots across seats game by game")
+	workers := flag.Int("workers", runtime.NumCPU(), "games played in parallel")
+	maxTurns := flag.Int("maxturns", 400, "stop a game as unfinished after this many turns")
+	out := flag.String("out", "", "write per-game results to a .csv or .json file")
+	dir := flag.String("dir", ".", "directory containing resources/cards.json")
+	rulesStr := flag.String("rules", "", `room rules as JSON, e.g. {"win_points":21}`)
+	flag.Parse()
+
+	// 输出文件相对于调用时的目录，需要在切换目录前确定
+	if *out != "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			fail(err)
+		}
+		*out = abs
+	}
+	// 规则的校验可能需要加载卡牌，先切换到卡牌所在的目录
+	if err := os.Chdir(*dir); err != nil {
+		fail(err)
+	}
+	var rules engine.Rules
+	if *rulesStr != "" {
+		if err := json.Unmarshal([]byte(*rulesStr), &rules); err != nil {
+			fail(fmt.Errorf("invalid rules: %v", err))
+		}
+	}
+	if err := rules.Validate(); err != nil {
+		fail(fmt.Errorf("invalid rules: %v", err))
+	}
+
+	configs, err := parseBots(*bots)
+	if err != nil {
+		fail(err)
+	}
+	if set, err := engine.DefaultCardSet(); err != nil {
+		fail(fmt.Errorf("failed to load cards, use -dir to point at the repository: %v", err))
+	} else if err := rules.CheckCardSet(set); err != nil {
+		fail(fmt.Errorf("invalid rules: %v", err))
+	}
+	if *games <= 0 || *workers <= 0 {
+		fail(errors.New("games and workers must be positive"))
+	}
+
+	start := time.Now()
+	results := make([]*GameResult, *games)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				seats := configs
+				if *rotate {
+					seats = rotated(configs, i)
+				}
+				results[i] = playGame(i, *seed+int64(i), rules, seats, *maxTurns)
+			}
+		}()
+	}
+	for i := 0; i < *games; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	summary := summarize(results, len(configs))
+	summary.Elapsed = time.Since(start).Round(time.Millisecond).String()
+	printSummary(summary)
+	if *out != "" {
+		if err := writeResults(*out, summary, results); err != nil {
+			fail(err)
+		}
+		fmt.Printf("Results written to %s\n", *out)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "splendor-sim:", err)
+	os.Exit(1)
+}
+
+// parseBots 解析每个座位的机器人，格式为 level[:playouts[:budget]]
+func parseBots(s string) ([]bot.Config, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 2 || len(parts) > engine.MaxPlayers {
+		return nil, fmt.Errorf("need 2 to %d bots, got %d", engine.MaxPlayers, len(parts))
+	}
+	configs := make([]bot.Config, len(parts))
+	for i, part := range parts {
+		fields := strings.Split(strings.TrimSpace(part), ":")
+		cfg := bot.Config{Level: fields[0]}
+		if len(fields) > 1 {
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid playouts in %q", part)
+			}
+			cfg.Playouts = n
+		}
+		if len(fields) > 2 {
+			d, err := time.ParseDuration(fields[2])
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid budget in %q", part)
+			}
+			cfg.Budget = d
+		}
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("invalid bot %q", part)
+		}
+		if _, err := bot.New(cfg); err != nil {
+			return nil, err
+		}
+		configs[i] = cfg
+	}
+	return configs, nil
+}
+
+// label 机器人在统计中的名称
+func label(cfg bot.Config) string {
+	s := cfg.Level
+	if cfg.Playouts > 0 {
+		s += ":" + strconv.Itoa(cfg.Playouts)
+	}
+	if cfg.Budget > 0 {
+		s += ":" + cfg.Budget.String()
+	}
+	return s
+}
+
+// rotated 第 i 局的座位安排，每个机器人轮流坐每个座位
+func rotated(configs []bot.Config, i int) []bot.Config {
+	n := len(configs)
+	seats := make([]bot.Config, n)
+	for s := range seats {
+		seats[s] = configs[(s+i)%n]
+	}
+	return seats
+}
+
+// playGame 用给定的种子进行一局，机器人的随机数也由种子决定
+func playGame(i int, seed int64, rules engine.Rules, configs []bot.Config, maxTurns int) *GameResult {
+	n := len(configs)
+	r := &GameResult{
+		Game:   i,
+		Seed:   seed,
+		Bots:   make([]string, n),
+		Winner: -1,
+		Points: make([]int, n),
+		Cards:  make([]int, n),
+	}
+	g := engine.NewGame(seed, rules)
+	players := make([]bot.Bot, n)
+	for s, cfg := range configs {
+		cfg.Seed = seed*int64(engine.MaxPlayers) + int64(s) + 1
+		players[s], _ = bot.New(cfg)
+		r.Bots[s] = label(cfg)
+		g.AddPlayer(fmt.Sprintf("Bot %d (%s)", s+1, r.Bots[s]))
+	}
+	if !g.StartGame() {
+		r.Error = "game could not start"
+		return r
+	}
+	r.Begin = g.BeginPlayerId
+	for g.State == engine.PlayingState && r.Turns < maxTurns {
+		pid := g.ActivePlayerId
+		m := players[pid].ChooseMove(g, pid)
+		if err := g.ApplyMove(pid, m); err != nil {
+			r.Error = fmt.Sprintf("turn %d: %s chose an illegal move: %v", r.Turns, r.Bots[pid], err)
+			break
+		}
+		r.Turns++
+	}
+	r.Rounds = (r.Turns + n - 1) / n
+	for s, p := range g.Players[:n] {
+		r.Points[s] = p.Points
+		for _, cards := range p.Cards {
+			r.Cards[s] += len(cards)
+		}
+	}
+	if g.State == engine.EndedState && g.Winner != nil {
+		r.Winner = g.Winner.Id
+	} else if g.State == engine.EndedState {
+		r.Tied = true
+	}
+	return r
+}
+
+// summarize 汇总结果，未结束的对局只计入局数
+func summarize(results []*GameResult, n int) *Summary {
+	s := &Summary{
+		Games:     len(results),
+		Seats:     make([]Rate, n),
+		Orders:    make([]Rate, n),
+		Bots:      make(map[string]Rate),
+		Points:    make(map[int]int),
+		WinPoints: make(map[int]int),
+	}
+	var turns, rounds, points, winPoints int
+	for _, r := range results {
+		if r.Error != "" {
+			s.Failed++
+		}
+		for seat, name := range r.Bots {
+			rate := s.Bots[name]
+			rate.Games++
+			if seat == r.Winner {
+				rate.Wins++
+			}
+			s.Bots[name] = rate
+			s.Seats[seat].Games++
+			s.Orders[(seat-r.Begin+n)%n].Games++
+		}
+		if r.Winner < 0 && !r.Tied {
+			continue
+		}
+		s.Finished++
+		turns += r.Turns
+		rounds += r.Rounds
+		if s.MinRounds == 0 || r.Rounds < s.MinRounds {
+			s.MinRounds = r.Rounds
+		}
+		s.MaxRounds = max(s.MaxRounds, r.Rounds)
+		for _, p := range r.Points {
+			s.Points[p]++
+			points += p
+		}
+		// 平局不计入任何座位的胜场
+		if r.Tied {
+			s.Ties++
+			continue
+		}
+		s.Seats[r.Winner].Wins++
+		s.Orders[(r.Winner-r.Begin+n)%n].Wins++
+		s.WinPoints[r.Points[r.Winner]]++
+		winPoints += r.Points[r.Winner]
+	}
+	for i := range s.Seats {
+		s.Seats[i].Rate = ratio(s.Seats[i].Wins, s.Seats[i].Games)
+		s.Orders[i].Rate = ratio(s.Orders[i].Wins, s.Orders[i].Games)
+	}
+	for name, rate := range s.Bots {
+		rate.Rate = ratio(rate.Wins, rate.Games)
+		s.Bots[name] = rate
+	}
+	if s.Finished > 0 {
+		s.AvgTurns = float64(turns) / float64(s.Finished)
+		s.AvgRounds = float64(rounds) / float64(s.Finished)
+		s.AvgPoints = float64(points) / float64(s.Finished*n)
+	}
+	if s.Finished > s.Ties {
+		s.AvgWinPoints = float64(winPoints) / float64(s.Finished-s.Ties)
+	}
+	return s
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func printSummary(s *Summary) {
+	fmt.Printf("Games: %d, finished: %d, ties: %d, failed: %d, elapsed: %s\n", s.Games, s.Finished, s.Ties, s.Failed, s.Elapsed)
+	fmt.Printf("Turns: avg %.1f, rounds: avg %.1f (min %d, max %d)\n", s.AvgTurns, s.AvgRounds, s.MinRounds, s.MaxRounds)
+	fmt.Printf("Points: avg %.2f, winner avg %.2f\n", s.AvgPoints, s.AvgWinPoints)
+	fmt.Println("\nWin rate by bot:")
+	names := make([]string, 0, len(s.Bots))
+	for name := range s.Bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r := s.Bots[name]
+		fmt.Printf("  %-16s %6d / %-6d %6.1f%%\n", name, r.Wins, r.Games, 100*r.Rate)
+	}
+	fmt.Println("\nWin rate by seat:")
+	for i, r := range s.Seats {
+		fmt.Printf("  seat %-11d %6d / %-6d %6.1f%%\n", i, r.Wins, r.Games, 100*r.Rate)
+	}
+	fmt.Println("\nWin rate by turn order (BeginPlayerId moves first):")
+	for i, r := range s.Orders {
+		fmt.Printf("  %-16s %6d / %-6d %6.1f%%\n", ordinal(i+1), r.Wins, r.Games, 100*r.Rate)
+	}
+	fmt.Println("\nFinal points (all players | winners):")
+	keys := make([]int, 0, len(s.Points))
+	for p := range s.Points {
+		keys = append(keys, p)
+	}
+	sort.Ints(keys)
+	for _, p := range keys {
+		fmt.Printf("  %3d %8d | %-8d\n", p, s.Points[p], s.WinPoints[p])
+	}
+}
+
+func ordinal(n int) string {
+	switch n {
+	case 1:
+		return "1st"
+	case 2:
+		return "2nd"
+	case 3:
+		return "3rd"
+	}
+	return strconv.Itoa(n) + "th"
+}
+
+// writeResults 按扩展名把每局结果写入 CSV 或 JSON，JSON 同时包含统计
+func writeResults(path string, s *Summary, results []*GameResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"summary": s, "games": results})
+	case ".csv":
+		return writeCSV(f, results)
+	}
+	return fmt.Errorf("unsupported output format: %s", path)
+}
+
+func writeCSV(f *os.File, results []*GameResult) error {
+	w := csv.NewWriter(f)
+	header := []string{"game", "seed", "begin", "winner", "winner_bot", "tied", "turns", "rounds"}
+	n := 0
+	if len(results) > 0 {
+		n = len(results[0].Bots)
+	}
+	for i := 0; i < n; i++ {
+		header = append(header, fmt.Sprintf("bot%d", i), fmt.Sprintf("points%d", i), fmt.Sprintf("cards%d", i))
+	}
+	header = append(header, "error")
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for _, r := range results {
+		winnerBot := ""
+		if r.Winner >= 0 {
+			winnerBot = r.Bots[r.Winner]
+		}
+		row := []string{
+			strconv.Itoa(r.Game), strconv.FormatInt(r.Seed, 10), strconv.Itoa(r.Begin),
+			strconv.Itoa(r.Winner), winnerBot, strconv.FormatBool(r.Tied), strconv.Itoa(r.Turns), strconv.Itoa(r.Rounds),
+		}
+		for i := 0; i < n; i++ {
+			row = append(row, r.Bots[i], strconv.Itoa(r.Points[i]), strconv.Itoa(r.Cards[i]))
+		}
+		row = append(row, r.Error)
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,64 @@
+package engine
+
+// ActionType 操作类型
+type ActionType string
+
+const (
+	ActionTake       ActionType = "take"
+	ActionDiscard    ActionType = "discard"
+	ActionBuy        ActionType = "buy"
+	ActionReserve    ActionType = "reserve"
+	ActionNobleVisit ActionType = "noble_visit"
+	ActionChoose     ActionType = "choose"
+	ActionPostGem    ActionType = "post_gem"
+	ActionStronghold ActionType = "stronghold"
+	ActionRaze       ActionType = "raze"
+	ActionNext       ActionType = "next"
+)
+
+// Action 玩家的一次操作，Target 为颜色或卡牌、贵族的 UUID，choose 的 Target 为能力的选项
+type Action struct {
+	Type   ActionType
+	Target string
+}
+
+// Result 操作结果，Error 非空表示操作失败，Nobles 非空表示需要玩家选择贵族
+// Ability 非空表示需要玩家为东方卡牌的能力或前哨站的宝石从 Options 中选择一项
+type Result struct {
+	Error   string
+	Nobles  []string
+	Ability string
+	Options []string
+}
+
+// Act 以玩家 pid 的身份执行操作，返回 nil 表示成功
+func (g *Game) Act(pid int, a Action) *Result {
+	if g.State != PlayingState {
+		return &Result{Error: "The game is not in progress"}
+	} else if pid != g.ActivePlayerId {
+		return &Result{Error: "Now is not your turn"}
+	}
+	switch a.Type {
+	case ActionTake:
+		return g.Take(a.Target)
+	case ActionDiscard:
+		return g.Discard(a.Target)
+	case ActionBuy:
+		return g.Buy(a.Target)
+	case ActionReserve:
+		return g.Reserve(a.Target)
+	case ActionNobleVisit:
+		return g.VisitNobleActively(a.Target)
+	case ActionChoose:
+		return g.Choose(a.Target)
+	case ActionPostGem:
+		return g.TakePostGem(a.Target)
+	case ActionStronghold:
+		return g.PlaceStronghold(a.Target)
+	case ActionRaze:
+		return g.RazeStronghold(a.Target)
+	case ActionNext:
+		return g.NextTurn()
+	}
+	return &Result{Error: "Invalid action"}
+}
@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"math/rand"
+	"strings"
+)
+
+const (
+	MaxPlayers   = 4
+	NoblePoints  = 3
+	MaxGems      = 10
+	MaxReserve   = 3
+	TotalGolds   = 5
+	WinPoints    = 15
+	LevelNum     = 3
+	TableSize    = 4
+	CityNum      = 3
+	WaitingState = "waiting"
+	PlayingState = "playing"
+	EndedState   = "ended"
+)
+
+var (
+	ColorList = []string{"W", "B", "G", "R", "K"}
+
+	ColorDict = map[string]string{
+		"W": "⚪",
+		"B": "🔵",
+		"G": "🟢",
+		"R": "🔴",
+		"K": "⚫",
+	}
+
+	GoldKey = "*"
+)
+
+type DevCard struct {
+	Uuid    string
+	Id      string
+	Level   int
+	Color   string
+	Points  int
+	Cost    map[string]int
+	Caption string
+	Orient  bool   // 东方扩展的卡牌
+	Ability string // 东方扩展卡牌的能力，见 Abilities
+}
+
+type Noble struct {
+	Uuid     string
+	Id       string
+	Sequence int
+	Cost     map[string]int
+	Caption  string
+}
+
+// City 城市扩展中的城市，分数达到 Points 并拥有 Cost 中的发展卡即可获得
+type City struct {
+	Uuid    string
+	Id      string
+	Points  int
+	Cost    map[string]int
+	Caption string
+}
+
+// Record 一条文字日志
+type Record struct {
+	Pid  int    `json:"pid"`
+	Msg  string `json:"msg"`
+	Time string `json:"time"`
+}
+
+// NewCards 按卡牌定义创建一局游戏的卡牌和贵族，UUID 由 rng 按定义的顺序生成
+func NewCards(set *CardSet, rng *rand.Rand) (piles [][]*DevCard, nobles []*Noble) {
+	piles = make([][]*DevCard, LevelNum)
+	for i := range piles {
+		piles[i] = make([]*DevCard, 0)
+	}
+	for _, def := range set.Cards {
+		piles[def.Level-1] = append(piles[def.Level-1], newDevCard(rng, def))
+	}
+	nobles = make([]*Noble, len(set.Nobles))
+	for i, def := range set.Nobles {
+		nobles[i] = newNoble(rng, i, def)
+	}
+	return
+}
+
+func newDevCard(rng *rand.Rand, def CardDef) *DevCard {
+	var pointStr string
+	if def.Points > 0 {
+		pointStr = fmt.Sprintf("+%d🔸", def.Points)
+	}
+	caption := fmt.Sprintf("(%s%s)[%s]", def.Color, pointStr, costLine(def.Cost))
+	if def.Ability != "" {
+		caption += " " + def.Ability
+	}
+	return &DevCard{
+		Uuid:    newUuid(rng),
+		Id:      def.Id,
+		Level:   def.Level,
+		Color:   def.Color,
+		Points:  def.Points,
+		Cost:    fullCost(def.Cost),
+		Caption: beautifyCaption(caption),
+		Ability: def.Ability,
+	}
+}
+
+func newNoble(rng *rand.Rand, seq int, def NobleDef) *Noble {
+	caption := fmt.Sprintf("(+%d🔸)[%s]", NoblePoints, beautifyCaption(costLine(def.Cost)))
+	return &Noble{
+		Uuid:     newUuid(rng),
+		Id:       def.Id,
+		Sequence: seq,
+		Cost:     fullCost(def.Cost),
+		Caption:  caption,
+	}
+}
+
+// NewCities 按定义创建城市，只在城市模式下调用，以免改变标准游戏的随机序列
+func NewCities(set *CardSet, rng *rand.Rand) []*City {
+	cities := make([]*City, len(set.Cities))
+	for i, def := range set.Cities {
+		caption := fmt.Sprintf("(%d🔸)[%s]", def.Points, beautifyCaption(costLine(def.Cost)))
+		cities[i] = &City{
+			Uuid:    newUuid(rng),
+			Id:      def.Id,
+			Points:  def.Points,
+			Cost:    fullCost(def.Cost),
+			Caption: caption,
+		}
+	}
+	return cities
+}
+
+// fullCost 补全没有出现的颜色
+func fullCost(cost map[string]int) map[string]int {
+	full := make(map[string]int)
+	for _, c := range ColorList {
+		full[c] = cost[c]
+	}
+	return full
+}
+
+// costLine 按颜色顺序把花费写成 "2R1K" 的形式
+func costLine(cost map[string]int) string {
+	line := ""
+	for _, c := range ColorList {
+		if cost[c] > 0 {
+			line += fmt.Sprintf("%d%s", cost[c], c)
+		}
+	}
+	return line
+}
+
+// newUuid 由 rng 生成 UUID，使相同种子的游戏拥有相同的卡牌 UUID
+func newUuid(rng *rand.Rand) string {
+	id, err := uuid.NewRandomFromReader(rng)
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+func beautifyCaption(str string) string {
+	for _, c := range ColorList {
+		str = strings.ReplaceAll(str, c, ColorDict[c])
+	}
+	return str
+}
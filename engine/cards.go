@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+var (
+	// CardFile 游戏使用的卡牌定义文件
+	CardFile = "resources/cards.json"
+
+	defaultCards    *CardSet
+	defaultCardsErr error
+	defaultCardOnce sync.Once
+)
+
+// CardDef 一张发展卡的定义
+type CardDef struct {
+	Id     string         `json:"id"`
+	Level  int            `json:"level"`
+	Color  string         `json:"color"`
+	Points int            `json:"points"`
+	Cost   map[string]int `json:"cost"`
+	// Ability 东方扩展卡牌的能力，只能用于 Orient 中的卡牌，join 卡牌没有颜色
+	Ability string `json:"ability,omitempty"`
+}
+
+// NobleDef 一个贵族的定义，贵族的分数固定为 NoblePoints
+type NobleDef struct {
+	Id   string         `json:"id"`
+	Cost map[string]int `json:"cost"`
+}
+
+// CityDef 城市扩展中一个城市的定义，Points 为获得城市需要的分数
+type CityDef struct {
+	Id     string         `json:"id"`
+	Points int            `json:"points"`
+	Cost   map[string]int `json:"cost"`
+}
+
+// CardSet 一套卡牌和贵族的定义，文件中的顺序决定了卡牌 UUID 的生成顺序，不应随意调整
+type CardSet struct {
+	Cards  []CardDef  `json:"cards"`
+	Nobles []NobleDef `json:"nobles"`
+	Cities []CityDef  `json:"cities,omitempty"`
+	Orient []CardDef  `json:"orient,omitempty"`
+	// Hash 卡牌定义内容的哈希，记录在 setup 事件中，回放时确认使用的是同一套卡牌
+	Hash string `json:"-"`
+}
+
+// DefaultCardSet 返回 CardFile 中的卡牌定义，只在第一次调用时加载
+func DefaultCardSet() (*CardSet, error) {
+	defaultCardOnce.Do(func() {
+		defaultCards, defaultCardsErr = LoadCardSet(CardFile)
+	})
+	return defaultCards, defaultCardsErr
+}
+
+// LoadCardSet 从文件加载并校验卡牌定义
+func LoadCardSet(path string) (*CardSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ParseCardSet(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseCardSet 解析并校验卡牌定义，不认识的字段视为错误
+func ParseCardSet(data []byte) (*CardSet, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	s := new(CardSet)
+	if err := dec.Decode(s); err != nil {
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) {
+			line := bytes.Count(data[:syntax.Offset], []byte("\n")) + 1
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		return nil, err
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	// 以重新编码的内容计算哈希，不受空白和字段顺序影响
+	canonical, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+	s.Hash = hex.EncodeToString(sum[:])
+	return s, nil
+}
+
+// Validate 检查卡牌定义是否能用于游戏，返回所有问题
+func (s *CardSet) Validate() error {
+	problems := make([]error, 0)
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+	ids := make(map[string]string)
+	checkId := func(where, id string) {
+		if id == "" {
+			fail("%s: missing id", where)
+		} else if prev, exists := ids[id]; exists {
+			fail("%s: id %q is already used by %s", where, id, prev)
+		} else {
+			ids[id] = where
+		}
+	}
+	checkCost := func(where string, cost map[string]int, optional bool) {
+		total := 0
+		for c, n := range cost {
+			if !isColor(c) {
+				fail("%s: unknown color %q in cost", where, c)
+			} else if n < 0 {
+				fail("%s: negative cost %d for %s", where, n, c)
+			}
+			total += n
+		}
+		if total == 0 && !optional {
+			fail("%s: cost is empty", where)
+		}
+	}
+	checkCards := func(list string, cards []CardDef, orient bool) {
+		levels := make([]int, LevelNum)
+		for i, c := range cards {
+			where := fmt.Sprintf("%s[%d]", list, i)
+			if c.Id != "" {
+				where += fmt.Sprintf(" (%s)", c.Id)
+			}
+			checkId(where, c.Id)
+			if c.Level < 1 || c.Level > LevelNum {
+				fail("%s: level must be between 1 and %d, got %d", where, LevelNum, c.Level)
+			} else {
+				levels[c.Level-1]++
+			}
+			if c.Ability == AbilityJoin {
+				if c.Color != "" {
+					fail("%s: join cards take their color when bought and can't have one", where)
+				}
+			} else if !isColor(c.Color) {
+				fail("%s: unknown color %q, expected one of %v", where, c.Color, ColorList)
+			}
+			if c.Points < 0 {
+				fail("%s: negative points %d", where, c.Points)
+			}
+			if c.Ability != "" && !orient {
+				fail("%s: only orient cards can have an ability", where)
+			} else if c.Ability != "" && !isAbility(c.Ability) {
+				fail("%s: unknown ability %q, expected one of %v", where, c.Ability, Abilities)
+			} else if c.Ability == AbilityFreeCard && c.Level == 1 {
+				fail("%s: level 1 cards can't take a free card", where)
+			}
+			// 献祭卡牌以丢弃奖励代替宝石，可以没有价格
+			checkCost(where, c.Cost, c.Ability == AbilitySacrifice)
+		}
+		// 至少能摆满标准规则的桌面，更大的桌面由 Rules.CheckCardSet 检查
+		size := TableSize
+		if orient {
+			size = OrientTableSize
+		}
+		for l, n := range levels {
+			if n < size && (!orient || len(cards) > 0) {
+				fail("%s level %d has %d cards, at least %d are needed to fill the table", list, l+1, n, size)
+			}
+		}
+	}
+	checkCards("cards", s.Cards, false)
+	checkCards("orient", s.Orient, true)
+	for i, n := range s.Nobles {
+		where := fmt.Sprintf("nobles[%d]", i)
+		if n.Id != "" {
+			where += fmt.Sprintf(" (%s)", n.Id)
+		}
+		checkId(where, n.Id)
+		checkCost(where, n.Cost, false)
+	}
+	if len(s.Nobles) < MaxPlayers+1 {
+		fail("%d nobles defined, at least %d are needed for %d players", len(s.Nobles), MaxPlayers+1, MaxPlayers)
+	}
+	// 城市是可选的，定义了城市时需要足够摆满桌面
+	for i, c := range s.Cities {
+		where := fmt.Sprintf("cities[%d]", i)
+		if c.Id != "" {
+			where += fmt.Sprintf(" (%s)", c.Id)
+		}
+		checkId(where, c.Id)
+		if c.Points <= 0 {
+			fail("%s: points must be positive, got %d", where, c.Points)
+		}
+		checkCost(where, c.Cost, false)
+	}
+	if len(s.Cities) > 0 && len(s.Cities) < CityNum {
+		fail("%d cities defined, at least %d are needed", len(s.Cities), CityNum)
+	}
+	return errors.Join(problems...)
+}
+
+// countLevel 某个等级的发展卡数量，不包括东方扩展的卡牌
+func (s *CardSet) countLevel(level int) int {
+	n := 0
+	for _, c := range s.Cards {
+		if c.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+func isColor(c string) bool {
+	for _, color := range ColorList {
+		if c == color {
+			return true
+		}
+	}
+	return false
+}
+
+func isAbility(a string) bool {
+	for _, ability := range Abilities {
+		if a == ability {
+			return true
+		}
+	}
+	return false
+}
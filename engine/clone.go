@@ -0,0 +1,118 @@
+package engine
+
+import "math/rand"
+
+// Clone 深拷贝游戏，用于机器人推演，对副本的操作不影响原游戏
+// 卡牌和贵族不会改变，由副本共享；副本的事件在原事件之后追加，不会写入原事件的底层数组
+func (g *Game) Clone() *Game {
+	c := &Game{
+		Players:        make([]*Player, len(g.Players)),
+		PlayerNum:      g.PlayerNum,
+		State:          g.State,
+		ActivePlayerId: g.ActivePlayerId,
+		SpectatorIndex: g.SpectatorIndex,
+		Gems:           copyCounts(g.Gems),
+		Golds:          g.Golds,
+		Table:          copyRows(g.Table),
+		Piles:          copyRows(g.Piles),
+		OrientTable:    copyRows(g.OrientTable),
+		OrientPiles:    copyRows(g.OrientPiles),
+		Strongholds:    make(map[string][]int, len(g.Strongholds)),
+		CardMap:        g.CardMap,
+		Nobles:         append([]*Noble{}, g.Nobles...),
+		AllNobles:      g.AllNobles,
+		Cities:         g.Cities,
+		AllCities:      g.AllCities,
+		LastRound:      g.LastRound,
+		Standings:      append([]Standing(nil), g.Standings...),
+		Events:         g.Events[:len(g.Events):len(g.Events)],
+		UpdatedTime:    g.UpdatedTime,
+		BeginPlayerId:  g.BeginPlayerId,
+		Seed:           g.Seed,
+		Rules:          g.Rules,
+		// 原游戏的 rng 只在开局前使用，副本另外创建，避免改变原游戏的随机序列
+		rng: rand.New(rand.NewSource(g.Seed + int64(len(g.Events)))),
+	}
+	for uuid, pids := range g.Strongholds {
+		c.Strongholds[uuid] = append([]int{}, pids...)
+	}
+	for i, p := range g.Players {
+		if p == nil {
+			continue
+		}
+		cp := *p
+		cp.Game = c
+		cp.Gems = copyCounts(p.Gems)
+		cp.Cards = make(map[string][]*DevCard, len(p.Cards))
+		for color, cards := range p.Cards {
+			cp.Cards[color] = append([]*DevCard{}, cards...)
+		}
+		cp.Reserved = append([]*DevCard{}, p.Reserved...)
+		cp.Nobles = append([]*Noble{}, p.Nobles...)
+		cp.ReservedNobles = append([]*Noble{}, p.ReservedNobles...)
+		cp.Posts = append([]int{}, p.Posts...)
+		cp.Taken = copyCounts(p.Taken)
+		c.Players[i] = &cp
+		if g.Winner == p {
+			c.Winner = &cp
+		}
+	}
+	return c
+}
+
+// Determinize 从玩家 pid 的视角复制游戏，并重新洗混该玩家看不到的卡牌
+// 看不到的卡牌包括牌堆和其他玩家从牌堆预定的卡牌，同一等级的卡牌之间互相交换
+func (g *Game) Determinize(pid int, rng *rand.Rand) *Game {
+	c := g.Clone()
+	hidden := make(map[string]bool)
+	for _, e := range g.Events {
+		if e.Type == EventReserve && e.Level > 0 && e.Pid != pid {
+			hidden[e.Card] = true
+		}
+	}
+	for level := range c.Piles {
+		// 收集该等级看不到的卡牌和它们所在的位置
+		pool := append([]*DevCard{}, c.Piles[level]...)
+		slots := make([]**DevCard, 0)
+		for _, p := range c.Players[:c.PlayerNum] {
+			for i, card := range p.Reserved {
+				if hidden[card.Uuid] && card.Level == level+1 {
+					pool = append(pool, card)
+					slots = append(slots, &p.Reserved[i])
+				}
+			}
+		}
+		shuffleCards(rng, pool)
+		for i, slot := range slots {
+			*slot = pool[i]
+		}
+		c.Piles[level] = pool[len(slots):]
+	}
+	// 东方扩展的牌堆不能从牌堆预定，直接洗混
+	for _, pile := range c.OrientPiles {
+		shuffleCards(rng, pile)
+	}
+	return c
+}
+
+func copyRows(rows [][]*DevCard) [][]*DevCard {
+	if rows == nil {
+		return nil
+	}
+	c := make([][]*DevCard, len(rows))
+	for i, row := range rows {
+		c[i] = append([]*DevCard{}, row...)
+	}
+	return c
+}
+
+func copyCounts(m map[string]int) map[string]int {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]int, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
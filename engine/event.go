@@ -0,0 +1,577 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EventType 事件类型
+type EventType string
+
+const (
+	EventSetup      EventType = "setup"
+	EventJoin       EventType = "join"
+	EventSpectate   EventType = "spectate"
+	EventRename     EventType = "rename"
+	EventStart      EventType = "start"
+	EventDeal       EventType = "deal"
+	EventTake       EventType = "take"
+	EventDiscard    EventType = "discard"
+	EventBuy        EventType = "buy"
+	EventReserve    EventType = "reserve"
+	EventNobleVisit EventType = "noble_visit"
+	EventCityClaim  EventType = "city_claim"
+	EventSacrifice  EventType = "sacrifice"
+	EventAbility    EventType = "ability"
+	EventPostUnlock EventType = "outpost"
+	EventPostGem    EventType = "post_gem"
+	EventStronghold EventType = "stronghold"
+	EventRaze       EventType = "raze"
+	EventTurnEnd    EventType = "turn_end"
+)
+
+// Event 一次状态变化，游戏的全部状态都由事件序列决定
+type Event struct {
+	Type        EventType      `json:"type"`
+	Pid         int            `json:"pid"`
+	Time        time.Time      `json:"time"`
+	Seed        int64          `json:"seed,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Uuid        string         `json:"uuid,omitempty"`
+	Color       string         `json:"color,omitempty"`
+	Card        string         `json:"card,omitempty"`
+	Level       int            `json:"level,omitempty"`
+	Slot        int            `json:"slot,omitempty"`
+	Payment     map[string]int `json:"payment,omitempty"`
+	FromReserve bool           `json:"from_reserve,omitempty"`
+	Gold        int            `json:"gold,omitempty"`
+	Noble       string         `json:"noble,omitempty"`
+	City        string         `json:"city,omitempty"`
+	Target      string         `json:"target,omitempty"` // 东方卡牌能力的选择
+	Orient      bool           `json:"orient,omitempty"`
+	Post        int            `json:"post,omitempty"`
+	Rules       *Rules         `json:"rules,omitempty"`
+	CardHash    string         `json:"card_hash,omitempty"` // 卡牌定义的哈希，见 CardSet.Hash
+}
+
+// Replay 由事件序列重建游戏，传入 events[:n] 即可得到第 n 个事件之前的状态
+func Replay(events []Event) (*Game, error) {
+	if len(events) == 0 || events[0].Type != EventSetup {
+		return nil, errors.New("the first event must be setup")
+	}
+	// 卡牌定义改变后无法重建原来的牌堆，没有哈希的旧事件不检查
+	set, err := DefaultCardSet()
+	if err != nil {
+		return nil, err
+	} else if hash := events[0].CardHash; hash != "" && hash != set.Hash {
+		return nil, errors.New("the game was played with a different card set")
+	}
+	// 没有规则的旧事件使用标准规则
+	var rules Rules
+	if events[0].Rules != nil {
+		rules = *events[0].Rules
+	}
+	if err := rules.CheckCardSet(set); err != nil {
+		return nil, err
+	}
+	g := NewGame(events[0].Seed, rules)
+	g.Events[0] = events[0]
+	g.UpdatedTime = events[0].Time
+	for i, e := range events[1:] {
+		if err := g.commit(e); err != nil {
+			return nil, fmt.Errorf("event %d (%s): %w", i+1, e.Type, err)
+		}
+	}
+	return g, nil
+}
+
+// Records 由事件生成文字日志
+func (g *Game) Records() []Record {
+	records := make([]Record, 0)
+	names := make(map[int]string)
+	taken := make(map[string]int)
+	add := func(e Event, msg string) {
+		records = append(records, Record{
+			Pid:  e.Pid,
+			Msg:  msg,
+			Time: e.Time.Format("2006-01-02 15:04:05"),
+		})
+	}
+	// 本回合拿取的宝石在回合结束或访问贵族时合并为一条记录
+	flush := func(e Event) {
+		sum := valueSum(taken)
+		if sum == 0 {
+			return
+		}
+		msg := fmt.Sprintf("%s takes %d gems: ", names[e.Pid], sum)
+		for _, c := range ColorList {
+			if taken[c] > 0 {
+				msg += fmt.Sprintf("%d%s", taken[c], ColorDict[c])
+			}
+		}
+		taken = make(map[string]int)
+		add(e, msg)
+	}
+	for _, e := range g.Events {
+		switch e.Type {
+		case EventJoin, EventRename:
+			names[e.Pid] = e.Name
+		case EventTake:
+			taken[e.Color]++
+		case EventDiscard:
+			if taken[e.Color] > 0 {
+				taken[e.Color]--
+			} else if e.Color != GoldKey {
+				add(e, fmt.Sprintf("%s discards 1%s", names[e.Pid], ColorDict[e.Color]))
+			}
+		case EventBuy:
+			msg := fmt.Sprintf("%s buys", names[e.Pid])
+			if e.FromReserve {
+				msg += " reserved"
+			}
+			msg += fmt.Sprintf(": %s, paying ", g.CardMap[e.Card].Caption)
+			for _, c := range ColorList {
+				if e.Payment[c] > 0 {
+					msg += fmt.Sprintf("%d%s", e.Payment[c], ColorDict[c])
+				}
+			}
+			if e.Payment[GoldKey] > 0 {
+				msg += fmt.Sprintf("%d🟡", e.Payment[GoldKey])
+			}
+			if msg[len(msg)-1] == ' ' {
+				msg += "nothing"
+			}
+			add(e, msg)
+		case EventReserve:
+			var msg string
+			if e.Level > 0 {
+				msg = fmt.Sprintf("%s reserves a card of level %d", names[e.Pid], e.Level)
+			} else {
+				msg = fmt.Sprintf("%s reserves: %s", names[e.Pid], g.CardMap[e.Card].Caption)
+			}
+			if e.Gold > 0 {
+				msg += ", getting 1🟡"
+			}
+			add(e, msg)
+		case EventNobleVisit:
+			flush(e)
+			add(e, fmt.Sprintf("%s visits a noble: %s", names[e.Pid], g.findNoble(e.Noble).Caption))
+		case EventSacrifice:
+			add(e, fmt.Sprintf("%s sacrifices: %s", names[e.Pid], g.CardMap[e.Card].Caption))
+		case EventAbility:
+			card := g.CardMap[e.Card]
+			switch {
+			case e.Target == "":
+			case card.Ability == AbilityJoin:
+				add(e, fmt.Sprintf("%s joins %s to %s", names[e.Pid], card.Caption, ColorDict[e.Target]))
+			case card.Ability == AbilityReserveNoble:
+				add(e, fmt.Sprintf("%s reserves a noble: %s", names[e.Pid], g.findNoble(e.Target).Caption))
+			case card.Ability == AbilityFreeCard:
+				add(e, fmt.Sprintf("%s takes for free: %s", names[e.Pid], g.CardMap[e.Target].Caption))
+			}
+		case EventPostUnlock:
+			add(e, fmt.Sprintf("%s unlocks an outpost: %s", names[e.Pid], findPost(e.Post).Caption))
+		case EventPostGem:
+			add(e, fmt.Sprintf("%s takes 1%s from an outpost", names[e.Pid], ColorDict[e.Color]))
+		case EventStronghold:
+			add(e, fmt.Sprintf("%s places a stronghold on: %s", names[e.Pid], g.CardMap[e.Card].Caption))
+		case EventRaze:
+			add(e, fmt.Sprintf("%s razes a stronghold on: %s", names[e.Pid], g.CardMap[e.Card].Caption))
+		case EventCityClaim:
+			flush(e)
+			add(e, fmt.Sprintf("%s claims a city: %s", names[e.Pid], g.findCity(e.City).Caption))
+		case EventTurnEnd:
+			flush(e)
+		}
+	}
+	return records
+}
+
+// record 记录并执行一个新事件
+func (g *Game) record(e Event) {
+	e.Time = time.Now()
+	if err := g.commit(e); err != nil {
+		panic(fmt.Sprintf("invalid %s event: %v", e.Type, err))
+	}
+}
+
+// commit 执行事件并追加到事件序列
+func (g *Game) commit(e Event) error {
+	if err := g.apply(e); err != nil {
+		return err
+	}
+	g.Events = append(g.Events, e)
+	g.UpdatedTime = e.Time
+	return nil
+}
+
+// apply 根据事件修改状态，所有状态变化都必须经过这里
+func (g *Game) apply(e Event) error {
+	switch e.Type {
+	case EventSetup:
+		// 种子在 NewGame 中使用，这里只检查 setup 是否为第一个事件
+		if len(g.Events) > 0 {
+			return errors.New("setup must be the first event")
+		}
+	case EventJoin:
+		if e.Pid != g.PlayerNum || e.Pid >= MaxPlayers {
+			return errors.New("unexpected player id")
+		}
+		player := NewPlayer(g, e.Pid, e.Name)
+		player.Uuid = e.Uuid
+		// 由于 g.Players 至少有 4 个元素，所以不会越界
+		g.Players[e.Pid] = player
+		g.PlayerNum++
+		// 添加一个贵族
+		g.Nobles = g.AllNobles[:g.nobleCount()]
+	case EventSpectate:
+		if e.Pid != g.SpectatorIndex {
+			return errors.New("unexpected spectator id")
+		}
+		spectator := NewPlayer(g, e.Pid, fmt.Sprintf("Spec-%d", e.Pid))
+		spectator.Uuid = e.Uuid
+		g.Players = append(g.Players, spectator)
+		g.SpectatorIndex++
+	case EventRename:
+		p := g.playerOf(e.Pid)
+		if p == nil {
+			return errors.New("unknown player")
+		}
+		p.Name = e.Name
+	case EventStart:
+		if g.PlayerNum < 2 || g.State != WaitingState {
+			return errors.New("the game can't start")
+		}
+		g.applyStart()
+	case EventDeal:
+		return g.applyDeal(e)
+	case EventTake:
+		p := g.getActivePlayer()
+		if p == nil || p.Id != e.Pid || g.Gems[e.Color] == 0 {
+			return errors.New("invalid take")
+		}
+		g.Gems[e.Color]--
+		p.Gems[e.Color]++
+		p.Taken[e.Color]++
+		if p.TakenNum() == 3 || p.Taken[e.Color] == 2 {
+			p.Finished = true
+		}
+	case EventDiscard:
+		p := g.getActivePlayer()
+		if p == nil || p.Id != e.Pid {
+			return errors.New("not the active player")
+		}
+		if e.Color == GoldKey {
+			if p.Golds == 0 {
+				return errors.New("no gold to discard")
+			}
+			p.Golds--
+			g.Golds++
+			break
+		} else if p.Gems[e.Color] == 0 {
+			return errors.New("no gem to discard")
+		}
+		p.Gems[e.Color]--
+		g.Gems[e.Color]++
+		if p.Taken[e.Color] > 0 {
+			p.Taken[e.Color]--
+		}
+	case EventBuy:
+		return g.applyBuy(e)
+	case EventReserve:
+		return g.applyReserve(e)
+	case EventNobleVisit:
+		return g.applyNobleVisit(e)
+	case EventCityClaim:
+		return g.applyCityClaim(e)
+	case EventSacrifice:
+		return g.applySacrifice(e)
+	case EventAbility:
+		return g.applyAbility(e)
+	case EventPostUnlock:
+		return g.applyPostUnlock(e)
+	case EventPostGem:
+		return g.applyPostGem(e)
+	case EventStronghold:
+		return g.applyStronghold(e)
+	case EventRaze:
+		return g.applyRaze(e)
+	case EventTurnEnd:
+		return g.applyTurnEnd(e)
+	default:
+		return errors.New("unknown event type")
+	}
+	return nil
+}
+
+func (g *Game) applyStart() {
+	// 初始化所对应的宝石
+	num := g.Rules.GemSupply[g.PlayerNum-2]
+	for _, color := range ColorList {
+		g.Gems[color] = num
+	}
+	// 复制一份贵族，避免访问贵族时修改 AllNobles
+	g.Nobles = append([]*Noble{}, g.AllNobles[:g.nobleCount()]...)
+	// 洗牌，桌上的牌由之后的 deal 事件发出
+	for i := 0; i < 3; i++ {
+		shuffleCards(g.rng, g.Piles[i])
+	}
+	for i := range g.OrientPiles {
+		shuffleCards(g.rng, g.OrientPiles[i])
+	}
+	// 要塞模块中每个玩家的要塞
+	if g.Rules.Strongholds {
+		for _, p := range g.Players[:g.PlayerNum] {
+			p.Strongholds = StrongholdNum
+		}
+	}
+	// 修改状态
+	g.State = PlayingState
+	// 随机挑选一个玩家先手
+	g.BeginPlayerId = g.rng.Intn(g.PlayerNum)
+	g.ActivePlayerId = g.BeginPlayerId
+	g.getActivePlayer().StartTurn()
+}
+
+func (g *Game) applyDeal(e Event) error {
+	table, piles := g.Table, g.Piles
+	if e.Orient {
+		table, piles = g.OrientTable, g.OrientPiles
+	}
+	l := e.Level - 1
+	if l < 0 || l >= len(piles) || len(piles[l]) == 0 || piles[l][0].Uuid != e.Card {
+		return errors.New("card is not on top of the pile")
+	}
+	card := piles[l][0]
+	if e.Slot < len(table[l]) && table[l][e.Slot] == nil {
+		table[l][e.Slot] = card
+	} else if e.Slot == len(table[l]) {
+		table[l] = append(table[l], card)
+	} else {
+		return errors.New("slot is not empty")
+	}
+	piles[l] = piles[l][1:]
+	return nil
+}
+
+func (g *Game) applyBuy(e Event) error {
+	p := g.getActivePlayer()
+	card := g.CardMap[e.Card]
+	if p == nil || p.Id != e.Pid || card == nil {
+		return errors.New("invalid buy")
+	}
+	// 移除卡牌
+	if e.FromReserve {
+		if !p.removeReserved(card) {
+			return errors.New("card is not reserved")
+		}
+	} else if !g.removeFromTable(card) {
+		return errors.New("card is not on the table")
+	}
+	// 支付宝石和黄金
+	for c, n := range e.Payment {
+		if c == GoldKey {
+			p.Golds -= n
+			g.Golds += n
+		} else {
+			p.Gems[c] -= n
+			g.Gems[c] += n
+		}
+	}
+	// 添加卡牌并修改分数，join 卡牌在选择颜色后添加
+	if card.Ability != AbilityJoin {
+		p.Cards[card.Color] = append(p.Cards[card.Color], card)
+	}
+	p.Points += card.Points
+	p.Finished = true
+	if needsChoice(card.Ability) {
+		p.Pending = card
+	}
+	// 买卡前已解锁的前哨站提供一个宝石
+	if p.HasPost(PostGem) && len(p.postGemOptions()) > 0 {
+		p.PostGemDue = true
+	}
+	return nil
+}
+
+func (g *Game) applyReserve(e Event) error {
+	p := g.getActivePlayer()
+	card := g.CardMap[e.Card]
+	if p == nil || p.Id != e.Pid || card == nil {
+		return errors.New("invalid reserve")
+	}
+	if e.Level > 0 {
+		l := e.Level - 1
+		if len(g.Piles[l]) == 0 || g.Piles[l][0].Uuid != e.Card {
+			return errors.New("card is not on top of the pile")
+		}
+		g.Piles[l] = g.Piles[l][1:]
+	} else if !g.removeFromTable(card) {
+		return errors.New("card is not on the table")
+	}
+	p.Reserved = append(p.Reserved, card)
+	// 获取黄金
+	if e.Gold > 0 {
+		if g.Golds == 0 {
+			return errors.New("no gold left")
+		}
+		p.Golds++
+		g.Golds--
+	}
+	p.Finished = true
+	return nil
+}
+
+func (g *Game) applyNobleVisit(e Event) error {
+	p := g.playerOf(e.Pid)
+	if p == nil {
+		return errors.New("unknown player")
+	}
+	// 移除贵族，自己预定的贵族从预定中移除
+	var noble *Noble
+	for i, v := range g.Nobles {
+		if v.Uuid == e.Noble {
+			noble = v
+			g.Nobles = append(g.Nobles[:i], g.Nobles[i+1:]...)
+			break
+		}
+	}
+	for i, v := range p.ReservedNobles {
+		if noble == nil && v.Uuid == e.Noble {
+			noble = v
+			p.ReservedNobles = append(p.ReservedNobles[:i:i], p.ReservedNobles[i+1:]...)
+			break
+		}
+	}
+	if noble == nil {
+		return errors.New("noble is not available")
+	}
+	// 添加贵族
+	p.Nobles = append(p.Nobles, noble)
+	// 修改分数
+	p.Points += NoblePoints
+	// 标记已访问
+	p.Visited = true
+	return nil
+}
+
+func (g *Game) applyCityClaim(e Event) error {
+	p := g.playerOf(e.Pid)
+	if p == nil {
+		return errors.New("unknown player")
+	} else if p.City != nil {
+		return errors.New("player already has a city")
+	}
+	// 城市不会被拿走，其他玩家仍然可以获得同一个城市
+	city := g.findCity(e.City)
+	if city == nil {
+		return errors.New("city is not available")
+	}
+	p.City = city
+	// 获得城市后进入最后一轮
+	g.LastRound = true
+	return nil
+}
+
+func (g *Game) applyTurnEnd(e Event) error {
+	player := g.getActivePlayer()
+	if player == nil || player.Id != e.Pid {
+		return errors.New("not the active player")
+	}
+	player.Finished = true
+	// 检查是否触发最后一回合，城市模式下由获得城市触发
+	if !g.Rules.Cities && player.Points >= g.Rules.WinPoints {
+		g.LastRound = true
+	}
+	// 下一个玩家
+	g.ActivePlayerId = (g.ActivePlayerId + 1) % g.PlayerNum
+	// 如果已经结束
+	if g.LastRound && g.ActivePlayerId == g.BeginPlayerId {
+		g.State = EndedState
+		g.Standings = g.determineStandings()
+		// 平局时没有唯一的赢家
+		if winners := g.Winners(); len(winners) == 1 {
+			g.Winner = winners[0]
+		}
+		g.ActivePlayerId = -1
+	} else {
+		g.getActivePlayer().StartTurn()
+	}
+	return nil
+}
+
+// removeFromTable 从桌上移除卡牌，牌堆不为空时留下空位等待发牌
+func (g *Game) removeFromTable(card *DevCard) bool {
+	table, piles := g.Table, g.Piles
+	if card.Orient {
+		table, piles = g.OrientTable, g.OrientPiles
+	}
+	l := card.Level - 1
+	for i, c := range table[l] {
+		if c != nil && c.Uuid == card.Uuid {
+			g.releaseStrongholds(card)
+			if len(piles[l]) > 0 {
+				table[l][i] = nil
+			} else {
+				table[l] = append(table[l][:i], table[l][i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Game) playerOf(pid int) *Player {
+	if pid < 0 || pid >= len(g.Players) {
+		return nil
+	}
+	return g.Players[pid]
+}
+
+func (g *Game) findNoble(uuid string) *Noble {
+	for _, n := range g.AllNobles {
+		if n.Uuid == uuid {
+			return n
+		}
+	}
+	return nil
+}
+
+func (g *Game) findCity(uuid string) *City {
+	for _, c := range g.Cities {
+		if c.Uuid == uuid {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *Player) removeReserved(card *DevCard) bool {
+	for i, c := range p.Reserved {
+		if c.Uuid == card.Uuid {
+			p.Reserved = append(p.Reserved[:i], p.Reserved[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// MoveBoundaries 返回每一步结束时的事件数量，第 0 步为开局发牌之后，之后每个回合结束为一步
+func MoveBoundaries(events []Event) []int {
+	boundaries := make([]int, 0)
+	for i, e := range events {
+		switch e.Type {
+		case EventStart:
+			// 跳过开局发牌
+			j := i + 1
+			for j < len(events) && events[j].Type == EventDeal {
+				j++
+			}
+			boundaries = append(boundaries, j)
+		case EventTurnEnd:
+			boundaries = append(boundaries, i+1)
+		}
+	}
+	return boundaries
+}
@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+// fingerprint 游戏的完整状态，包括序列化时省略的回合内状态
+func fingerprint(t *testing.T, g *Game) string {
+	t.Helper()
+	type turn struct {
+		Taken      map[string]int
+		Visited    bool
+		Finished   bool
+		Pending    *DevCard
+		PostGemDue bool
+	}
+	turns := make([]turn, 0, len(g.Players))
+	for _, p := range g.Players {
+		if p != nil {
+			turns = append(turns, turn{p.Taken, p.Visited, p.Finished, p.Pending, p.PostGemDue})
+		}
+	}
+	data, err := json.Marshal(struct {
+		Game           *Game
+		Turns          []turn
+		PlayerNum      int
+		State          string
+		SpectatorIndex int
+		LastRound      bool
+		BeginPlayerId  int
+		Events         int
+	}{g, turns, g.PlayerNum, g.State, g.SpectatorIndex, g.LastRound, g.BeginPlayerId, len(g.Events)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// TestReplayMatchesLiveGame 逐个操作进行游戏，每次操作后由事件重建的游戏都应与进行中的游戏相同
+func TestReplayMatchesLiveGame(t *testing.T) {
+	variants := []struct {
+		name  string
+		rules Rules
+	}{
+		{"standard", Rules{}},
+		{"cities", Rules{Cities: true}},
+		{"orient", Rules{Orient: true}},
+		{"outposts and strongholds", Rules{Outposts: true, Strongholds: true}},
+	}
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			g := NewGame(7, v.rules)
+			snapshots := make(map[int]string)
+			snap := func() {
+				snapshots[len(g.Events)] = fingerprint(t, g)
+			}
+			snap()
+			for i := 0; i < 3; i++ {
+				g.AddPlayer("p")
+				snap()
+			}
+			g.AddSpectator()
+			snap()
+			g.RenamePlayer(0, "first")
+			snap()
+			g.StartGame()
+			snap()
+			rng := rand.New(rand.NewSource(7))
+			for turn := 0; turn < 300 && g.State == PlayingState; turn++ {
+				pid := g.ActivePlayerId
+				moves := g.LegalMoves(pid)
+				m := moves[rng.Intn(len(moves))]
+				// 不需要丢弃宝石的拿取回合逐个宝石执行，以检查回合中间的状态
+				if (m.Type == MoveTakeDifferent || m.Type == MoveTakeSame) && m.Discards == nil && m.Noble == "" {
+					for _, c := range ColorList {
+						for n := 0; n < m.Gems[c]; n++ {
+							if res := g.Act(pid, Action{Type: ActionTake, Target: c}); res != nil && res.Error != "" {
+								t.Fatalf("turn %d: %s", turn, res.Error)
+							}
+							snap()
+						}
+					}
+					// 可拿的颜色少于三种时需要手动结束回合
+					if p := g.getActivePlayer(); p != nil && p.TakenNum() > 0 {
+						g.Act(pid, Action{Type: ActionNext})
+						snap()
+					}
+					continue
+				}
+				if err := g.ApplyMove(pid, m); err != nil {
+					t.Fatalf("turn %d: %v", turn, err)
+				}
+				snap()
+			}
+			for i := 1; i <= len(g.Events); i++ {
+				replayed, err := Replay(g.Events[:i])
+				if err != nil {
+					t.Fatalf("replay of %d events: %v", i, err)
+				} else if len(replayed.Events) != i {
+					t.Fatalf("replay of %d events has %d events", i, len(replayed.Events))
+				}
+				if want, exists := snapshots[i]; exists && fingerprint(t, replayed) != want {
+					t.Fatalf("replay of %d events differs from the live game", i)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayRejectsBadEvents(t *testing.T) {
+	g := newStartedGame(t, 3, Rules{}, 2)
+	swapped := append([]Event{}, g.Events...)
+	swapped[0].CardHash = "another card set"
+	// 规则要求的贵族比卡牌定义中的多
+	crowded := append([]Event{}, g.Events...)
+	crowded[0].Rules = &Rules{Nobles: intPtr(99)}
+	tests := []struct {
+		name   string
+		events []Event
+	}{
+		{"empty", nil},
+		{"no setup", g.Events[1:]},
+		{"wrong deal", append(append([]Event{}, g.Events[:len(g.Events)-1]...), Event{Type: EventDeal, Level: 1, Card: "nope"})},
+		{"unknown type", append(append([]Event{}, g.Events...), Event{Type: "jump"})},
+		{"different card set", swapped},
+		{"too many nobles", crowded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Replay(tt.events); err == nil {
+				t.Fatal("replay succeeded")
+			}
+		})
+	}
+}
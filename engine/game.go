@@ -0,0 +1,381 @@
+package engine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Game struct {
+	sync.Mutex
+	Players        []*Player
+	PlayerNum      int    `json:"-"`
+	State          string `json:"-"`
+	ActivePlayerId int
+	SpectatorIndex int `json:"-"`
+	Gems           map[string]int
+	Golds          int
+	Table          [][]*DevCard
+	Piles          [][]*DevCard
+	OrientTable    [][]*DevCard
+	OrientPiles    [][]*DevCard
+	Strongholds    map[string][]int
+	CardMap        map[string]*DevCard `json:"-"`
+	Nobles         []*Noble
+	AllNobles      []*Noble `json:"-"`
+	Cities         []*City
+	AllCities      []*City `json:"-"`
+	LastRound      bool    `json:"-"`
+	Rules          Rules
+	Winner         *Player
+	Standings      []Standing
+	Events         []Event   `json:"-"`
+	UpdatedTime    time.Time `json:"-"`
+	BeginPlayerId  int       `json:"-"`
+	Seed           int64     `json:"-"`
+	rng            *rand.Rand
+}
+
+// NewGame 以给定种子和规则创建新游戏，相同种子、规则和操作的游戏结果相同
+// 卡牌来自 DefaultCardSet，调用前应确认卡牌定义能够加载
+func NewGame(seed int64, rules Rules) *Game {
+	set, err := DefaultCardSet()
+	if err != nil {
+		panic(fmt.Sprintf("failed to load cards: %v", err))
+	}
+	rules = rules.withDefaults()
+	rng := rand.New(rand.NewSource(seed))
+	piles, loadedNobles := NewCards(set, rng)
+	shuffleNobles(rng, loadedNobles)
+	// 城市在贵族之后生成，标准游戏的随机序列不受影响
+	var cities []*City
+	if rules.Cities {
+		cities = NewCities(set, rng)
+		shuffleCities(rng, cities)
+	}
+	// 东方扩展的卡牌在城市之后生成
+	var orientTable, orientPiles [][]*DevCard
+	if rules.Orient {
+		orientPiles = NewOrientCards(set, rng)
+		orientTable = make([][]*DevCard, LevelNum)
+		for i := range orientTable {
+			orientTable[i] = make([]*DevCard, 0)
+		}
+	}
+
+	table := make([][]*DevCard, LevelNum)
+	for i := range table {
+		table[i] = make([]*DevCard, 0)
+	}
+	cardMap := make(map[string]*DevCard)
+	for _, pile := range append(append([][]*DevCard{}, piles...), orientPiles...) {
+		for _, card := range pile {
+			cardMap[card.Uuid] = card
+		}
+	}
+	g := &Game{
+		Players:        make([]*Player, MaxPlayers),
+		PlayerNum:      0,
+		State:          WaitingState,
+		ActivePlayerId: -1,
+		SpectatorIndex: MaxPlayers,
+		Gems:           make(map[string]int),
+		Golds:          *rules.Golds,
+		Table:          table,
+		Piles:          piles,
+		OrientTable:    orientTable,
+		OrientPiles:    orientPiles,
+		Strongholds:    make(map[string][]int),
+		CardMap:        cardMap,
+		AllNobles:      loadedNobles,
+		AllCities:      cities,
+		LastRound:      false,
+		Winner:         nil,
+		Events:         make([]Event, 0),
+		UpdatedTime:    time.Now(),
+		Seed:           seed,
+		Rules:          rules,
+		rng:            rng,
+	}
+	g.Nobles = g.AllNobles[:g.nobleCount()]
+	g.Cities = g.AllCities[:g.cityCount()]
+	g.record(Event{Type: EventSetup, Pid: -1, Seed: seed, Rules: &rules, CardHash: set.Hash})
+	return g
+}
+
+// AddPlayer 添加玩家并返回其编号和 UUID
+func (g *Game) AddPlayer(name string) (pid int, uuid string) {
+	pid = g.PlayerNum
+	g.record(Event{Type: EventJoin, Pid: pid, Name: name, Uuid: newPlayerUuid()})
+	uuid = g.Players[pid].Uuid
+	return
+}
+
+// RenamePlayer 重命名玩家
+func (g *Game) RenamePlayer(pid int, name string) {
+	g.record(Event{Type: EventRename, Pid: pid, Name: name})
+}
+
+// AddSpectator 添加观众并返回其编号和 UUID
+func (g *Game) AddSpectator() (sid int, uuid string) {
+	sid = g.SpectatorIndex
+	g.record(Event{Type: EventSpectate, Pid: sid, Uuid: newPlayerUuid()})
+	uuid = g.Players[len(g.Players)-1].Uuid
+	return
+}
+
+// StartGame 开始游戏
+func (g *Game) StartGame() bool {
+	if g.PlayerNum < 2 || g.State != WaitingState {
+		return false
+	}
+	// 洗牌并随机挑选一个玩家先手
+	g.record(Event{Type: EventStart, Pid: -1})
+	// 发牌
+	g.refillTable()
+	return true
+}
+
+// Take 宝石拿取
+func (g *Game) Take(color string) *Result {
+	player := g.getActivePlayer()
+	info := player.TakeOne(color)
+	if info == "continue" {
+		return nil
+	} else if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// Discard 宝石丢弃
+func (g *Game) Discard(color string) *Result {
+	player := g.getActivePlayer()
+	info := player.Discard(color)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return nil
+}
+
+// Buy 购买发展卡
+func (g *Game) Buy(uuid string) *Result {
+	player := g.getActivePlayer()
+	info := player.Buy(uuid)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// Reserve 预定发展卡
+func (g *Game) Reserve(uuid string) *Result {
+	player := g.getActivePlayer()
+	info := player.Reserve(uuid)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// Choose 完成东方卡牌能力的选择
+func (g *Game) Choose(target string) *Result {
+	player := g.getActivePlayer()
+	info := player.Choose(target)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// TakePostGem 拿取前哨站的宝石
+func (g *Game) TakePostGem(color string) *Result {
+	player := g.getActivePlayer()
+	info := player.TakePostGem(color)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// PlaceStronghold 在卡牌上放置要塞
+func (g *Game) PlaceStronghold(uuid string) *Result {
+	player := g.getActivePlayer()
+	info := player.PlaceStronghold(uuid)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// RazeStronghold 摧毁卡牌上其他玩家的要塞
+func (g *Game) RazeStronghold(uuid string) *Result {
+	player := g.getActivePlayer()
+	info := player.RazeStronghold(uuid)
+	if info != "" {
+		return &Result{Error: info}
+	}
+	return g.NextTurn()
+}
+
+// VisitNobleActively 主动访问贵族
+func (g *Game) VisitNobleActively(uuid string) *Result {
+	player := g.getActivePlayer()
+	nobles := player.CheckNobles()
+	for _, n := range nobles {
+		if n.Uuid == uuid {
+			player.DoVisit(n)
+			return g.NextTurn()
+		}
+	}
+	return &Result{Error: "You can't visit this noble"}
+}
+
+// NextTurn 下一个回合
+func (g *Game) NextTurn() *Result {
+	player := g.getActivePlayer()
+	if player == nil {
+		return nil
+	}
+	// 在此处统一修改 Finished
+	player.Finished = true
+	// 等待玩家完成东方卡牌能力或前哨站宝石的选择
+	if ability, options := player.PendingChoice(); ability != "" {
+		return &Result{Ability: ability, Options: options}
+	}
+	// 检查贵族，如果有多个贵族则暂不跳过回合，否则结束回合
+	nobles := g.checkingNobleAndAutoVisit()
+	if nobles != nil {
+		return nobles
+	}
+	// 城市模式下检查城市，所有城市的效果相同，满足多个时获得第一个
+	if g.Rules.Cities && player.City == nil {
+		if cities := player.CheckCities(); len(cities) > 0 {
+			player.claimCity(cities[0])
+		}
+	}
+	g.record(Event{Type: EventTurnEnd, Pid: player.Id})
+	return nil
+}
+
+func (g *Game) checkingNobleAndAutoVisit() *Result {
+	player := g.getActivePlayer()
+	if player.Visited {
+		return nil
+	}
+	// 检查贵族
+	nobles := player.CheckNobles()
+	// 有多个贵族，返回贵族信息，暂时不跳过回合
+	if len(nobles) > 1 {
+		uuids := make([]string, len(nobles))
+		for i, n := range nobles {
+			uuids[i] = n.Uuid
+		}
+		return &Result{Nobles: uuids}
+	}
+	// 有一个贵族则自动访问
+	if len(nobles) == 1 {
+		player.DoVisit(nobles[0])
+	}
+	return nil
+}
+
+func (g *Game) getActivePlayer() *Player {
+	index := g.ActivePlayerId
+	if index < 0 || index >= g.PlayerNum {
+		return nil
+	}
+	return g.Players[index]
+}
+
+// Standing 最终排名中的一名玩家，名次相同的玩家平局
+type Standing struct {
+	Pid    int  `json:"pid"`
+	Rank   int  `json:"rank"`
+	Points int  `json:"points"`
+	Cards  int  `json:"cards"`
+	City   bool `json:"city,omitempty"`
+}
+
+// determineStandings 按分数从高到低排名，分数相同时购买发展卡少的玩家在前，两者都相同时名次相同
+// 城市模式下获得城市的玩家排在没有城市的玩家之前
+func (g *Game) determineStandings() []Standing {
+	standings := make([]Standing, g.PlayerNum)
+	for i, p := range g.Players[:g.PlayerNum] {
+		standings[i] = Standing{Pid: p.Id, Points: p.Points, Cards: p.CardNum(), City: p.City != nil}
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.City != b.City {
+			return a.City
+		} else if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		return a.Cards < b.Cards
+	})
+	for i := range standings {
+		prev := standings[max(i-1, 0)]
+		if i > 0 && standings[i].City == prev.City && standings[i].Points == prev.Points && standings[i].Cards == prev.Cards {
+			standings[i].Rank = prev.Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings
+}
+
+// Winners 返回排名第一的所有玩家，多于一人时为平局，游戏未结束时为空
+func (g *Game) Winners() []*Player {
+	winners := make([]*Player, 0)
+	for _, s := range g.Standings {
+		if s.Rank == 1 {
+			winners = append(winners, g.Players[s.Pid])
+		}
+	}
+	return winners
+}
+
+// refillTable 从牌堆向桌上的空位发牌，东方扩展的卡牌另外发在自己的一行
+func (g *Game) refillTable() {
+	g.refillRows(g.Table, g.Piles, g.Rules.TableSize, false)
+	g.refillRows(g.OrientTable, g.OrientPiles, OrientTableSize, true)
+}
+
+func (g *Game) refillRows(table, piles [][]*DevCard, size int, orient bool) {
+	for l, row := range table {
+		for i := len(row); i < size && len(piles[l]) > 0; i++ {
+			g.record(Event{Type: EventDeal, Pid: g.ActivePlayerId, Level: l + 1, Slot: i, Card: piles[l][0].Uuid, Orient: orient})
+		}
+		for i, card := range table[l] {
+			if card == nil && len(piles[l]) > 0 {
+				g.record(Event{Type: EventDeal, Pid: g.ActivePlayerId, Level: l + 1, Slot: i, Card: piles[l][0].Uuid, Orient: orient})
+			}
+		}
+	}
+}
+
+func shuffleCards(rng *rand.Rand, cards []*DevCard) {
+	n := len(cards)
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(n-i)
+		cards[i], cards[j] = cards[j], cards[i]
+	}
+}
+
+func shuffleNobles(rng *rand.Rand, nobles []*Noble) {
+	n := len(nobles)
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(n-i)
+		nobles[i], nobles[j] = nobles[j], nobles[i]
+	}
+}
+
+func shuffleCities(rng *rand.Rand, cities []*City) {
+	n := len(cities)
+	for i := 0; i < n; i++ {
+		j := i + rng.Intn(n-i)
+		cities[i], cities[j] = cities[j], cities[i]
+	}
+}
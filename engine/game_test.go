@@ -0,0 +1,261 @@
+package engine
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sameEvents 比较两个事件序列，忽略时间和随机生成的玩家 UUID
+func sameEvents(a, b []Event) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := a[i], b[i]
+		x.Time, y.Time = time.Time{}, time.Time{}
+		x.Uuid, y.Uuid = "", ""
+		if !reflect.DeepEqual(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+func pileIds(g *Game) [][]string {
+	ids := make([][]string, 0)
+	for _, rows := range [][][]*DevCard{g.Table, g.Piles} {
+		for _, row := range rows {
+			line := make([]string, 0, len(row))
+			for _, card := range row {
+				line = append(line, card.Uuid)
+			}
+			ids = append(ids, line)
+		}
+	}
+	return ids
+}
+
+func TestSameSeedSameGame(t *testing.T) {
+	variants := []struct {
+		name  string
+		rules Rules
+	}{
+		{"standard", Rules{}},
+		{"cities", Rules{Cities: true}},
+		{"orient", Rules{Orient: true}},
+	}
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 5; seed++ {
+				a := newStartedGame(t, seed, v.rules, 4)
+				b := newStartedGame(t, seed, v.rules, 4)
+				if !reflect.DeepEqual(pileIds(a), pileIds(b)) {
+					t.Fatalf("seed %d: the cards are dealt differently", seed)
+				} else if a.BeginPlayerId != b.BeginPlayerId {
+					t.Fatalf("seed %d: different first players", seed)
+				}
+				playRandom(t, a, rand.New(rand.NewSource(seed)), 400, nil)
+				playRandom(t, b, rand.New(rand.NewSource(seed)), 400, nil)
+				if !sameEvents(a.Events, b.Events) {
+					t.Fatalf("seed %d: the events differ", seed)
+				} else if !reflect.DeepEqual(a.Standings, b.Standings) {
+					t.Fatalf("seed %d: got standings %v and %v", seed, a.Standings, b.Standings)
+				}
+			}
+		})
+	}
+}
+
+func TestDifferentSeedsDiffer(t *testing.T) {
+	a := newStartedGame(t, 1, Rules{}, 2)
+	b := newStartedGame(t, 2, Rules{}, 2)
+	if reflect.DeepEqual(pileIds(a), pileIds(b)) {
+		t.Fatal("different seeds dealt the same cards")
+	}
+}
+
+func TestDetermineStandings(t *testing.T) {
+	type seat struct {
+		points, cards int
+		city          bool
+	}
+	tests := []struct {
+		name  string
+		seats []seat
+		pids  []int // 从第一名开始的玩家
+		ranks []int
+	}{
+		{"points", []seat{{10, 5, false}, {15, 9, false}, {12, 3, false}}, []int{1, 2, 0}, []int{1, 2, 3}},
+		{"fewer cards win ties", []seat{{15, 8, false}, {15, 6, false}}, []int{1, 0}, []int{1, 2}},
+		{"shared rank", []seat{{15, 6, false}, {16, 7, false}, {15, 6, false}, {9, 2, false}}, []int{1, 0, 2, 3}, []int{1, 2, 2, 4}},
+		{"shared first place", []seat{{15, 6, false}, {15, 6, false}, {14, 6, false}}, []int{0, 1, 2}, []int{1, 1, 3}},
+		{"city first", []seat{{20, 9, false}, {16, 9, true}, {17, 9, true}}, []int{2, 1, 0}, []int{1, 2, 3}},
+		{"city ties", []seat{{16, 4, true}, {18, 4, false}, {16, 4, true}}, []int{0, 2, 1}, []int{1, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame(1, Rules{})
+			for i, s := range tt.seats {
+				g.AddPlayer("p")
+				p := g.Players[i]
+				p.Points = s.points
+				for j := 0; j < s.cards; j++ {
+					p.Cards["W"] = append(p.Cards["W"], &DevCard{Color: "W"})
+				}
+				if s.city {
+					p.City = &City{}
+				}
+			}
+			standings := g.determineStandings()
+			for i, s := range standings {
+				if s.Pid != tt.pids[i] || s.Rank != tt.ranks[i] {
+					t.Fatalf("got %+v, want players %v with ranks %v", standings, tt.pids, tt.ranks)
+				}
+			}
+		})
+	}
+}
+
+// giveCards 给玩家每种颜色 counts 张发展卡
+func giveCards(p *Player, counts map[string]int) {
+	for c, n := range counts {
+		for i := 0; i < n; i++ {
+			p.Cards[c] = append(p.Cards[c], &DevCard{Color: c, Level: 1})
+		}
+	}
+}
+
+// takeThree 以拿三个不同颜色宝石的回合结束当前玩家的回合
+func takeThree(t *testing.T, g *Game) {
+	t.Helper()
+	pid := g.ActivePlayerId
+	if err := g.ApplyMove(pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCityClaim(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(p *Player, city *City)
+		city  int // 获得的城市在 g.Cities 中的位置，-1 表示没有获得城市
+	}{
+		{"meets the city", func(p *Player, city *City) {
+			giveCards(p, city.Cost)
+			p.Points = city.Points
+		}, 0},
+		{"one point short", func(p *Player, city *City) {
+			giveCards(p, city.Cost)
+			p.Points = city.Points - 1
+		}, -1},
+		{"one card short", func(p *Player, city *City) {
+			cost := copyCost(city.Cost)
+			for _, c := range ColorList {
+				if cost[c] > 0 {
+					cost[c]--
+					break
+				}
+			}
+			giveCards(p, cost)
+			p.Points = city.Points
+		}, -1},
+		{"points without cards", func(p *Player, city *City) {
+			p.Points = 30
+		}, -1},
+		{"meets every city", func(p *Player, city *City) {
+			counts := make(map[string]int)
+			for _, c := range ColorList {
+				counts[c] = 10
+			}
+			giveCards(p, counts)
+			p.Points = 30
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, 1, Rules{Cities: true}, 2)
+			p := g.getActivePlayer()
+			tt.setup(p, g.Cities[0])
+			takeThree(t, g)
+			if tt.city < 0 {
+				if p.City != nil || g.LastRound {
+					t.Fatalf("claimed %v, last round %v", p.City, g.LastRound)
+				}
+				return
+			}
+			if p.City != g.Cities[tt.city] {
+				t.Fatalf("claimed %v, want %v", p.City, g.Cities[tt.city])
+			} else if !g.LastRound {
+				t.Fatal("claiming a city didn't start the last round")
+			}
+		})
+	}
+}
+
+// TestCityLastRound 第一个玩家获得城市后，其他玩家在最后一轮中仍然可以获得同一个城市
+func TestCityLastRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		city   bool // 第二个玩家也满足城市的要求
+		points int  // 第二个玩家比第一个玩家多的分数
+		cards  int  // 第二个玩家比第一个玩家多的发展卡
+		ranks  []int
+	}{
+		{"only the first player", false, 5, 0, []int{1, 2}},
+		{"same city, more points", true, 1, 0, []int{2, 1}},
+		{"same city, fewer cards", true, 0, -1, []int{2, 1}},
+		{"same city, tie", true, 0, 0, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, 1, Rules{Cities: true}, 2)
+			city := g.Cities[0]
+			first := g.getActivePlayer()
+			second := g.Players[1-first.Id]
+			cost := copyCost(city.Cost)
+			// 多给一张卡牌，少一张时仍然满足城市
+			cost[ColorList[0]]++
+			giveCards(first, cost)
+			first.Points = city.Points
+			takeThree(t, g)
+			if !g.LastRound || g.State != PlayingState {
+				t.Fatalf("got last round %v and state %s", g.LastRound, g.State)
+			}
+
+			cost = copyCost(city.Cost)
+			cost[ColorList[0]] += 1 + tt.cards
+			if !tt.city {
+				cost = map[string]int{ColorList[0]: first.CardNum() + tt.cards}
+			}
+			giveCards(second, cost)
+			second.Points = first.Points + tt.points
+			takeThree(t, g)
+			if g.State != EndedState {
+				t.Fatalf("the game didn't end after the last round: %s", g.State)
+			} else if (second.City == city) != tt.city {
+				t.Fatalf("second player claimed %v", second.City)
+			}
+			ranks := make(map[int]int)
+			for _, s := range g.Standings {
+				ranks[s.Pid] = s.Rank
+			}
+			if ranks[first.Id] != tt.ranks[0] || ranks[second.Id] != tt.ranks[1] {
+				t.Fatalf("got standings %+v, want ranks %v", g.Standings, tt.ranks)
+			}
+			// 平局时没有唯一的赢家
+			if tie := tt.ranks[0] == tt.ranks[1]; tie != (g.Winner == nil) {
+				t.Fatalf("got winner %v", g.Winner)
+			}
+		})
+	}
+}
+
+func copyCost(cost map[string]int) map[string]int {
+	c := make(map[string]int, len(cost))
+	for k, v := range cost {
+		c[k] = v
+	}
+	return c
+}
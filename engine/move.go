@@ -0,0 +1,679 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// MoveType 完整回合的类型
+type MoveType string
+
+const (
+	MoveTakeDifferent MoveType = "take_different"
+	MoveTakeSame      MoveType = "take_same"
+	MoveReserve       MoveType = "reserve"
+	MoveReservePile   MoveType = "reserve_pile"
+	MoveBuy           MoveType = "buy"
+	MoveStronghold    MoveType = "stronghold"
+	MoveRaze          MoveType = "raze"
+	MovePass          MoveType = "pass"
+)
+
+// 校验失败的原因代码
+const (
+	ErrNotPlaying      = "not_playing"
+	ErrNotYourTurn     = "not_your_turn"
+	ErrTurnInProgress  = "turn_in_progress"
+	ErrInvalidMove     = "invalid_move"
+	ErrInvalidGems     = "invalid_gems"
+	ErrNotEnoughGems   = "not_enough_gems"
+	ErrCardUnavailable = "card_unavailable"
+	ErrReserveLimit    = "reserve_limit"
+	ErrPileEmpty       = "pile_empty"
+	ErrCannotAfford    = "cannot_afford"
+	ErrInvalidPayment  = "invalid_payment"
+	ErrInvalidDiscards = "invalid_discards"
+	ErrNobleRequired   = "noble_required"
+	ErrInvalidNoble    = "invalid_noble"
+	ErrChoiceRequired  = "choice_required"
+	ErrInvalidChoice   = "invalid_choice"
+	ErrCardGuarded     = "card_guarded"
+	ErrStronghold      = "invalid_stronghold"
+)
+
+// Move 一个完整回合的操作，宝石的键为 ColorList 中的颜色或 GoldKey
+type Move struct {
+	Type     MoveType       `json:"type"`
+	Gems     map[string]int `json:"gems,omitempty"`
+	Card     string         `json:"card,omitempty"`
+	Level    int            `json:"level,omitempty"`
+	Payment  map[string]int `json:"payment,omitempty"`
+	Discards map[string]int `json:"discards,omitempty"`
+	Noble    string         `json:"noble,omitempty"`
+	Choice   string         `json:"choice,omitempty"`   // 东方卡牌能力的选择：颜色、贵族或卡牌的 UUID
+	PostGem  string         `json:"post_gem,omitempty"` // 买卡后从前哨站拿取的宝石颜色
+}
+
+// Key 回合的唯一标识，内容相同的回合标识相同
+func (m Move) Key() string {
+	key := string(m.Type) + "|" + countsKey(m.Gems) + "|" + m.Card + "|" + strconv.Itoa(m.Level)
+	return key + "|" + countsKey(m.Payment) + "|" + countsKey(m.Discards) + "|" + m.Noble + "|" + m.Choice + "|" + m.PostGem
+}
+
+// countsKey 按固定的颜色顺序把宝石数量转换为字符串
+func countsKey(m map[string]int) string {
+	key := ""
+	for _, c := range append(append([]string{}, ColorList...), GoldKey) {
+		if m[c] > 0 {
+			key += c + strconv.Itoa(m[c])
+		}
+	}
+	return key
+}
+
+// MoveError 回合校验失败的原因
+type MoveError struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *MoveError) Error() string {
+	return e.Msg
+}
+
+// hand 执行操作后玩家手中的宝石和奖励
+type hand struct {
+	gems  map[string]int
+	golds int
+	bonus map[string]int
+}
+
+// ValidateMove 校验玩家 pid 的完整回合是否合法，合法时返回 nil
+func (g *Game) ValidateMove(pid int, m Move) error {
+	p, err := g.moverOf(pid)
+	if err != nil {
+		return err
+	}
+	h, err := g.handAfterAction(p, m)
+	if err != nil {
+		return err
+	}
+	// 检查丢弃的宝石
+	excess := h.total() - g.Rules.MaxGems
+	if excess < 0 {
+		excess = 0
+	}
+	if valueSum(m.Discards) != excess {
+		return moveError(ErrInvalidDiscards, "You must discard exactly %d gems", excess)
+	}
+	for c, n := range m.Discards {
+		if n < 0 || n > h.count(c) {
+			return moveError(ErrInvalidDiscards, "You can't discard %d %s", n, c)
+		}
+	}
+	// 检查贵族
+	nobles := g.noblesFor(p, h.bonus)
+	if m.Noble != "" {
+		for _, n := range nobles {
+			if n.Uuid == m.Noble {
+				return nil
+			}
+		}
+		return moveError(ErrInvalidNoble, "You can't visit this noble")
+	} else if len(nobles) > 1 {
+		return moveError(ErrNobleRequired, "Choose a noble to visit")
+	}
+	return nil
+}
+
+// ApplyMove 校验并一次性执行玩家 pid 的完整回合，不合法时不修改任何状态
+func (g *Game) ApplyMove(pid int, m Move) error {
+	if err := g.ValidateMove(pid, m); err != nil {
+		return err
+	}
+	p := g.getActivePlayer()
+	switch m.Type {
+	case MoveTakeDifferent, MoveTakeSame:
+		p.takeGems(m.Gems)
+	case MoveReserve:
+		p.reserveCard(g.tableCard(m.Card))
+	case MoveReservePile:
+		p.reservePile(m.Level)
+	case MoveBuy:
+		card := g.tableCard(m.Card)
+		if card == nil {
+			card = p.reservedCard(m.Card)
+		}
+		pay := m.Payment
+		if pay == nil {
+			pay = p.defaultPayment(card)
+		}
+		p.buyCard(card, pay)
+		if m.Choice != "" {
+			p.Choose(m.Choice)
+		} else {
+			p.autoResolve()
+		}
+		if m.PostGem != "" {
+			p.TakePostGem(m.PostGem)
+		}
+	case MoveStronghold:
+		p.PlaceStronghold(m.Card)
+	case MoveRaze:
+		p.RazeStronghold(m.Card)
+	}
+	// 丢弃多余的宝石
+	for _, c := range append(append([]string{}, ColorList...), GoldKey) {
+		for i := 0; i < m.Discards[c]; i++ {
+			p.Discard(c)
+		}
+	}
+	// 访问选择的贵族，只有一个贵族时由 NextTurn 自动访问
+	if m.Noble != "" {
+		for _, n := range p.availableNobles() {
+			if n.Uuid == m.Noble {
+				p.DoVisit(n)
+				break
+			}
+		}
+	}
+	g.NextTurn()
+	return nil
+}
+
+// LegalMoves 列出玩家 pid 在当前状态下所有合法的完整回合
+func (g *Game) LegalMoves(pid int) []Move {
+	p, err := g.moverOf(pid)
+	if err != nil {
+		return nil
+	}
+	moves := g.actionMoves(p)
+	if len(moves) == 0 {
+		return []Move{{Type: MovePass}}
+	}
+	// 为每个操作补全丢弃方案和贵族选择
+	result := make([]Move, 0, len(moves))
+	for _, m := range moves {
+		h, err := g.handAfterAction(p, m)
+		if err != nil {
+			continue
+		}
+		excess := h.total() - g.Rules.MaxGems
+		discards := []map[string]int{nil}
+		if excess > 0 {
+			discards = h.discardOptions(excess)
+		}
+		nobles := []string{""}
+		if candidates := g.noblesFor(p, h.bonus); len(candidates) > 1 {
+			nobles = make([]string, len(candidates))
+			for i, n := range candidates {
+				nobles[i] = n.Uuid
+			}
+		}
+		for _, d := range discards {
+			for _, n := range nobles {
+				full := m
+				full.Discards = d
+				full.Noble = n
+				result = append(result, full)
+			}
+		}
+	}
+	return result
+}
+
+func (g *Game) moverOf(pid int) (*Player, error) {
+	if g.State != PlayingState {
+		return nil, moveError(ErrNotPlaying, "The game is not in progress")
+	} else if pid != g.ActivePlayerId {
+		return nil, moveError(ErrNotYourTurn, "Now is not your turn")
+	}
+	p := g.getActivePlayer()
+	if p.Finished || p.TakenNum() > 0 {
+		return nil, moveError(ErrTurnInProgress, "You have already acted this turn")
+	}
+	return p, nil
+}
+
+// actionMoves 列出所有不含丢弃和贵族选择的操作
+func (g *Game) actionMoves(p *Player) []Move {
+	moves := make([]Move, 0)
+	// 拿取不同颜色的宝石
+	available := make([]string, 0, len(ColorList))
+	for _, c := range ColorList {
+		if g.Gems[c] > 0 {
+			available = append(available, c)
+		}
+	}
+	for _, colors := range Combinations(available, min(3, len(available))) {
+		gems := make(map[string]int)
+		for _, c := range colors {
+			gems[c] = 1
+		}
+		moves = append(moves, Move{Type: MoveTakeDifferent, Gems: gems})
+	}
+	// 拿取两个相同颜色的宝石
+	for _, c := range ColorList {
+		if g.Gems[c] >= 4 {
+			moves = append(moves, Move{Type: MoveTakeSame, Gems: map[string]int{c: 2}})
+		}
+	}
+	// 预定
+	if len(p.Reserved) < p.MaxReserve() {
+		for _, card := range g.TableCards() {
+			if !p.Blocked(card) {
+				moves = append(moves, Move{Type: MoveReserve, Card: card.Uuid})
+			}
+		}
+		for i, pile := range g.Piles {
+			if len(pile) > 0 {
+				moves = append(moves, Move{Type: MoveReservePile, Level: i + 1})
+			}
+		}
+	}
+	// 购买，东方卡牌有多个选项时每个选项都是一个操作
+	cards := append(g.TableCards(), p.Reserved...)
+	for _, card := range cards {
+		if p.Blocked(card) || p.abilityError(card) != "" {
+			continue
+		}
+		choices := []string{""}
+		if options := p.optionsFor(card); needsChoice(card.Ability) && len(options) > 1 {
+			choices = options
+		}
+		for _, pay := range p.paymentOptions(card) {
+			for _, choice := range choices {
+				for _, gem := range p.postGemsAfter(pay) {
+					moves = append(moves, Move{Type: MoveBuy, Card: card.Uuid, Payment: pay, Choice: choice, PostGem: gem})
+				}
+			}
+		}
+	}
+	// 要塞
+	if g.Rules.Strongholds {
+		for _, card := range g.TableCards() {
+			if p.strongholdError(false, card.Uuid) == "" {
+				moves = append(moves, Move{Type: MoveStronghold, Card: card.Uuid})
+			}
+			if p.strongholdError(true, card.Uuid) == "" {
+				moves = append(moves, Move{Type: MoveRaze, Card: card.Uuid})
+			}
+		}
+	}
+	return moves
+}
+
+// handAfterAction 校验操作部分，并计算操作后玩家手中的宝石和奖励
+func (g *Game) handAfterAction(p *Player, m Move) (*hand, error) {
+	h := p.currentHand()
+	if (m.Choice != "" || m.PostGem != "") && m.Type != MoveBuy {
+		return nil, moveError(ErrInvalidChoice, "Only buying a card can include a choice")
+	}
+	switch m.Type {
+	case MoveTakeDifferent, MoveTakeSame:
+		if m.Card != "" || m.Level != 0 || m.Payment != nil {
+			return nil, moveError(ErrInvalidMove, "Taking gems can't include a card")
+		}
+		kinds, sum := 0, 0
+		for c, n := range m.Gems {
+			if _, ok := ColorDict[c]; !ok || n < 0 {
+				return nil, moveError(ErrInvalidGems, "You can't take %d %s", n, c)
+			} else if n == 0 {
+				continue
+			} else if g.Gems[c] < n {
+				return nil, moveError(ErrNotEnoughGems, "No %s left", ColorDict[c])
+			}
+			kinds++
+			sum += n
+			h.gems[c] += n
+		}
+		if m.Type == MoveTakeSame {
+			if kinds != 1 || sum != 2 {
+				return nil, moveError(ErrInvalidGems, "You must take 2 gems of the same color")
+			}
+			for c, n := range m.Gems {
+				if n == 2 && g.Gems[c] < 4 {
+					return nil, moveError(ErrNotEnoughGems, "There are not enough %s left", ColorDict[c])
+				}
+			}
+			break
+		}
+		available := 0
+		for _, c := range ColorList {
+			if g.Gems[c] > 0 {
+				available++
+			}
+		}
+		if kinds != sum || sum != min(3, available) || sum == 0 {
+			return nil, moveError(ErrInvalidGems, "You must take %d gems of different colors", min(3, available))
+		}
+	case MoveReserve, MoveReservePile:
+		if m.Gems != nil || m.Payment != nil {
+			return nil, moveError(ErrInvalidMove, "Reserving can't include gems")
+		} else if len(p.Reserved) >= p.MaxReserve() {
+			return nil, moveError(ErrReserveLimit, "You have already reserved %d cards", p.MaxReserve())
+		}
+		if m.Type == MoveReserve {
+			if card := g.tableCard(m.Card); card == nil {
+				return nil, moveError(ErrCardUnavailable, "This card is not available")
+			} else if p.Blocked(card) {
+				return nil, moveError(ErrCardGuarded, "This card is guarded by another player's stronghold")
+			}
+		} else if m.Level < 1 || m.Level > len(g.Piles) || len(g.Piles[m.Level-1]) == 0 {
+			return nil, moveError(ErrPileEmpty, "No card left in this pile")
+		}
+		if g.Golds > 0 {
+			h.golds++
+		}
+	case MoveBuy:
+		if m.Gems != nil || m.Level != 0 {
+			return nil, moveError(ErrInvalidMove, "Buying can't include gems")
+		}
+		card := g.tableCard(m.Card)
+		if card == nil {
+			card = p.reservedCard(m.Card)
+		}
+		if card == nil {
+			return nil, moveError(ErrCardUnavailable, "This card is not available")
+		} else if p.Blocked(card) {
+			return nil, moveError(ErrCardGuarded, "This card is guarded by another player's stronghold")
+		} else if info := p.abilityError(card); info != "" {
+			return nil, moveError(ErrCannotAfford, "%s", info)
+		}
+		pay := m.Payment
+		if pay == nil {
+			pay = p.defaultPayment(card)
+			if pay == nil {
+				return nil, moveError(ErrCannotAfford, "Not enough gems")
+			}
+		} else if err := p.checkPayment(card, pay); err != nil {
+			return nil, err
+		}
+		for c, n := range pay {
+			if c == GoldKey {
+				h.golds -= n
+			} else {
+				h.gems[c] -= n
+			}
+		}
+		if err := h.addCard(p, card, m.Choice); err != nil {
+			return nil, err
+		}
+		// 前哨站的宝石，支付的宝石已回到桌面
+		gems := p.postGemsAfter(pay)
+		if m.PostGem == "" && gems[0] != "" {
+			return nil, moveError(ErrChoiceRequired, "Choose a gem to take from your outpost")
+		} else if m.PostGem != "" && !slices.Contains(gems, m.PostGem) {
+			return nil, moveError(ErrInvalidChoice, "You can't take this gem from an outpost")
+		}
+		if m.PostGem != "" {
+			h.gems[m.PostGem]++
+		}
+	case MoveStronghold, MoveRaze:
+		if m.Gems != nil || m.Level != 0 || m.Payment != nil {
+			return nil, moveError(ErrInvalidMove, "Strongholds can't include gems")
+		} else if info := p.strongholdError(m.Type == MoveRaze, m.Card); info != "" {
+			return nil, moveError(ErrStronghold, "%s", info)
+		}
+	case MovePass:
+		if m.Gems != nil || m.Card != "" || m.Level != 0 || m.Payment != nil {
+			return nil, moveError(ErrInvalidMove, "Passing can't include anything")
+		} else if len(g.actionMoves(p)) > 0 {
+			return nil, moveError(ErrInvalidMove, "You can only pass when no other move is possible")
+		}
+	default:
+		return nil, moveError(ErrInvalidMove, "Invalid move type")
+	}
+	return h, nil
+}
+
+// addCard 校验东方卡牌能力的选择，并把买下的卡牌加入奖励
+func (h *hand) addCard(p *Player, card *DevCard, choice string) error {
+	options := p.optionsFor(card)
+	if !needsChoice(card.Ability) && choice != "" {
+		return moveError(ErrInvalidChoice, "This card has nothing to choose")
+	} else if choice == "" && len(options) > 1 {
+		return moveError(ErrChoiceRequired, "Choose how to use the card's ability")
+	} else if choice == "" && len(options) == 1 {
+		choice = options[0]
+	} else if choice != "" && !slices.Contains(options, choice) {
+		return moveError(ErrInvalidChoice, "You can't choose this")
+	}
+	switch card.Ability {
+	case AbilityJoin:
+		h.bonus[choice]++
+		return nil
+	case AbilitySacrifice:
+		for _, c := range p.Sacrifices(card) {
+			h.bonus[c.Color] -= c.Bonus()
+		}
+	case AbilityFreeCard:
+		if choice != "" {
+			h.bonus[p.Game.CardMap[choice].Color]++
+		}
+	}
+	h.bonus[card.Color] += card.Bonus()
+	return nil
+}
+
+// noblesFor 返回玩家拥有给定奖励时能够访问的贵族
+func (g *Game) noblesFor(p *Player, bonus map[string]int) []*Noble {
+	var nobles []*Noble
+	for _, n := range p.availableNobles() {
+		able := true
+		for c, v := range n.Cost {
+			if bonus[c] < v {
+				able = false
+				break
+			}
+		}
+		if able {
+			nobles = append(nobles, n)
+		}
+	}
+	return nobles
+}
+
+// postGemsAfter 按支付方案买卡后可以从前哨站拿取的宝石，不能拿取时只有 ""
+func (p *Player) postGemsAfter(pay map[string]int) []string {
+	gems := make([]string, 0)
+	if p.HasPost(PostGem) {
+		for _, c := range ColorList {
+			if p.Game.Gems[c]+pay[c] > 0 {
+				gems = append(gems, c)
+			}
+		}
+	}
+	if len(gems) == 0 {
+		return []string{""}
+	}
+	return gems
+}
+
+func (g *Game) tableCard(uuid string) *DevCard {
+	for _, card := range g.TableCards() {
+		if card.Uuid == uuid {
+			return card
+		}
+	}
+	return nil
+}
+
+func (p *Player) takeGems(gems map[string]int) {
+	for _, c := range ColorList {
+		for i := 0; i < gems[c]; i++ {
+			p.Game.record(Event{Type: EventTake, Pid: p.Id, Color: c})
+		}
+	}
+}
+
+func (p *Player) reservedCard(uuid string) *DevCard {
+	for _, card := range p.Reserved {
+		if card.Uuid == uuid {
+			return card
+		}
+	}
+	return nil
+}
+
+func (p *Player) currentHand() *hand {
+	h := &hand{
+		gems:  make(map[string]int),
+		golds: p.Golds,
+		bonus: make(map[string]int),
+	}
+	for _, c := range ColorList {
+		h.gems[c] = p.Gems[c]
+		h.bonus[c] = p.Bonus(c)
+	}
+	return h
+}
+
+// defaultPayment 优先使用宝石、不足部分使用黄金的支付方案，买不起时返回 nil
+func (p *Player) defaultPayment(card *DevCard) map[string]int {
+	pay := make(map[string]int)
+	for _, c := range ColorList {
+		need := card.Cost[c] - p.Bonus(c)
+		if need <= 0 {
+			continue
+		}
+		gems := min(need, p.Gems[c])
+		if gems > 0 {
+			pay[c] = gems
+		}
+		if need > gems {
+			pay[GoldKey] += need - gems
+		}
+	}
+	if pay[GoldKey] > p.Golds {
+		return nil
+	}
+	return pay
+}
+
+// paymentOptions 列出购买卡牌的所有支付方案
+func (p *Player) paymentOptions(card *DevCard) []map[string]int {
+	result := make([]map[string]int, 0)
+	current := make(map[string]int)
+	var walk func(i, golds int)
+	walk = func(i, golds int) {
+		if i == len(ColorList) {
+			pay := make(map[string]int)
+			for k, v := range current {
+				if v > 0 {
+					pay[k] = v
+				}
+			}
+			if golds > 0 {
+				pay[GoldKey] = golds
+			}
+			result = append(result, pay)
+			return
+		}
+		c := ColorList[i]
+		need := max(card.Cost[c]-p.Bonus(c), 0)
+		// 每种颜色用黄金替代的数量
+		for sub := max(need-p.Gems[c], 0); sub <= need && golds+sub <= p.Golds; sub++ {
+			current[c] = need - sub
+			walk(i+1, golds+sub)
+		}
+		current[c] = 0
+	}
+	walk(0, 0)
+	return result
+}
+
+// checkPayment 校验支付方案是否恰好付清卡牌费用
+func (p *Player) checkPayment(card *DevCard, pay map[string]int) error {
+	var goldNeeded int
+	for c, n := range pay {
+		if n < 0 {
+			return moveError(ErrInvalidPayment, "Invalid payment")
+		} else if c == GoldKey {
+			continue
+		} else if _, ok := ColorDict[c]; !ok {
+			return moveError(ErrInvalidPayment, "Invalid payment")
+		}
+	}
+	for _, c := range ColorList {
+		need := max(card.Cost[c]-p.Bonus(c), 0)
+		if pay[c] > need || pay[c] > p.Gems[c] {
+			return moveError(ErrInvalidPayment, "You can't pay %d %s", pay[c], ColorDict[c])
+		}
+		goldNeeded += need - pay[c]
+	}
+	if pay[GoldKey] != goldNeeded {
+		return moveError(ErrInvalidPayment, "You must pay %d 🟡", goldNeeded)
+	} else if goldNeeded > p.Golds {
+		return moveError(ErrCannotAfford, "Not enough gems")
+	}
+	return nil
+}
+
+func (h *hand) count(key string) int {
+	if key == GoldKey {
+		return h.golds
+	}
+	return h.gems[key]
+}
+
+func (h *hand) total() int {
+	return valueSum(h.gems) + h.golds
+}
+
+// discardOptions 列出丢弃 n 个宝石的所有方案
+func (h *hand) discardOptions(n int) []map[string]int {
+	keys := append(append([]string{}, ColorList...), GoldKey)
+	result := make([]map[string]int, 0)
+	current := make(map[string]int)
+	var walk func(i, left int)
+	walk = func(i, left int) {
+		if left == 0 {
+			option := make(map[string]int)
+			for k, v := range current {
+				if v > 0 {
+					option[k] = v
+				}
+			}
+			result = append(result, option)
+			return
+		} else if i == len(keys) {
+			return
+		}
+		for k := min(left, h.count(keys[i])); k >= 0; k-- {
+			current[keys[i]] = k
+			walk(i+1, left-k)
+		}
+		current[keys[i]] = 0
+	}
+	walk(0, n)
+	return result
+}
+
+// Combinations 从 items 中按顺序选出 k 个的所有组合，k 为 0 时没有组合
+func Combinations(items []string, k int) [][]string {
+	result := make([][]string, 0)
+	if k == 0 {
+		return result
+	}
+	current := make([]string, 0, k)
+	var walk func(start int)
+	walk = func(start int) {
+		if len(current) == k {
+			result = append(result, append([]string{}, current...))
+			return
+		}
+		for i := start; i < len(items); i++ {
+			current = append(current, items[i])
+			walk(i + 1)
+			current = current[:len(current)-1]
+		}
+	}
+	walk(0)
+	return result
+}
+
+func moveError(code, format string, args ...any) *MoveError {
+	return &MoveError{Code: code, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// 测试在 engine 目录中运行
+	CardFile = "../resources/cards.json"
+	os.Exit(m.Run())
+}
+
+// newStartedGame 创建并开始一局 n 名玩家的游戏
+func newStartedGame(t *testing.T, seed int64, rules Rules, n int) *Game {
+	t.Helper()
+	g := NewGame(seed, rules)
+	for i := 0; i < n; i++ {
+		g.AddPlayer("p")
+	}
+	if !g.StartGame() {
+		t.Fatal("the game can't start")
+	}
+	return g
+}
+
+// playRandom 由 rng 随机选择合法的回合，最多进行 turns 个回合，每个回合之前调用 check
+func playRandom(t *testing.T, g *Game, rng *rand.Rand, turns int, check func(pid int, moves []Move)) {
+	t.Helper()
+	for i := 0; i < turns && g.State == PlayingState; i++ {
+		pid := g.ActivePlayerId
+		moves := g.LegalMoves(pid)
+		if check != nil {
+			check(pid, moves)
+		}
+		if err := g.ApplyMove(pid, moves[rng.Intn(len(moves))]); err != nil {
+			t.Fatalf("turn %d: %v", i, err)
+		}
+	}
+}
+
+func TestValidateMoveRejects(t *testing.T) {
+	g := newStartedGame(t, 1, Rules{}, 2)
+	pid := g.ActivePlayerId
+	card := g.Table[0][0].Uuid
+	for _, c := range ColorList {
+		g.Gems[c] = 4
+	}
+	tests := []struct {
+		name string
+		pid  int
+		move Move
+		code string
+	}{
+		{"not your turn", 1 - pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}}, ErrNotYourTurn},
+		{"unknown type", pid, Move{Type: "jump"}, ErrInvalidMove},
+		{"two different gems", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1}}, ErrInvalidGems},
+		{"same color as different", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 2, "B": 1}}, ErrInvalidGems},
+		{"unknown color", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"X": 1, "B": 1, "G": 1}}, ErrInvalidGems},
+		{"negative gems", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": -1, "B": 1, "G": 1}}, ErrInvalidGems},
+		{"two colors as same", pid, Move{Type: MoveTakeSame, Gems: map[string]int{"W": 1, "B": 1}}, ErrInvalidGems},
+		{"gems with a card", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}, Card: card}, ErrInvalidMove},
+		{"choice with gems", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}, Choice: "W"}, ErrInvalidChoice},
+		{"unknown card", pid, Move{Type: MoveReserve, Card: "nope"}, ErrCardUnavailable},
+		{"reserve with gems", pid, Move{Type: MoveReserve, Card: card, Gems: map[string]int{"W": 1}}, ErrInvalidMove},
+		{"pile out of range", pid, Move{Type: MoveReservePile, Level: 4}, ErrPileEmpty},
+		{"buy unknown card", pid, Move{Type: MoveBuy, Card: "nope"}, ErrCardUnavailable},
+		{"buy without gems", pid, Move{Type: MoveBuy, Card: card}, ErrCannotAfford},
+		{"negative payment", pid, Move{Type: MoveBuy, Card: card, Payment: map[string]int{"W": -1}}, ErrInvalidPayment},
+		{"stronghold disabled", pid, Move{Type: MoveStronghold, Card: card}, ErrStronghold},
+		{"pass with moves left", pid, Move{Type: MovePass}, ErrInvalidMove},
+		{"needless discard", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}, Discards: map[string]int{"W": 1}}, ErrInvalidDiscards},
+		{"unknown noble", pid, Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}, Noble: "nope"}, ErrInvalidNoble},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.ValidateMove(tt.pid, tt.move)
+			var moveErr *MoveError
+			if !errors.As(err, &moveErr) {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			} else if moveErr.Code != tt.code {
+				t.Fatalf("got %s (%s), want %s", moveErr.Code, moveErr.Msg, tt.code)
+			}
+		})
+	}
+}
+
+func TestValidateMoveLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(g *Game, p *Player)
+		move  func(g *Game) Move
+		code  string
+	}{
+		{
+			"not playing",
+			func(g *Game, p *Player) { g.State = EndedState },
+			func(g *Game) Move { return Move{Type: MovePass} },
+			ErrNotPlaying,
+		},
+		{
+			"turn in progress",
+			func(g *Game, p *Player) { p.Taken["W"] = 1 },
+			func(g *Game) Move { return Move{Type: MovePass} },
+			ErrTurnInProgress,
+		},
+		{
+			"reserve limit",
+			func(g *Game, p *Player) { p.Reserved = append(p.Reserved, g.Piles[0][:*g.Rules.MaxReserve]...) },
+			func(g *Game) Move { return Move{Type: MoveReservePile, Level: 1} },
+			ErrReserveLimit,
+		},
+		{
+			"empty pile",
+			func(g *Game, p *Player) { g.Piles[2] = g.Piles[2][:0] },
+			func(g *Game) Move { return Move{Type: MoveReservePile, Level: 3} },
+			ErrPileEmpty,
+		},
+		{
+			"no gems left",
+			func(g *Game, p *Player) { g.Gems["W"] = 0 },
+			func(g *Game) Move { return Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}} },
+			ErrNotEnoughGems,
+		},
+		{
+			"two of a short color",
+			func(g *Game, p *Player) { g.Gems["W"] = 3 },
+			func(g *Game) Move { return Move{Type: MoveTakeSame, Gems: map[string]int{"W": 2}} },
+			ErrNotEnoughGems,
+		},
+		{
+			"missing discards",
+			func(g *Game, p *Player) { p.Gems["W"] = g.Rules.MaxGems },
+			func(g *Game) Move { return Move{Type: MoveTakeDifferent, Gems: map[string]int{"B": 1, "G": 1, "R": 1}} },
+			ErrInvalidDiscards,
+		},
+		{
+			"discarding gems not in hand",
+			func(g *Game, p *Player) { p.Gems["W"] = g.Rules.MaxGems },
+			func(g *Game) Move {
+				return Move{Type: MoveTakeDifferent, Gems: map[string]int{"B": 1, "G": 1, "R": 1}, Discards: map[string]int{"K": 3}}
+			},
+			ErrInvalidDiscards,
+		},
+		{
+			"overpaying",
+			func(g *Game, p *Player) {
+				for _, c := range ColorList {
+					p.Gems[c] = 7
+				}
+			},
+			func(g *Game) Move {
+				card := g.Table[0][0]
+				pay := make(map[string]int)
+				for c, n := range card.Cost {
+					if n > 0 {
+						pay[c] = n + 1
+					}
+				}
+				return Move{Type: MoveBuy, Card: card.Uuid, Payment: pay}
+			},
+			ErrInvalidPayment,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, 2, Rules{}, 2)
+			p := g.getActivePlayer()
+			tt.setup(g, p)
+			err := g.ValidateMove(p.Id, tt.move(g))
+			var moveErr *MoveError
+			if !errors.As(err, &moveErr) {
+				t.Fatalf("got %v, want %s", err, tt.code)
+			} else if moveErr.Code != tt.code {
+				t.Fatalf("got %s (%s), want %s", moveErr.Code, moveErr.Msg, tt.code)
+			}
+		})
+	}
+}
+
+func TestLegalMovesAreValid(t *testing.T) {
+	variants := []struct {
+		name  string
+		rules Rules
+	}{
+		{"standard", Rules{}},
+		{"orient", Rules{Orient: true}},
+		{"outposts", Rules{Outposts: true}},
+		{"strongholds", Rules{Strongholds: true}},
+	}
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			for seed := int64(1); seed <= 3; seed++ {
+				g := newStartedGame(t, seed, v.rules, 3)
+				rng := rand.New(rand.NewSource(seed))
+				playRandom(t, g, rng, 120, func(pid int, moves []Move) {
+					if len(moves) == 0 {
+						t.Fatal("no legal move")
+					}
+					for _, m := range moves {
+						if err := g.ValidateMove(pid, m); err != nil {
+							t.Fatalf("legal move %s rejected: %v", m.Key(), err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
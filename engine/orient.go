@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+)
+
+// 东方扩展卡牌的能力
+const (
+	AbilityDouble       = "double"        // 提供两个同色奖励
+	AbilityJoin         = "join"          // 没有颜色，买下时加入玩家已有的一种颜色
+	AbilityReserveNoble = "reserve_noble" // 买下时预定一个贵族，只有自己能够访问
+	AbilityFreeCard     = "free_card"     // 买下时免费拿取桌上一张低一级的卡牌
+	AbilitySacrifice    = "sacrifice"     // 以丢弃两个同色奖励代替支付宝石
+)
+
+const (
+	// OrientTableSize 东方扩展每个等级公开的卡牌数量
+	OrientTableSize = 2
+)
+
+var (
+	Abilities = []string{AbilityDouble, AbilityJoin, AbilityReserveNoble, AbilityFreeCard, AbilitySacrifice}
+)
+
+// NewOrientCards 按定义创建东方扩展的牌堆，与 NewCities 一样只在对应模式下调用
+func NewOrientCards(set *CardSet, rng *rand.Rand) [][]*DevCard {
+	piles := make([][]*DevCard, LevelNum)
+	for i := range piles {
+		piles[i] = make([]*DevCard, 0)
+	}
+	for _, def := range set.Orient {
+		card := newDevCard(rng, def)
+		card.Orient = true
+		piles[def.Level-1] = append(piles[def.Level-1], card)
+	}
+	return piles
+}
+
+// Bonus 卡牌提供的奖励数量
+func (c *DevCard) Bonus() int {
+	if c.Ability == AbilityDouble {
+		return 2
+	}
+	return 1
+}
+
+// Bonus 玩家某种颜色的奖励数量，双倍卡牌计为两个
+func (p *Player) Bonus(color string) int {
+	n := 0
+	for _, card := range p.Cards[color] {
+		n += card.Bonus()
+	}
+	return n
+}
+
+// TableCards 桌上所有公开的卡牌，包括东方扩展的卡牌
+func (g *Game) TableCards() []*DevCard {
+	cards := make([]*DevCard, 0)
+	for _, rows := range [][][]*DevCard{g.Table, g.OrientTable} {
+		for _, row := range rows {
+			for _, card := range row {
+				if card != nil {
+					cards = append(cards, card)
+				}
+			}
+		}
+	}
+	return cards
+}
+
+// needsChoice 买下后需要玩家选择的能力
+func needsChoice(ability string) bool {
+	return ability == AbilityJoin || ability == AbilityReserveNoble || ability == AbilityFreeCard
+}
+
+// AbilityOptions 等待选择的能力的所有选项：颜色、贵族或卡牌的 UUID
+func (p *Player) AbilityOptions() []string {
+	if p.Pending == nil {
+		return nil
+	}
+	return p.optionsFor(p.Pending)
+}
+
+// optionsFor 买下卡牌后能力的选项，在买下前后相同
+func (p *Player) optionsFor(card *DevCard) []string {
+	options := make([]string, 0)
+	switch card.Ability {
+	case AbilityJoin:
+		for _, c := range ColorList {
+			if len(p.Cards[c]) > 0 {
+				options = append(options, c)
+			}
+		}
+	case AbilityReserveNoble:
+		for _, n := range p.Game.Nobles {
+			options = append(options, n.Uuid)
+		}
+	case AbilityFreeCard:
+		for _, c := range p.Game.Table[card.Level-2] {
+			if c != nil && !p.Blocked(c) {
+				options = append(options, c.Uuid)
+			}
+		}
+	}
+	return options
+}
+
+// abilityError 卡牌的能力是否允许玩家买下
+func (p *Player) abilityError(card *DevCard) string {
+	switch card.Ability {
+	case AbilityJoin:
+		if len(p.optionsFor(card)) == 0 {
+			return "You need a card to join this card to"
+		}
+	case AbilitySacrifice:
+		if p.Bonus(card.Color) < 2 {
+			return "You need 2 bonuses of this color to sacrifice"
+		}
+	}
+	return ""
+}
+
+// Sacrifices 献祭时丢弃的卡牌，优先丢弃分数低的卡牌，双倍卡牌计为两个奖励
+func (p *Player) Sacrifices(card *DevCard) []*DevCard {
+	cards := append([]*DevCard{}, p.Cards[card.Color]...)
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].Points != cards[j].Points {
+			return cards[i].Points < cards[j].Points
+		}
+		return cards[i].Bonus() > cards[j].Bonus()
+	})
+	result := make([]*DevCard, 0)
+	for n := 0; n < 2 && len(cards) > 0; cards = cards[1:] {
+		result = append(result, cards[0])
+		n += cards[0].Bonus()
+	}
+	return result
+}
+
+// Choose 完成东方卡牌能力的选择
+func (p *Player) Choose(target string) string {
+	if p.Pending == nil {
+		return "There is nothing to choose"
+	}
+	for _, option := range p.AbilityOptions() {
+		if option == target {
+			p.resolveAbility(target)
+			return ""
+		}
+	}
+	return "You can't choose this"
+}
+
+// autoResolve 只有一个或没有选项时自动完成能力，否则等待玩家选择
+func (p *Player) autoResolve() {
+	if p.Pending == nil {
+		return
+	}
+	if options := p.AbilityOptions(); len(options) == 0 {
+		p.resolveAbility("")
+	} else if len(options) == 1 {
+		p.resolveAbility(options[0])
+	}
+}
+
+func (p *Player) resolveAbility(target string) {
+	p.Game.record(Event{Type: EventAbility, Pid: p.Id, Card: p.Pending.Uuid, Target: target})
+	p.Game.refillTable()
+	// join 和免费拿取的卡牌在能力完成后才计入奖励
+	p.checkPosts()
+}
+
+// availableNobles 玩家能够访问的贵族：桌上的贵族和自己预定的贵族
+func (p *Player) availableNobles() []*Noble {
+	return append(append([]*Noble{}, p.Game.Nobles...), p.ReservedNobles...)
+}
+
+func (g *Game) applySacrifice(e Event) error {
+	p := g.getActivePlayer()
+	card := g.CardMap[e.Card]
+	if p == nil || p.Id != e.Pid || card == nil {
+		return errors.New("invalid sacrifice")
+	}
+	for color, cards := range p.Cards {
+		for i, c := range cards {
+			if c.Uuid == card.Uuid {
+				p.Cards[color] = append(cards[:i:i], cards[i+1:]...)
+				p.Points -= card.Points
+				return nil
+			}
+		}
+	}
+	return errors.New("card is not owned")
+}
+
+func (g *Game) applyAbility(e Event) error {
+	p := g.getActivePlayer()
+	if p == nil || p.Id != e.Pid || p.Pending == nil || p.Pending.Uuid != e.Card {
+		return errors.New("no ability is waiting")
+	}
+	card := p.Pending
+	switch card.Ability {
+	case AbilityJoin:
+		if _, ok := ColorDict[e.Target]; !ok || len(p.Cards[e.Target]) == 0 {
+			return errors.New("invalid color to join")
+		}
+		p.Cards[e.Target] = append(p.Cards[e.Target], card)
+	case AbilityReserveNoble:
+		if e.Target == "" {
+			break
+		}
+		var noble *Noble
+		for i, n := range g.Nobles {
+			if n.Uuid == e.Target {
+				noble = n
+				g.Nobles = append(g.Nobles[:i:i], g.Nobles[i+1:]...)
+				break
+			}
+		}
+		if noble == nil {
+			return errors.New("noble is not available")
+		}
+		p.ReservedNobles = append(p.ReservedNobles, noble)
+	case AbilityFreeCard:
+		if e.Target == "" {
+			break
+		}
+		free := g.CardMap[e.Target]
+		if free == nil || free.Orient || free.Level != card.Level-1 || !g.removeFromTable(free) {
+			return errors.New("card is not on the table")
+		}
+		p.Cards[free.Color] = append(p.Cards[free.Color], free)
+		p.Points += free.Points
+	}
+	p.Pending = nil
+	return nil
+}
@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// placeOrient 把一张指定能力和等级的东方卡牌放到桌上，在牌堆中时与桌上的第一张交换
+func placeOrient(t *testing.T, g *Game, ability string, level int) *DevCard {
+	t.Helper()
+	row, pile := g.OrientTable[level-1], g.OrientPiles[level-1]
+	for _, c := range row {
+		if c != nil && c.Ability == ability {
+			return c
+		}
+	}
+	for i, c := range pile {
+		if c.Ability == ability {
+			pile[i], row[0] = row[0], c
+			return c
+		}
+	}
+	t.Fatalf("no %s card in level %d", ability, level)
+	return nil
+}
+
+// ownCard 给玩家一张登记过的卡牌，献祭时能够丢弃
+func ownCard(g *Game, p *Player, color, ability string) *DevCard {
+	card := &DevCard{Uuid: fmt.Sprintf("owned-%d", len(g.CardMap)), Color: color, Level: 1, Ability: ability}
+	g.CardMap[card.Uuid] = card
+	p.Cards[color] = append(p.Cards[color], card)
+	return card
+}
+
+func TestOrientAbilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		ability string
+		level   int
+		setup   func(g *Game, p *Player, card *DevCard)
+		choice  func(g *Game) string
+		code    string // 买下时的错误，为空时能够买下
+		check   func(g *Game, p *Player, card *DevCard) string
+	}{
+		{"double", AbilityDouble, 1, nil, nil, "", func(g *Game, p *Player, card *DevCard) string {
+			if n := p.Bonus(card.Color); n != 2 {
+				return fmt.Sprintf("got %d bonuses, want 2", n)
+			}
+			return ""
+		}},
+		{"join", AbilityJoin, 1, func(g *Game, p *Player, card *DevCard) {
+			ownCard(g, p, "R", "")
+			ownCard(g, p, "G", "")
+		}, func(g *Game) string {
+			return "G"
+		}, "", func(g *Game, p *Player, card *DevCard) string {
+			if p.Bonus("G") != 2 || p.Bonus("R") != 1 || p.Cards["G"][1] != card {
+				return fmt.Sprintf("got cards %v", p.Cards)
+			} else if p.Points != card.Points {
+				return fmt.Sprintf("got %d points, want %d", p.Points, card.Points)
+			}
+			return ""
+		}},
+		{"join without a choice", AbilityJoin, 1, func(g *Game, p *Player, card *DevCard) {
+			ownCard(g, p, "R", "")
+			ownCard(g, p, "G", "")
+		}, nil, ErrChoiceRequired, nil},
+		{"join without bonuses", AbilityJoin, 1, nil, nil, ErrCannotAfford, nil},
+		{"reserve noble", AbilityReserveNoble, 2, nil, func(g *Game) string {
+			return g.Nobles[1].Uuid
+		}, "", func(g *Game, p *Player, card *DevCard) string {
+			if len(p.ReservedNobles) != 1 || len(p.availableNobles()) != len(g.Nobles)+1 {
+				return fmt.Sprintf("reserved %v, nobles left %v", p.ReservedNobles, g.Nobles)
+			}
+			for _, n := range g.Nobles {
+				if n == p.ReservedNobles[0] {
+					return "the reserved noble is still on the table"
+				}
+			}
+			return ""
+		}},
+		{"free card", AbilityFreeCard, 2, nil, func(g *Game) string {
+			return g.Table[0][0].Uuid
+		}, "", func(g *Game, p *Player, card *DevCard) string {
+			var free *DevCard
+			for _, c := range ColorList {
+				for _, owned := range p.Cards[c] {
+					if owned.Level == 1 {
+						free = owned
+					}
+				}
+			}
+			if free == nil || g.tableCard(free.Uuid) != nil {
+				return fmt.Sprintf("got cards %v", p.Cards)
+			} else if p.Points != card.Points+free.Points {
+				return fmt.Sprintf("got %d points, want %d", p.Points, card.Points+free.Points)
+			}
+			return ""
+		}},
+		{"sacrifice", AbilitySacrifice, 3, func(g *Game, p *Player, card *DevCard) {
+			for i := 0; i < 3; i++ {
+				ownCard(g, p, card.Color, "")
+			}
+		}, nil, "", func(g *Game, p *Player, card *DevCard) string {
+			if len(p.Cards[card.Color]) != 2 || p.Bonus(card.Color) != 2 {
+				return fmt.Sprintf("got cards %v", p.Cards[card.Color])
+			}
+			return ""
+		}},
+		{"sacrifice a double card", AbilitySacrifice, 3, func(g *Game, p *Player, card *DevCard) {
+			ownCard(g, p, card.Color, "")
+			ownCard(g, p, card.Color, AbilityDouble)
+		}, nil, "", func(g *Game, p *Player, card *DevCard) string {
+			// 双倍卡牌计为两个奖励，只丢弃这一张
+			cards := p.Cards[card.Color]
+			if len(cards) != 2 || cards[0].Ability != "" || p.Bonus(card.Color) != 2 {
+				return fmt.Sprintf("got cards %v", cards)
+			}
+			return ""
+		}},
+		{"sacrifice one bonus", AbilitySacrifice, 3, func(g *Game, p *Player, card *DevCard) {
+			ownCard(g, p, card.Color, "")
+			ownCard(g, p, ColorList[(colorIndex(card.Color)+1)%len(ColorList)], "")
+		}, nil, ErrCannotAfford, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, 1, Rules{Orient: true}, 2)
+			pid := g.ActivePlayerId
+			p := g.Players[pid]
+			card := placeOrient(t, g, tt.ability, tt.level)
+			for c, n := range card.Cost {
+				p.Gems[c] = n
+			}
+			if tt.setup != nil {
+				tt.setup(g, p, card)
+			}
+			m := Move{Type: MoveBuy, Card: card.Uuid}
+			if tt.choice != nil {
+				m.Choice = tt.choice(g)
+			}
+			err := g.ValidateMove(pid, m)
+			if tt.code != "" {
+				var moveErr *MoveError
+				if !errors.As(err, &moveErr) || moveErr.Code != tt.code {
+					t.Fatalf("got %v, want %s", err, tt.code)
+				} else if tt.code == ErrCannotAfford && p.Buy(card.Uuid) == "" {
+					// 行动接口在买下后才选择，只有不能买下时同样拒绝
+					t.Fatal("the card was bought through an action")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			} else if err := g.ApplyMove(pid, m); err != nil {
+				t.Fatal(err)
+			} else if p.Pending != nil {
+				t.Fatalf("ability of %s is still pending", p.Pending.Uuid)
+			} else if g.tableCard(card.Uuid) != nil {
+				t.Fatal("the card is still on the table")
+			}
+			if info := tt.check(g, p, card); info != "" {
+				t.Fatal(info)
+			}
+		})
+	}
+}
+
+func colorIndex(color string) int {
+	for i, c := range ColorList {
+		if c == color {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,291 @@
+package engine
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+type Player struct {
+	Id       int
+	Name     string
+	Uuid     string
+	Game     *Game `json:"-"`
+	Gems     map[string]int
+	Golds    int
+	Cards    map[string][]*DevCard
+	Reserved []*DevCard
+	Nobles   []*Noble
+	City     *City // 城市模式下获得的城市
+	Points   int
+	Taken    map[string]int `json:"-"`
+	Visited  bool           `json:"-"`
+	Finished bool           `json:"-"`
+	// ReservedNobles 东方卡牌预定的贵族，Pending 为买下后等待选择能力的东方卡牌
+	ReservedNobles []*Noble
+	Pending        *DevCard `json:"-"`
+	// Posts 已解锁的前哨站，PostGemDue 为买卡后还没有拿取前哨站的宝石
+	Posts       []int
+	PostGemDue  bool `json:"-"`
+	Strongholds int  // 手中剩下的要塞
+}
+
+// NewPlayer 创建新玩家
+func NewPlayer(game *Game, id int, name string) *Player {
+	gems := make(map[string]int)
+	cards := make(map[string][]*DevCard)
+	for _, c := range ColorList {
+		gems[c] = 0
+		cards[c] = make([]*DevCard, 0)
+	}
+
+	return &Player{
+		Id:       id,
+		Name:     name,
+		Uuid:     uuid.New().String(),
+		Game:     game,
+		Gems:     gems,
+		Cards:    cards,
+		Reserved: make([]*DevCard, 0),
+		Nobles:   make([]*Noble, 0),
+		Points:   0,
+		Taken:    make(map[string]int),
+		Visited:  false,
+		Finished: false,
+
+		ReservedNobles: make([]*Noble, 0),
+		Posts:          make([]int, 0),
+	}
+}
+
+// TakeOne 拿取宝石
+func (p *Player) TakeOne(color string) string {
+	if p.Finished {
+		return "You have already acted"
+	} else if p.totalGems() >= p.Game.Rules.MaxGems {
+		return fmt.Sprintf("You already have %d gems", p.Game.Rules.MaxGems)
+	} else if color == GoldKey {
+		return "You can't take a 🟡"
+	} else if p.Game.Gems[color] == 0 {
+		return fmt.Sprintf("No %s left", ColorDict[color])
+	} else if p.Taken[color] == 1 && p.TakenNum() == 2 {
+		return "You have already taken 2 different gems"
+	} else if p.Taken[color] == 1 && p.Game.Gems[color] < 3 {
+		return fmt.Sprintf("There are not enough %s left", ColorDict[color])
+	}
+	p.Game.record(Event{Type: EventTake, Pid: p.Id, Color: color})
+	if p.TakenNum() < 3 && p.Taken[color] < 2 {
+		return "continue"
+	}
+	return ""
+}
+
+// Discard 丢弃宝石
+func (p *Player) Discard(color string) string {
+	if color == GoldKey {
+		if p.Golds == 0 {
+			return "You don't have any 🟡"
+		}
+	} else if p.Gems[color] == 0 {
+		return fmt.Sprintf("You don't have any %s", ColorDict[color])
+	}
+	p.Game.record(Event{Type: EventDiscard, Pid: p.Id, Color: color})
+	return ""
+}
+
+// Buy 购买卡牌
+func (p *Player) Buy(uuid string) string {
+	if p.Finished {
+		return "You have already acted"
+	} else if p.TakenNum() > 0 {
+		return "You have already taken gems"
+	}
+	card := p.Game.tableCard(uuid)
+	if card == nil {
+		card = p.reservedCard(uuid)
+	}
+	if card == nil {
+		return "This card is not available"
+	} else if p.Blocked(card) {
+		return "This card is guarded by another player's stronghold"
+	}
+	if info := p.abilityError(card); info != "" {
+		return info
+	}
+	// 计算需要支付的宝石
+	pay := p.defaultPayment(card)
+	if pay == nil {
+		return "Not enough gems"
+	}
+	p.buyCard(card, pay)
+	p.autoResolve()
+	return ""
+}
+
+// Reserve 预购卡牌
+func (p *Player) Reserve(uuid string) string {
+	if p.Finished {
+		return "You have already acted"
+	} else if p.TakenNum() > 0 {
+		return "You have already taken gems"
+	} else if len(p.Reserved) >= p.MaxReserve() {
+		return fmt.Sprintf("You have already reserved %d cards", p.MaxReserve())
+	} else if p.totalGems() >= p.Game.Rules.MaxGems && p.Game.Golds > 0 {
+		return "Discard a gem first"
+	}
+	// 首先检查是否是牌堆中的牌
+	if strings.Contains(uuid, "level") {
+		level := int(uuid[len(uuid)-1] - '0')
+		if level < 1 || level > len(p.Game.Piles) || len(p.Game.Piles[level-1]) == 0 {
+			return "No card left in this pile"
+		}
+		p.reservePile(level)
+		return ""
+	}
+	// 否则检查是否是桌上的牌
+	card := p.Game.tableCard(uuid)
+	if card == nil {
+		return "This card is not available"
+	} else if p.Blocked(card) {
+		return "This card is guarded by another player's stronghold"
+	}
+	p.reserveCard(card)
+	return ""
+}
+
+// CheckNobles 检查能够访问的贵族，包括自己预定的贵族
+func (p *Player) CheckNobles() []*Noble {
+	var nobles []*Noble
+	for _, n := range p.availableNobles() {
+		if len(p.NobleShortfall(n)) == 0 {
+			nobles = append(nobles, n)
+		}
+	}
+	return nobles
+}
+
+// NobleShortfall 访问贵族还缺少的各色发展卡数量
+func (p *Player) NobleShortfall(n *Noble) map[string]int {
+	missing := make(map[string]int)
+	for c, v := range n.Cost {
+		if d := v - p.Bonus(c); d > 0 {
+			missing[c] = d
+		}
+	}
+	return missing
+}
+
+// Shortfall 买下卡牌还缺少的各色宝石数量，未计入黄金
+func (p *Player) Shortfall(card *DevCard) map[string]int {
+	missing := make(map[string]int)
+	for c, v := range card.Cost {
+		if d := v - p.powerOf(c); d > 0 {
+			missing[c] = d
+		}
+	}
+	return missing
+}
+
+// CheckCities 检查能够获得的城市
+func (p *Player) CheckCities() []*City {
+	var cities []*City
+	for _, c := range p.Game.Cities {
+		if points, missing := p.CityShortfall(c); points == 0 && len(missing) == 0 {
+			cities = append(cities, c)
+		}
+	}
+	return cities
+}
+
+// CityShortfall 获得城市还缺少的分数和各色发展卡数量
+func (p *Player) CityShortfall(c *City) (points int, missing map[string]int) {
+	missing = make(map[string]int)
+	for color, v := range c.Cost {
+		if d := v - p.Bonus(color); d > 0 {
+			missing[color] = d
+		}
+	}
+	return max(0, c.Points-p.Points), missing
+}
+
+// DoVisit 执行访问贵族
+func (p *Player) DoVisit(noble *Noble) {
+	p.Game.record(Event{Type: EventNobleVisit, Pid: p.Id, Noble: noble.Uuid})
+}
+
+// StartTurn 开始回合
+func (p *Player) StartTurn() {
+	p.Finished = false
+	p.Visited = false
+	p.Taken = make(map[string]int)
+}
+
+// TakenNum 返回玩家在本回合已拿取的宝石数量
+func (p *Player) TakenNum() int {
+	return valueSum(p.Taken)
+}
+
+// CardNum 返回玩家购买的发展卡数量
+func (p *Player) CardNum() int {
+	n := 0
+	for _, cards := range p.Cards {
+		n += len(cards)
+	}
+	return n
+}
+
+func (p *Player) powerOf(color string) int {
+	return p.Bonus(color) + p.Gems[color]
+}
+
+func (p *Player) totalGems() int {
+	return valueSum(p.Gems) + p.Golds
+}
+
+// buyCard 按照支付方案购买卡牌，献祭卡牌先丢弃奖励
+func (p *Player) buyCard(card *DevCard, pay map[string]int) {
+	if card.Ability == AbilitySacrifice {
+		for _, c := range p.Sacrifices(card) {
+			p.Game.record(Event{Type: EventSacrifice, Pid: p.Id, Card: c.Uuid})
+		}
+	}
+	payment := make(map[string]int)
+	for c, n := range pay {
+		if n > 0 {
+			payment[c] = n
+		}
+	}
+	fromReserve := p.reservedCard(card.Uuid) != nil
+	p.Game.record(Event{Type: EventBuy, Pid: p.Id, Card: card.Uuid, Payment: payment, FromReserve: fromReserve})
+	p.Game.refillTable()
+	p.checkPosts()
+}
+
+// reserveCard 预定桌上的卡牌
+func (p *Player) reserveCard(card *DevCard) {
+	p.Game.record(Event{Type: EventReserve, Pid: p.Id, Card: card.Uuid, Gold: min(p.Game.Golds, 1)})
+	p.Game.refillTable()
+}
+
+// reservePile 预定牌堆顶的卡牌
+func (p *Player) reservePile(level int) {
+	card := p.Game.Piles[level-1][0]
+	p.Game.record(Event{Type: EventReserve, Pid: p.Id, Card: card.Uuid, Level: level, Gold: min(p.Game.Golds, 1)})
+}
+
+// claimCity 获得城市
+func (p *Player) claimCity(city *City) {
+	p.Game.record(Event{Type: EventCityClaim, Pid: p.Id, City: city.Uuid})
+}
+
+func newPlayerUuid() string {
+	return uuid.New().String()
+}
+
+func valueSum(m map[string]int) int {
+	var sum int
+	for _, v := range m {
+		sum += v
+	}
+	return sum
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 前哨站的能力，前哨站是本项目的自定规则，与官方的贸易站模块无关：
+// 前哨站不在地图上，没有顺序要求，玩家的奖励满足要求后立即解锁，每个玩家都可以解锁每个前哨站
+const (
+	PostGem     = "post_gem"      // 每次买下卡牌后拿取一个任意颜色的宝石
+	PostReserve = "post_reserve"  // 预定卡牌的上限加一
+	PostPoints  = "post_points"   // 解锁时获得 OutpostBonusPoints 分
+	PostPerPost = "post_per_post" // 每个已解锁的前哨站 1 分，包括这一个
+)
+
+const (
+	// OutpostBonusPoints 解锁 PostPoints 前哨站获得的分数
+	OutpostBonusPoints = 5
+)
+
+// Outpost 前哨站，玩家的奖励满足 Cost 时解锁，解锁后永久拥有能力，每个玩家都可以解锁
+type Outpost struct {
+	Id      int
+	Ability string
+	Cost    map[string]int
+	Caption string
+}
+
+var (
+	// Outposts 所有前哨站，Id 从 1 开始
+	Outposts = []*Outpost{
+		newOutpost(1, PostGem, map[string]int{"R": 3, "W": 1}),
+		newOutpost(2, PostReserve, map[string]int{"W": 2, "K": 2}),
+		newOutpost(3, PostPoints, map[string]int{"K": 5}),
+		newOutpost(4, PostPerPost, map[string]int{"B": 3, "G": 3}),
+	}
+)
+
+func newOutpost(id int, ability string, cost map[string]int) *Outpost {
+	return &Outpost{
+		Id:      id,
+		Ability: ability,
+		Cost:    fullCost(cost),
+		Caption: beautifyCaption(fmt.Sprintf("(%d)[%s] %s", id, costLine(cost), ability)),
+	}
+}
+
+func findPost(id int) *Outpost {
+	for _, post := range Outposts {
+		if post.Id == id {
+			return post
+		}
+	}
+	return nil
+}
+
+// HasPost 玩家是否已解锁有该能力的前哨站
+func (p *Player) HasPost(ability string) bool {
+	for _, id := range p.Posts {
+		if findPost(id).Ability == ability {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxReserve 玩家预定卡牌的上限
+func (p *Player) MaxReserve() int {
+	if p.HasPost(PostReserve) {
+		return *p.Game.Rules.MaxReserve + 1
+	}
+	return *p.Game.Rules.MaxReserve
+}
+
+// PostShortfall 玩家距离解锁前哨站还缺少的发展卡
+func (p *Player) PostShortfall(post *Outpost) map[string]int {
+	missing := make(map[string]int)
+	for c, v := range post.Cost {
+		if d := v - p.Bonus(c); d > 0 {
+			missing[c] = d
+		}
+	}
+	return missing
+}
+
+// checkPosts 解锁奖励已满足的前哨站，只在买卡和完成东方卡牌的能力后调用，拿取宝石等回合不会解锁
+func (p *Player) checkPosts() {
+	if !p.Game.Rules.Outposts {
+		return
+	}
+	for _, post := range Outposts {
+		if !p.HasPost(post.Ability) && len(p.PostShortfall(post)) == 0 {
+			p.Game.record(Event{Type: EventPostUnlock, Pid: p.Id, Post: post.Id})
+		}
+	}
+}
+
+// postGemOptions 前哨站宝石可以选择的颜色
+func (p *Player) postGemOptions() []string {
+	options := make([]string, 0)
+	for _, c := range ColorList {
+		if p.Game.Gems[c] > 0 {
+			options = append(options, c)
+		}
+	}
+	return options
+}
+
+// TakePostGem 拿取前哨站的宝石
+func (p *Player) TakePostGem(color string) string {
+	if !p.PostGemDue {
+		return "You can't take a gem from an outpost now"
+	} else if _, ok := ColorDict[color]; !ok || p.Game.Gems[color] == 0 {
+		return "You can't take this gem"
+	}
+	p.Game.record(Event{Type: EventPostGem, Pid: p.Id, Color: color})
+	return ""
+}
+
+// PendingChoice 等待玩家选择的能力和选项，东方卡牌的能力先于前哨站的宝石
+func (p *Player) PendingChoice() (string, []string) {
+	if p.Pending != nil {
+		return p.Pending.Ability, p.AbilityOptions()
+	} else if p.PostGemDue {
+		return PostGem, p.postGemOptions()
+	}
+	return "", nil
+}
+
+// postPoints 解锁前哨站时获得的分数，posts 已包括刚解锁的前哨站
+func postPoints(posts []int, post *Outpost) int {
+	points := 0
+	if post.Ability == PostPoints {
+		points += OutpostBonusPoints
+	}
+	if post.Ability == PostPerPost {
+		points += len(posts)
+	} else {
+		for _, id := range posts {
+			if findPost(id).Ability == PostPerPost {
+				points++
+			}
+		}
+	}
+	return points
+}
+
+func (g *Game) applyPostUnlock(e Event) error {
+	p := g.playerOf(e.Pid)
+	post := findPost(e.Post)
+	if p == nil || post == nil || p.HasPost(post.Ability) {
+		return errors.New("invalid outpost")
+	}
+	p.Posts = append(p.Posts, post.Id)
+	p.Points += postPoints(p.Posts, post)
+	return nil
+}
+
+func (g *Game) applyPostGem(e Event) error {
+	p := g.getActivePlayer()
+	if p == nil || p.Id != e.Pid || !p.PostGemDue || g.Gems[e.Color] == 0 {
+		return errors.New("invalid outpost gem")
+	}
+	g.Gems[e.Color]--
+	p.Gems[e.Color]++
+	p.PostGemDue = false
+	return nil
+}
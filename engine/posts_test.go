@@ -0,0 +1,45 @@
+package engine
+
+import "testing"
+
+// TestPostsUnlockAfterBuying 奖励满足要求的前哨站只在买卡后解锁，拿取宝石的回合不会解锁
+func TestPostsUnlockAfterBuying(t *testing.T) {
+	g := newStartedGame(t, 1, Rules{Outposts: true}, 2)
+	pid := g.ActivePlayerId
+	p := g.Players[pid]
+	// 直接给玩家五张黑色卡牌，满足 PostPoints 前哨站的要求
+	for i := 0; i < 5; i++ {
+		p.Cards["K"] = append(p.Cards["K"], &DevCard{Color: "K", Level: 1})
+	}
+	take := Move{Type: MoveTakeDifferent, Gems: map[string]int{"W": 1, "B": 1, "G": 1}}
+	if err := g.ApplyMove(pid, take); err != nil {
+		t.Fatal(err)
+	} else if len(p.Posts) != 0 {
+		t.Fatalf("posts %v unlocked by taking gems", p.Posts)
+	}
+	other := g.ActivePlayerId
+	if err := g.ApplyMove(other, Move{Type: MoveTakeDifferent, Gems: map[string]int{"R": 1, "B": 1, "G": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range ColorList {
+		p.Gems[c] = 7
+	}
+	var buy *Move
+	for _, m := range g.LegalMoves(pid) {
+		if m.Type == MoveBuy {
+			buy = &m
+			break
+		}
+	}
+	if buy == nil {
+		t.Fatal("no card to buy")
+	}
+	points := p.Points + g.CardMap[buy.Card].Points
+	if err := g.ApplyMove(pid, *buy); err != nil {
+		t.Fatal(err)
+	} else if !p.HasPost(PostPoints) {
+		t.Fatalf("got posts %v after buying, want post %d", p.Posts, 3)
+	} else if p.Points != points+OutpostBonusPoints {
+		t.Fatalf("got %d points, want %d", p.Points, points+OutpostBonusPoints)
+	}
+}
@@ -0,0 +1,144 @@
+package engine
+
+// PlayerReport 一名玩家在整局游戏中的统计，宝石的键为 ColorList 中的颜色
+type PlayerReport struct {
+	Id     int
+	Name   string
+	Points int
+	Turns  int
+	// 拿取、买卡支付和丢弃的宝石
+	GemsTaken     map[string]int
+	GemsSpent     map[string]int
+	GemsDiscarded map[string]int
+	// 预定得到、买卡支付和丢弃的黄金
+	GoldsGained    int
+	GoldsSpent     int
+	GoldsDiscarded int
+	// 按等级和颜色统计买下的卡牌，CardsByLevel[0] 为一级卡牌
+	CardsByLevel []map[string]int
+	FromReserve  int
+	// PointsCurve 每个回合结束时的分数
+	PointsCurve []int
+	Nobles      []NobleTiming
+	// 浪费的回合：预定后没有买下的卡牌、需要丢弃宝石的回合和什么都没做的回合
+	Reserved         int
+	ReservedUnbought int
+	DiscardTurns     int
+	PassTurns        int
+}
+
+// NobleTiming 贵族在玩家的第几个回合来访
+type NobleTiming struct {
+	Noble string
+	Turn  int
+}
+
+// Report 由事件生成每名玩家的统计，游戏未开始时返回空列表
+func (g *Game) Report() []*PlayerReport {
+	reports := make([]*PlayerReport, 0, g.PlayerNum)
+	for _, p := range g.Players[:g.PlayerNum] {
+		r := &PlayerReport{
+			Id:            p.Id,
+			Name:          p.Name,
+			GemsTaken:     make(map[string]int),
+			GemsSpent:     make(map[string]int),
+			GemsDiscarded: make(map[string]int),
+			CardsByLevel:  make([]map[string]int, len(g.Piles)),
+			PointsCurve:   make([]int, 0),
+			Nobles:        make([]NobleTiming, 0),
+		}
+		for i := range r.CardsByLevel {
+			r.CardsByLevel[i] = make(map[string]int)
+		}
+		reports = append(reports, r)
+	}
+	// 本回合是否有行动、是否丢弃过宝石
+	acted, discarded := false, false
+	// 每名玩家已解锁的前哨站，join 卡牌加入的颜色
+	posts := make([][]int, len(reports))
+	joined := make(map[string]string)
+	for _, e := range g.Events {
+		if e.Pid < 0 || e.Pid >= len(reports) {
+			continue
+		}
+		r := reports[e.Pid]
+		switch e.Type {
+		case EventTake:
+			r.GemsTaken[e.Color]++
+			acted = true
+		case EventPostGem:
+			r.GemsTaken[e.Color]++
+		case EventPostUnlock:
+			posts[e.Pid] = append(posts[e.Pid], e.Post)
+			r.Points += postPoints(posts[e.Pid], findPost(e.Post))
+		case EventStronghold, EventRaze:
+			acted = true
+		case EventDiscard:
+			if e.Color == GoldKey {
+				r.GoldsDiscarded++
+			} else {
+				r.GemsDiscarded[e.Color]++
+			}
+			discarded = true
+		case EventBuy:
+			card := g.CardMap[e.Card]
+			for c, n := range e.Payment {
+				if c == GoldKey {
+					r.GoldsSpent += n
+				} else {
+					r.GemsSpent[c] += n
+				}
+			}
+			// join 卡牌在选择颜色后计入
+			if card.Ability != AbilityJoin {
+				r.CardsByLevel[card.Level-1][card.Color]++
+			}
+			r.Points += card.Points
+			if e.FromReserve {
+				r.FromReserve++
+			}
+			acted = true
+		case EventSacrifice:
+			card := g.CardMap[e.Card]
+			color := card.Color
+			if c, exists := joined[card.Uuid]; exists {
+				color = c
+			}
+			r.CardsByLevel[card.Level-1][color]--
+			r.Points -= card.Points
+		case EventAbility:
+			card := g.CardMap[e.Card]
+			if card.Ability == AbilityJoin {
+				joined[card.Uuid] = e.Target
+				r.CardsByLevel[card.Level-1][e.Target]++
+			} else if card.Ability == AbilityFreeCard && e.Target != "" {
+				free := g.CardMap[e.Target]
+				r.CardsByLevel[free.Level-1][free.Color]++
+				r.Points += free.Points
+			}
+		case EventReserve:
+			r.Reserved++
+			r.GoldsGained += e.Gold
+			acted = true
+		case EventNobleVisit:
+			r.Points += NoblePoints
+			// 贵族在回合结束前来访，属于正在进行的回合
+			r.Nobles = append(r.Nobles, NobleTiming{Noble: e.Noble, Turn: r.Turns + 1})
+		case EventTurnEnd:
+			r.Turns++
+			r.PointsCurve = append(r.PointsCurve, r.Points)
+			if discarded {
+				r.DiscardTurns++
+			}
+			if !acted {
+				r.PassTurns++
+			}
+			acted, discarded = false, false
+		}
+	}
+	// 游戏结束时仍在手中的预定卡牌
+	for _, p := range g.Players[:g.PlayerNum] {
+		reports[p.Id].ReservedUnbought = len(p.Reserved)
+	}
+	return reports
+}
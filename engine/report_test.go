@@ -0,0 +1,29 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// TestReportMatchesPlayers 统计中的卡牌和分数应与玩家的状态一致，包括献祭和免费拿取的卡牌
+func TestReportMatchesPlayers(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		g := newStartedGame(t, seed, Rules{Orient: true, Outposts: true}, 3)
+		playRandom(t, g, rand.New(rand.NewSource(seed)), 400, nil)
+		for _, r := range g.Report() {
+			p := g.Players[r.Id]
+			if r.Points != p.Points {
+				t.Errorf("seed %d player %d: report has %d points, want %d", seed, r.Id, r.Points, p.Points)
+			}
+			for _, c := range ColorList {
+				n := 0
+				for _, level := range r.CardsByLevel {
+					n += level[c]
+				}
+				if n != len(p.Cards[c]) {
+					t.Errorf("seed %d player %d: report has %d %s cards, want %d", seed, r.Id, n, c, len(p.Cards[c]))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package engine
+
+import "fmt"
+
+// Rules 房间规则，创建游戏时确定，零值字段使用标准规则
+// 可以为 0 的数量使用指针，nil 表示使用标准规则
+type Rules struct {
+	WinPoints  int   `json:"win_points,omitempty"`
+	MaxGems    int   `json:"max_gems,omitempty"`    // 手中宝石（包括黄金）的上限
+	MaxReserve *int  `json:"max_reserve,omitempty"` // 预定卡牌的上限，为 0 时不能预定
+	Golds      *int  `json:"golds,omitempty"`
+	GemSupply  []int `json:"gem_supply,omitempty"` // 2、3、4 人时每种颜色的宝石数量
+	Nobles     *int  `json:"nobles,omitempty"`     // 贵族数量，标准规则为玩家数量加一
+	TableSize  int   `json:"table_size,omitempty"` // 每个等级公开的卡牌数量
+	// Cities 城市扩展：用城市代替贵族，有玩家获得城市时游戏进入最后一轮，WinPoints 不再使用
+	Cities bool `json:"cities,omitempty"`
+	// Orient 东方扩展：桌上多一组带有能力的卡牌
+	Orient bool `json:"orient,omitempty"`
+	// Outposts 前哨站：买卡后解锁奖励满足要求的前哨站，获得永久的能力
+	// 前哨站及其能力是本项目的自定规则，不是官方的贸易站模块
+	Outposts bool `json:"outposts,omitempty"`
+	// Strongholds 要塞模块：玩家可以用一个回合在桌上的卡牌上放置要塞或摧毁其他玩家的要塞
+	// 这是简化的要塞规则，与官方规则的区别见 StrongholdNum
+	Strongholds bool `json:"strongholds,omitempty"`
+}
+
+var (
+	DefaultGemSupply = []int{4, 5, 7}
+)
+
+// withDefaults 用标准规则补全未指定的字段
+func (r Rules) withDefaults() Rules {
+	if r.WinPoints == 0 {
+		r.WinPoints = WinPoints
+	}
+	if r.MaxGems == 0 {
+		r.MaxGems = MaxGems
+	}
+	if r.MaxReserve == nil {
+		r.MaxReserve = intPtr(MaxReserve)
+	}
+	if r.Golds == nil {
+		r.Golds = intPtr(TotalGolds)
+	}
+	if len(r.GemSupply) == 0 {
+		r.GemSupply = DefaultGemSupply
+	}
+	if r.TableSize == 0 {
+		r.TableSize = TableSize
+	}
+	return r
+}
+
+// Validate 检查规则是否在合理范围内，零值字段表示使用标准规则
+// 不读取卡牌定义，卡牌是否足够由 CheckCardSet 检查
+func (r Rules) Validate() error {
+	check := func(name string, v, lo, hi int) error {
+		if v != 0 && (v < lo || v > hi) {
+			return fmt.Errorf("%s must be between %d and %d", name, lo, hi)
+		}
+		return nil
+	}
+	checkCount := func(name string, v *int, hi int) error {
+		if v != nil && (*v < 0 || *v > hi) {
+			return fmt.Errorf("%s must be between %d and %d", name, 0, hi)
+		}
+		return nil
+	}
+	if err := check("win_points", r.WinPoints, 1, 99); err != nil {
+		return err
+	} else if err := check("max_gems", r.MaxGems, 3, 30); err != nil {
+		return err
+	} else if err := checkCount("max_reserve", r.MaxReserve, 10); err != nil {
+		return err
+	} else if err := checkCount("golds", r.Golds, 20); err != nil {
+		return err
+	} else if err := checkCount("nobles", r.Nobles, 20); err != nil {
+		return err
+	} else if err := check("table_size", r.TableSize, 1, 6); err != nil {
+		return err
+	}
+	if r.Cities && r.Nobles != nil {
+		return fmt.Errorf("nobles can't be set when playing with cities")
+	}
+	if len(r.GemSupply) == 0 {
+		return nil
+	} else if len(r.GemSupply) != MaxPlayers-1 {
+		return fmt.Errorf("gem_supply must have %d values, for 2 to %d players", MaxPlayers-1, MaxPlayers)
+	}
+	for _, n := range r.GemSupply {
+		if n < 1 || n > 20 {
+			return fmt.Errorf("gem_supply must be between %d and %d", 1, 20)
+		}
+	}
+	return nil
+}
+
+// CheckCardSet 检查卡牌定义是否足够用于这些规则，规则应已通过 Validate
+func (r Rules) CheckCardSet(set *CardSet) error {
+	r = r.withDefaults()
+	for l := 1; l <= LevelNum; l++ {
+		if n := set.countLevel(l); n < r.TableSize {
+			return fmt.Errorf("the card set has %d cards of level %d, at least %d are needed to fill the table", n, l, r.TableSize)
+		}
+	}
+	if r.Cities && len(set.Cities) < CityNum {
+		return fmt.Errorf("the card set defines %d cities, at least %d are needed to play with cities", len(set.Cities), CityNum)
+	} else if r.Orient && len(set.Orient) == 0 {
+		return fmt.Errorf("the card set doesn't define any orient cards")
+	} else if r.Nobles != nil && *r.Nobles > len(set.Nobles) {
+		return fmt.Errorf("the card set defines %d nobles, %d are needed", len(set.Nobles), *r.Nobles)
+	}
+	return nil
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// nobleCount 当前玩家数量下的贵族数量，城市模式下没有贵族
+// 贵族是否足够由 CheckCardSet 检查，卡牌定义至少有 MaxPlayers+1 个贵族
+func (g *Game) nobleCount() int {
+	if g.Rules.Cities {
+		return 0
+	} else if g.Rules.Nobles != nil {
+		return *g.Rules.Nobles
+	}
+	return g.PlayerNum + 1
+}
+
+// cityCount 城市模式下摆出的城市数量，与玩家数量无关
+func (g *Game) cityCount() int {
+	if !g.Rules.Cities {
+		return 0
+	}
+	return min(CityNum, len(g.AllCities))
+}
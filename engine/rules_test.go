@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestZeroCountRules(t *testing.T) {
+	var rules Rules
+	if err := json.Unmarshal([]byte(`{"golds":0,"nobles":0,"max_reserve":0}`), &rules); err != nil {
+		t.Fatal(err)
+	} else if err := rules.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	g := newStartedGame(t, 1, rules, 3)
+	if g.Golds != 0 {
+		t.Errorf("got %d golds, want 0", g.Golds)
+	} else if len(g.Nobles) != 0 {
+		t.Errorf("got %d nobles, want 0", len(g.Nobles))
+	}
+	for _, m := range g.LegalMoves(g.ActivePlayerId) {
+		if m.Type == MoveReserve || m.Type == MoveReservePile {
+			t.Fatal("reserving is allowed with max_reserve 0")
+		}
+	}
+	// 未指定的数量使用标准规则
+	g = newStartedGame(t, 1, Rules{}, 3)
+	if g.Golds != TotalGolds || len(g.Nobles) != 4 {
+		t.Errorf("got %d golds and %d nobles, want %d and %d", g.Golds, len(g.Nobles), TotalGolds, 4)
+	}
+}
+
+func TestCheckCardSet(t *testing.T) {
+	set, err := DefaultCardSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := *set
+	small.Cards = nil
+	for l := 1; l <= LevelNum; l++ {
+		for _, c := range set.Cards {
+			if c.Level == l && small.countLevel(l) < TableSize {
+				small.Cards = append(small.Cards, c)
+			}
+		}
+	}
+	noCities := *set
+	noCities.Cities = nil
+	noOrient := *set
+	noOrient.Orient = nil
+	tests := []struct {
+		name  string
+		set   *CardSet
+		rules Rules
+		ok    bool
+	}{
+		{"standard", set, Rules{}, true},
+		{"large table", set, Rules{TableSize: 6}, true},
+		{"small set", &small, Rules{}, true},
+		{"small set and large table", &small, Rules{TableSize: 5}, false},
+		{"cities", set, Rules{Cities: true}, true},
+		{"no cities", &noCities, Rules{Cities: true}, false},
+		{"orient", set, Rules{Orient: true}, true},
+		{"no orient cards", &noOrient, Rules{Orient: true}, false},
+		{"all nobles", set, Rules{Nobles: intPtr(len(set.Nobles))}, true},
+		{"too many nobles", set, Rules{Nobles: intPtr(len(set.Nobles) + 1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rules.CheckCardSet(tt.set); (err == nil) != tt.ok {
+				t.Fatalf("got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"errors"
+	"slices"
+)
+
+// 要塞是简化的规则，与官方的要塞模块不同：放置或摧毁一个要塞都占用整个回合，摧毁不需要代价，
+// 同一玩家在一张卡牌上放满 StrongholdNum 个要塞时占领这张卡牌，这些要塞不能再被摧毁
+const (
+	// StrongholdNum 要塞模块中每个玩家的要塞数量，也是占领一张卡牌需要的要塞数量
+	StrongholdNum = 3
+)
+
+// Blocked 卡牌上是否有其他玩家的要塞，有时不能购买或预定，占领卡牌的玩家不受其他要塞的影响
+func (p *Player) Blocked(card *DevCard) bool {
+	if pid := p.Game.claimedBy(card.Uuid); pid >= 0 {
+		return pid != p.Id
+	}
+	for _, pid := range p.Game.Strongholds[card.Uuid] {
+		if pid != p.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// claimedBy 占领卡牌的玩家，没有被占领时为 -1
+func (g *Game) claimedBy(uuid string) int {
+	counts := make(map[int]int)
+	for _, pid := range g.Strongholds[uuid] {
+		if counts[pid]++; counts[pid] == StrongholdNum {
+			return pid
+		}
+	}
+	return -1
+}
+
+// strongholdError 放置或摧毁要塞是否合法，raze 为 true 时摧毁其他玩家的要塞
+func (p *Player) strongholdError(raze bool, uuid string) string {
+	if !p.Game.Rules.Strongholds {
+		return "Strongholds are not enabled"
+	} else if p.Finished {
+		return "You have already acted"
+	} else if p.TakenNum() > 0 {
+		return "You have already taken gems"
+	}
+	card := p.Game.tableCard(uuid)
+	if card == nil {
+		return "This card is not available"
+	} else if raze && !p.Blocked(card) {
+		return "There is no stronghold of another player on this card"
+	} else if pid := p.Game.claimedBy(uuid); pid >= 0 && pid != p.Id {
+		return "This card is claimed by another player's strongholds"
+	} else if !raze && p.Strongholds == 0 {
+		return "You have no stronghold left"
+	}
+	return ""
+}
+
+// PlaceStronghold 在桌上的卡牌上放置要塞
+func (p *Player) PlaceStronghold(uuid string) string {
+	if info := p.strongholdError(false, uuid); info != "" {
+		return info
+	}
+	p.Game.record(Event{Type: EventStronghold, Pid: p.Id, Card: uuid})
+	return ""
+}
+
+// RazeStronghold 摧毁卡牌上其他玩家最后放置的要塞，要塞回到主人手中
+func (p *Player) RazeStronghold(uuid string) string {
+	if info := p.strongholdError(true, uuid); info != "" {
+		return info
+	}
+	p.Game.record(Event{Type: EventRaze, Pid: p.Id, Card: uuid})
+	return ""
+}
+
+// releaseStrongholds 卡牌离开桌面时要塞回到主人手中
+func (g *Game) releaseStrongholds(card *DevCard) {
+	for _, pid := range g.Strongholds[card.Uuid] {
+		g.Players[pid].Strongholds++
+	}
+	delete(g.Strongholds, card.Uuid)
+}
+
+func (g *Game) applyStronghold(e Event) error {
+	p := g.getActivePlayer()
+	if p == nil || p.Id != e.Pid || p.Strongholds == 0 || g.tableCard(e.Card) == nil {
+		return errors.New("invalid stronghold")
+	} else if pid := g.claimedBy(e.Card); pid >= 0 && pid != p.Id {
+		return errors.New("card is claimed")
+	}
+	g.Strongholds[e.Card] = append(g.Strongholds[e.Card], p.Id)
+	p.Strongholds--
+	p.Finished = true
+	return nil
+}
+
+func (g *Game) applyRaze(e Event) error {
+	p := g.getActivePlayer()
+	if p == nil || p.Id != e.Pid {
+		return errors.New("not the active player")
+	} else if pid := g.claimedBy(e.Card); pid >= 0 && pid != p.Id {
+		return errors.New("card is claimed")
+	}
+	pids := g.Strongholds[e.Card]
+	for i := len(pids) - 1; i >= 0; i-- {
+		if pids[i] != p.Id {
+			g.Players[pids[i]].Strongholds++
+			g.Strongholds[e.Card] = slices.Delete(pids, i, i+1)
+			p.Finished = true
+			return nil
+		}
+	}
+	return errors.New("no stronghold to raze")
+}
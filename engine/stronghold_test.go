@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+// TestStrongholds 要塞阻止其他玩家购买和预定卡牌，放满 StrongholdNum 个要塞后卡牌被占领
+func TestStrongholds(t *testing.T) {
+	type step struct {
+		second bool // 由第二个玩家执行
+		move   MoveType
+		code   string // 校验的错误，为空时执行回合
+	}
+	first, second := false, true
+	tests := []struct {
+		name  string
+		steps []step
+		left  [2]int // 最后两名玩家手中的要塞
+	}{
+		{"guard", []step{
+			{first, MoveStronghold, ""},
+			{second, MoveBuy, ErrCardGuarded},
+			{second, MoveReserve, ErrCardGuarded},
+			{second, MoveRaze, ""},
+		}, [2]int{StrongholdNum, StrongholdNum}},
+		{"raze own stronghold", []step{
+			{first, MoveStronghold, ""},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveRaze, ErrStronghold},
+		}, [2]int{StrongholdNum - 1, StrongholdNum}},
+		{"claim", []step{
+			{first, MoveStronghold, ""},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveStronghold, ""},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveStronghold, ""},
+			{second, MoveRaze, ErrStronghold},
+			{second, MoveStronghold, ErrStronghold},
+			{second, MoveBuy, ErrCardGuarded},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveBuy, ""},
+		}, [2]int{StrongholdNum, StrongholdNum}},
+		{"claim over another stronghold", []step{
+			{first, MoveTakeDifferent, ""},
+			{second, MoveStronghold, ""},
+			{first, MoveStronghold, ""},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveStronghold, ""},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveStronghold, ""},
+			{second, MoveRaze, ErrStronghold},
+			{second, MoveTakeDifferent, ""},
+			{first, MoveBuy, ""},
+		}, [2]int{StrongholdNum, StrongholdNum}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartedGame(t, 1, Rules{Strongholds: true}, 2)
+			players := [2]*Player{g.getActivePlayer(), g.Players[1-g.ActivePlayerId]}
+			card := g.Table[0][0]
+			for i, s := range tt.steps {
+				p := players[0]
+				if s.second {
+					p = players[1]
+				}
+				m := Move{Type: s.move, Card: card.Uuid}
+				switch s.move {
+				case MoveTakeDifferent:
+					// 拿取桌上还有的三种颜色
+					m = Move{Type: MoveTakeDifferent, Gems: make(map[string]int)}
+					for _, c := range ColorList {
+						if g.Gems[c] > 0 && len(m.Gems) < 3 {
+							m.Gems[c] = 1
+						}
+					}
+				case MoveBuy:
+					// 手中的宝石正好买下卡牌
+					for _, c := range ColorList {
+						g.Gems[c] += p.Gems[c] - card.Cost[c]
+						p.Gems[c] = card.Cost[c]
+					}
+				}
+				err := g.ValidateMove(p.Id, m)
+				if s.code != "" {
+					var moveErr *MoveError
+					if !errors.As(err, &moveErr) || moveErr.Code != s.code {
+						t.Fatalf("step %d: got %v, want %s", i, err, s.code)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				} else if err := g.ApplyMove(p.Id, m); err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+			}
+			for i, p := range players {
+				if p.Strongholds != tt.left[i] {
+					t.Fatalf("player %d has %d strongholds, want %d", i, p.Strongholds, tt.left[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"sort"
+	"splendor-go/engine"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// GameArchive 已结束游戏的存档，为 nil 时不存档
+	GameArchive Archive
+)
+
+// Archive 已结束游戏的存档
+type Archive interface {
+	Put(r *ArchiveRecord) error
+	Get(id string) (*ArchiveRecord, error)
+	Search(q ArchiveQuery) ([]*ArchiveRecord, error)
+}
+
+// ArchiveRecord 一局已结束游戏的完整记录
+type ArchiveRecord struct {
+	Id        string            `json:"id"`
+	GameId    string            `json:"game"`
+	Players   []ArchivePlayer   `json:"players"`
+	Winner    *int              `json:"winner"`
+	Standings []engine.Standing `json:"standings,omitempty"`
+	Rules     engine.Rules      `json:"rules"`
+	Seed      int64             `json:"seed,string,omitempty"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+	Duration  int               `json:"duration"`
+	Turns     int               `json:"turns"`
+	Events    []engine.Event    `json:"events,omitempty"`
+	// Abandoned 中途放弃的游戏没有赢家和排名，种子和事件会暴露隐藏信息，不公开
+	Abandoned bool `json:"abandoned,omitempty"`
+}
+
+// ArchivePlayer 存档中的玩家信息
+type ArchivePlayer struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	Cards  int    `json:"cards"`
+	Nobles int    `json:"nobles"`
+	City   string `json:"city,omitempty"` // 城市模式下获得的城市
+}
+
+// ArchiveQuery 存档的搜索条件，零值表示不限制
+type ArchiveQuery struct {
+	GameId    string
+	Player    string
+	From      time.Time
+	To        time.Time
+	PlayerNum int
+}
+
+// NewArchiveRecord 由游戏生成存档记录，未结束的游戏记为中途放弃
+func NewArchiveRecord(gameId string, g *engine.Game) *ArchiveRecord {
+	r := &ArchiveRecord{
+		Id:        uuid.New().String(),
+		GameId:    gameId,
+		Players:   make([]ArchivePlayer, g.PlayerNum),
+		Turns:     len(engine.MoveBoundaries(g.Events)) - 1,
+		Events:    make([]engine.Event, len(g.Events)),
+		Abandoned: g.State != engine.EndedState,
+	}
+	// 玩家的 UUID 用于身份验证，不写入存档
+	for i, e := range g.Events {
+		e.Uuid = ""
+		r.Events[i] = e
+	}
+	for i, p := range g.Players[:g.PlayerNum] {
+		r.Players[i] = ArchivePlayer{
+			Id:     p.Id,
+			Name:   p.Name,
+			Score:  p.Points,
+			Cards:  p.CardNum(),
+			Nobles: len(p.Nobles),
+		}
+		if p.City != nil {
+			r.Players[i].City = p.City.Id
+		}
+	}
+	if !r.Abandoned {
+		if g.Winner != nil {
+			r.Winner = &g.Winner.Id
+		}
+		r.Standings = g.Standings
+		r.Seed = g.Seed
+	}
+	r.Rules = g.Rules
+	for _, e := range g.Events {
+		if e.Type == engine.EventStart {
+			r.StartTime = e.Time
+			break
+		}
+	}
+	r.EndTime = g.Events[len(g.Events)-1].Time
+	r.Duration = int(r.EndTime.Sub(r.StartTime).Seconds())
+	return r
+}
+
+// Match 判断存档是否满足搜索条件
+func (q ArchiveQuery) Match(r *ArchiveRecord) bool {
+	if q.GameId != "" && r.GameId != q.GameId {
+		return false
+	} else if q.PlayerNum > 0 && len(r.Players) != q.PlayerNum {
+		return false
+	} else if !q.From.IsZero() && r.EndTime.Before(q.From) {
+		return false
+	} else if !q.To.IsZero() && !r.EndTime.Before(q.To) {
+		return false
+	}
+	if q.Player == "" {
+		return true
+	}
+	for _, p := range r.Players {
+		if strings.EqualFold(p.Name, q.Player) {
+			return true
+		}
+	}
+	return false
+}
+
+// FileArchive 基于文件的存档，每局游戏保存为一个 JSON 文件，内存中只保留不含事件的摘要
+type FileArchive struct {
+	sync.RWMutex
+	Dir       string
+	summaries map[string]*ArchiveRecord
+}
+
+// NewFileArchive 创建基于文件的存档并加载已有的摘要
+func NewFileArchive(dir string) (*FileArchive, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	a := &FileArchive{
+		Dir:       dir,
+		summaries: make(map[string]*ArchiveRecord),
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		r, err := a.read(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		a.summaries[r.Id] = summaryOf(r)
+	}
+	return a, nil
+}
+
+// Put 保存存档
+func (a *FileArchive) Put(r *ArchiveRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	path := a.pathOf(r.Id)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+	a.Lock()
+	a.summaries[r.Id] = summaryOf(r)
+	a.Unlock()
+	return nil
+}
+
+// Get 读取完整的存档，不存在时返回 nil
+// id 来自 URL，不是目录下的文件名时视为不存在，避免读取目录以外的文件
+func (a *FileArchive) Get(id string) (*ArchiveRecord, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, nil
+	}
+	a.RLock()
+	_, exists := a.summaries[id]
+	a.RUnlock()
+	if !exists {
+		return nil, nil
+	}
+	return a.read(id)
+}
+
+// Search 搜索存档摘要，按结束时间从新到旧排列
+func (a *FileArchive) Search(q ArchiveQuery) ([]*ArchiveRecord, error) {
+	a.RLock()
+	result := make([]*ArchiveRecord, 0)
+	for _, r := range a.summaries {
+		if q.Match(r) {
+			result = append(result, r)
+		}
+	}
+	a.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].EndTime.After(result[j].EndTime)
+	})
+	return result, nil
+}
+
+func (a *FileArchive) read(id string) (*ArchiveRecord, error) {
+	data, err := os.ReadFile(a.pathOf(id))
+	if err != nil {
+		return nil, err
+	}
+	r := new(ArchiveRecord)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (a *FileArchive) pathOf(id string) string {
+	return filepath.Join(a.Dir, id+".json")
+}
+
+func summaryOf(r *ArchiveRecord) *ArchiveRecord {
+	summary := *r
+	summary.Events = nil
+	return &summary
+}
+
+// archiveGame 将游戏存档，未结束的游戏记为中途放弃
+func archiveGame(m *GameManager) error {
+	if GameArchive == nil {
+		return errors.New("archive is not available")
+	}
+	return GameArchive.Put(NewArchiveRecord(m.GameId, m.GamePtr))
+}
+
+// latestArchivedEvents 返回指定游戏名最近一局存档的事件，以及这局游戏是否已结束
+func latestArchivedEvents(gameId string) ([]engine.Event, bool) {
+	if GameArchive == nil {
+		return nil, false
+	}
+	records, err := GameArchive.Search(ArchiveQuery{GameId: gameId})
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+	r, err := GameArchive.Get(records[0].Id)
+	if err != nil || r == nil {
+		return nil, false
+	}
+	return r.Events, !r.Abandoned
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"splendor-go/engine"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFileArchive 测试期间使用 dir 中的文件存档
+func useFileArchive(t *testing.T, dir string) *FileArchive {
+	t.Helper()
+	archive, err := NewFileArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	GameArchive = archive
+	t.Cleanup(func() {
+		GameArchive = nil
+	})
+	return archive
+}
+
+// archived 一局 2024 年 3 月 day 日结束的游戏的存档
+func archived(id, gameId string, day int, abandoned bool, names ...string) *ArchiveRecord {
+	r := &ArchiveRecord{
+		Id:        id,
+		GameId:    gameId,
+		Players:   make([]ArchivePlayer, len(names)),
+		EndTime:   time.Date(2024, 3, day, 12, 0, 0, 0, time.Local),
+		Events:    []engine.Event{{Type: engine.EventSetup}},
+		Abandoned: abandoned,
+	}
+	for i, name := range names {
+		r.Players[i] = ArchivePlayer{Id: i, Name: name}
+	}
+	return r
+}
+
+func TestArchiveListRouter(t *testing.T) {
+	archive := useFileArchive(t, t.TempDir())
+	for _, r := range []*ArchiveRecord{
+		archived("a", "room", 1, false, "Alice", "Bob"),
+		archived("b", "room", 2, false, "alice", "Carol", "Dave"),
+		archived("c", "other", 3, true, "Bob", "Carol"),
+	} {
+		if err := archive.Put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := newRouter()
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		ids    string // 按结束时间从新到旧排列的存档
+	}{
+		{"all", "", http.StatusOK, "c b a"},
+		{"player ignores case", "player=ALICE", http.StatusOK, "b a"},
+		{"players", "players=2", http.StatusOK, "c a"},
+		{"from", "from=2024-03-02", http.StatusOK, "c b"},
+		{"to includes the day", "to=2024-03-02", http.StatusOK, "b a"},
+		{"combined", "player=carol&from=2024-03-03", http.StatusOK, "c"},
+		{"nothing", "player=Eve", http.StatusOK, ""},
+		{"bad date", "from=yesterday", http.StatusBadRequest, ""},
+		{"bad players", "players=two", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := send(t, r, http.MethodGet, "/archive?"+tt.query, nil)
+			if status != tt.status {
+				t.Fatalf("got status %d, want %d: %v", status, tt.status, res)
+			} else if status != http.StatusOK {
+				return
+			}
+			ids := make([]string, 0)
+			for _, game := range res["games"].([]any) {
+				record := game.(map[string]any)
+				if _, exists := record["events"]; exists {
+					t.Fatal("the list contains events")
+				}
+				ids = append(ids, record["id"].(string))
+			}
+			if got := strings.Join(ids, " "); got != tt.ids {
+				t.Fatalf("got %q, want %q", got, tt.ids)
+			}
+		})
+	}
+}
+
+func TestArchiveRouter(t *testing.T) {
+	// 目录外放一局游戏，存档中有一条记录的 id 指向它
+	root := t.TempDir()
+	secret, _ := json.Marshal(archived("secret", "hidden", 1, false, "Mallory"))
+	planted, _ := json.Marshal(archived("../secret", "planted", 1, false, "Mallory"))
+	dir := filepath.Join(root, "archive")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.json"), secret, 0644); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(filepath.Join(dir, "planted.json"), planted, 0644); err != nil {
+		t.Fatal(err)
+	}
+	archive := useFileArchive(t, dir)
+	if record, err := archive.Get("../secret"); record != nil || err != nil {
+		t.Fatalf("read a file outside the archive: %v %v", record, err)
+	}
+	for _, r := range []*ArchiveRecord{
+		archived("ended", "room", 1, false, "Alice", "Bob"),
+		archived("abandoned", "room", 2, true, "Alice", "Bob"),
+	} {
+		if err := archive.Put(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := newRouter()
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		events bool
+	}{
+		{"ended", "/archive/ended", http.StatusOK, true},
+		{"abandoned", "/archive/abandoned", http.StatusOK, false},
+		{"unknown", "/archive/nope", http.StatusNotFound, false},
+		{"parent directory", "/archive/..%2Fsecret", http.StatusNotFound, false},
+		{"dot", "/archive/..", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.status, w.Body)
+			} else if strings.Contains(w.Body.String(), "Mallory") {
+				t.Fatalf("leaked a file outside the archive: %s", w.Body)
+			} else if w.Code != http.StatusOK {
+				return
+			}
+			record := new(ArchiveRecord)
+			if err := json.Unmarshal(w.Body.Bytes(), record); err != nil {
+				t.Fatal(err)
+			} else if got := len(record.Events) > 0; got != tt.events {
+				t.Fatalf("events shown: %v, want %v", got, tt.events)
+			}
+		})
+	}
+}
@@ -3,102 +3,32 @@ package main
 import (
 	"bufio"
 	"fmt"
-	"github.com/google/uuid"
 	"math/rand"
 	"os"
-	"strings"
 )
 
 const (
-	MaxPlayers        = 4
-	NoblePoints       = 3
-	MaxGems           = 10
-	MaxReserve        = 3
-	TotalGolds        = 5
-	WinPoints         = 15
-	L1Num             = 40
-	L2Num             = 30
-	L3Num             = 20
-	NobleNum          = 10
-	TableSize         = 4
-	PollInterval      = 400
+	// 无操作多久后删除游戏：未开始的游戏以分钟计，进行中的以小时计，已结束的以分钟计
 	DeleteWaitingGame = 10
 	DeletePlayingGame = 24
-	WaitingState      = "waiting"
-	PlayingState      = "playing"
-	EndedState        = "ended"
+	DeleteEndedGame   = 60
+	// 清理游戏的间隔，以秒计
+	JanitorInterval = 60
+	// 机器人行动前等待的时间，以毫秒计
+	BotDelay = 800
+	// 机器人每步最多的思考时间，以秒计
+	MaxBotBudget = 10
+	// 保留状态缓存的版本数，更早的版本请求增量时返回完整状态
+	MaxDeltaVersions = 64
+	// 回合建议默认和最多返回的数量
+	DefaultHints = 3
+	MaxHints     = 10
 )
 
 var (
-	ColorList = []string{"W", "B", "G", "R", "K"}
-
-	ColorDict = map[string]string{
-		"W": "⚪",
-		"B": "🔵",
-		"G": "🟢",
-		"R": "🔴",
-		"K": "⚫",
-	}
-
 	SuggestWords = make([]string, 0)
 )
 
-type DevCard struct {
-	Uuid    string
-	Level   int
-	Color   string
-	Points  int
-	Cost    map[string]int
-	Caption string
-}
-
-type Noble struct {
-	Uuid     string
-	Sequence int
-	Cost     map[string]int
-	Caption  string
-}
-
-// LoadCards 从文件加载所有卡牌和贵族
-func LoadCards() (l1, l2, l3 []*DevCard, nobles []*Noble) {
-	l1 = make([]*DevCard, L1Num)
-	l2 = make([]*DevCard, L2Num)
-	l3 = make([]*DevCard, L3Num)
-	nobles = make([]*Noble, NobleNum)
-	// 打开 ../resources/cards.txt
-	file, err := os.Open("resources/cards.txt")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer func(f *os.File) {
-		err := f.Close()
-		if err != nil {
-			fmt.Println(err)
-		}
-	}(file)
-	// 创建读取器
-	scanner := bufio.NewScanner(file)
-	// 读取
-	for i := 0; i < L1Num; i++ {
-		scanner.Scan()
-		l1[i] = newDevCard(1, ColorList[i/8], scanner.Text())
-	}
-	for i := 0; i < L2Num; i++ {
-		scanner.Scan()
-		l2[i] = newDevCard(2, ColorList[i/6], scanner.Text())
-	}
-	for i := 0; i < L3Num; i++ {
-		scanner.Scan()
-		l3[i] = newDevCard(3, ColorList[i/4], scanner.Text())
-	}
-	for i := 0; i < NobleNum; i++ {
-		scanner.Scan()
-		nobles[i] = newNoble(i, scanner.Text())
-	}
-	return
-}
-
 // InitRoomWords 从文件加载所有提示词
 func InitRoomWords() {
 	// 打开 ../resources/words.txt
@@ -121,63 +51,6 @@ func InitRoomWords() {
 	}
 }
 
-func newDevCard(level int, color, line string) *DevCard {
-	// 先处理分数
-	n := len(line)
-	var points int
-	if line[n-2] == '+' {
-		points = int(line[n-1] - '0')
-		line = line[:n-2]
-		n -= 2
-	}
-	// 再处理宝石
-	cost := make(map[string]int)
-	for _, c := range ColorList {
-		cost[c] = 0
-	}
-	for i := 0; i < n; i += 2 {
-		cost[line[i+1:i+2]] = int(line[i] - '0')
-	}
-	var pointStr string
-	if points > 0 {
-		pointStr = fmt.Sprintf("+%d🔸", points)
-	}
-	caption := fmt.Sprintf("(%s%s)[%s]", color, pointStr, line)
-	// 返回
-	return &DevCard{
-		Uuid:    uuid.New().String(),
-		Level:   level,
-		Color:   color,
-		Points:  points,
-		Cost:    cost,
-		Caption: beautifyCaption(caption),
-	}
-}
-
-func newNoble(seq int, line string) *Noble {
-	cost := make(map[string]int)
-	for _, c := range ColorList {
-		cost[c] = 0
-	}
-	for i := 0; i < len(line); i += 2 {
-		cost[line[i+1:i+2]] = int(line[i] - '0')
-	}
-	caption := fmt.Sprintf("(+3🔸)[%s]", beautifyCaption(line))
-	return &Noble{
-		Uuid:     uuid.New().String(),
-		Sequence: seq,
-		Cost:     cost,
-		Caption:  caption,
-	}
-}
-
 func randomSuggestion() string {
 	return SuggestWords[rand.Intn(len(SuggestWords))]
 }
-
-func beautifyCaption(str string) string {
-	for _, c := range ColorList {
-		str = strings.ReplaceAll(str, c, ColorDict[c])
-	}
-	return str
-}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"splendor-go/engine"
+	"time"
+)
+
+// Janitor 定期删除长时间无操作的游戏，进行中的游戏删除前先存档
+type Janitor struct {
+	Interval       time.Duration
+	WaitingTimeout time.Duration
+	PlayingTimeout time.Duration
+	EndedTimeout   time.Duration
+}
+
+// NewJanitor 以默认的间隔和超时创建清理器
+func NewJanitor() *Janitor {
+	return &Janitor{
+		Interval:       JanitorInterval * time.Second,
+		WaitingTimeout: DeleteWaitingGame * time.Minute,
+		PlayingTimeout: DeletePlayingGame * time.Hour,
+		EndedTimeout:   DeleteEndedGame * time.Minute,
+	}
+}
+
+// Run 每隔 Interval 清理一次，直到 stop 被关闭
+func (j *Janitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(j.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			j.Sweep(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sweep 删除到 now 为止已超时的游戏，返回删除的数量
+func (j *Janitor) Sweep(now time.Time) int {
+	removed := 0
+	for _, m := range GameMap.List() {
+		m.Do(func() {
+			idle := now.Sub(m.lastActive())
+			timeout := j.timeoutOf(m.GamePtr.State)
+			if idle < timeout {
+				return
+			}
+			// 中途放弃的游戏也存档，便于之后回放，存档中记为放弃，不公开隐藏信息
+			if m.Started && !m.Archived {
+				if err := archiveGame(m); err != nil {
+					fmt.Println(err)
+				}
+				m.Archived = true
+			}
+			deleteGame(m.GameId)
+			removed++
+
+			timeStr := now.Format("2006-01-02 15:04:05")
+			fmt.Printf("[%s] Game \"%s\" removed (%s, idle for %s)\n",
+				timeStr, m.GameId, m.GamePtr.State, idle.Truncate(time.Second))
+		})
+	}
+	return removed
+}
+
+func (j *Janitor) timeoutOf(state string) time.Duration {
+	switch state {
+	case engine.WaitingState:
+		return j.WaitingTimeout
+	case engine.EndedState:
+		return j.EndedTimeout
+	}
+	return j.PlayingTimeout
+}
+
+// lastActive 最后一次操作或聊天的时间
+func (m *GameManager) lastActive() time.Time {
+	last := m.GamePtr.UpdatedTime
+	if n := len(m.ChatList); n > 0 && m.ChatList[n-1].SendTime.After(last) {
+		last = m.ChatList[n-1].SendTime
+	}
+	return last
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"splendor-go/engine"
+	"testing"
+	"time"
+)
+
+func TestJanitorSweep(t *testing.T) {
+	archive := useFileArchive(t, t.TempDir())
+	r := newRouter()
+	j := NewJanitor()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		state    string
+		idle     time.Duration // 距离最后一次操作的时间
+		chat     bool          // 一分钟前有人发送了消息
+		removed  bool
+		archived string // 存档的结果，为空时没有存档
+	}{
+		{"waiting idle", engine.WaitingState, 11 * time.Minute, false, true, ""},
+		{"waiting", engine.WaitingState, 9 * time.Minute, false, false, ""},
+		{"playing idle", engine.PlayingState, 25 * time.Hour, false, true, "abandoned"},
+		{"playing", engine.PlayingState, 23 * time.Hour, false, false, ""},
+		{"playing with a recent chat", engine.PlayingState, 25 * time.Hour, true, false, ""},
+		{"ended idle", engine.EndedState, 61 * time.Minute, false, true, "ended"},
+		{"ended", engine.EndedState, 59 * time.Minute, false, false, "ended"},
+	}
+	managers := make([]*GameManager, len(tests))
+	for i, tt := range tests {
+		gameId := fmt.Sprintf("janitor-%d", i)
+		if tt.state == engine.WaitingState {
+			request(t, r, http.MethodPost, "/create/"+gameId, nil)
+			t.Cleanup(func() {
+				deleteGame(gameId)
+			})
+			managers[i], _ = GameMap.Get(gameId)
+			managers[i].JoinGame()
+		} else {
+			managers[i], _ = newRoom(t, r, gameId, "seed=1", 2)
+		}
+		if tt.state == engine.EndedState {
+			playToEnd(t, managers[i])
+		}
+		if tt.chat {
+			managers[i].Chat(0, "still here", -1)
+		}
+		m := managers[i]
+		m.Do(func() {
+			m.GamePtr.UpdatedTime = now.Add(-tt.idle)
+			for _, chat := range m.ChatList {
+				chat.SendTime = now.Add(-time.Minute)
+			}
+		})
+	}
+
+	removed := 0
+	for _, tt := range tests {
+		if tt.removed {
+			removed++
+		}
+	}
+	if n := j.Sweep(now); n != removed {
+		t.Fatalf("removed %d games, want %d", n, removed)
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameId := fmt.Sprintf("janitor-%d", i)
+			if _, exists := GameMap.Get(gameId); exists == tt.removed {
+				t.Fatalf("game is kept: %v, want %v", exists, !tt.removed)
+			}
+			// 删除的游戏不再处理请求
+			if alive := managers[i].Do(func() {}); alive == tt.removed {
+				t.Fatalf("game is running: %v, want %v", alive, !tt.removed)
+			}
+			records, err := archive.Search(ArchiveQuery{GameId: gameId})
+			if err != nil {
+				t.Fatal(err)
+			}
+			archived := ""
+			if len(records) > 1 {
+				t.Fatalf("archived %d times", len(records))
+			} else if len(records) == 1 && records[0].Abandoned {
+				archived = "abandoned"
+			} else if len(records) == 1 {
+				archived = "ended"
+			}
+			if archived != tt.archived {
+				t.Fatalf("got archive %q, want %q", archived, tt.archived)
+			}
+		})
+	}
+}
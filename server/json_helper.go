@@ -2,6 +2,9 @@ package main
 
 import (
 	"github.com/gin-gonic/gin"
+	"reflect"
+	"splendor-go/bot"
+	"splendor-go/engine"
 	"strconv"
 )
 
@@ -13,39 +16,56 @@ var (
 		"R": "r",
 		"K": "b",
 	}
-	GoldKey = "*"
 )
 
-func SerializeDevCard(d *DevCard) gin.H {
-	return gin.H{
+func SerializeDevCard(d *engine.DevCard) gin.H {
+	res := gin.H{
 		"uuid":   d.Uuid,
 		"color":  ColorMap[d.Color],
 		"points": d.Points,
 		"cost":   transformMapColors(d.Cost),
 		"level":  "level" + strconv.Itoa(d.Level),
 	}
+	// 东方扩展的卡牌，join 卡牌没有颜色
+	if d.Orient {
+		res["orient"] = true
+		res["ability"] = d.Ability
+		if d.Ability == engine.AbilityJoin {
+			res["color"] = engine.GoldKey
+		}
+	}
+	return res
 }
 
-func SerializeHiddenDevCard(d *DevCard) gin.H {
+func SerializeHiddenDevCard(d *engine.DevCard) gin.H {
 	return gin.H{
 		"uuid":  d.Uuid,
 		"level": "level" + strconv.Itoa(d.Level),
 	}
 }
 
-func SerializeNoble(n *Noble) gin.H {
+func SerializeNoble(n *engine.Noble) gin.H {
 	return gin.H{
 		"uuid":        n.Uuid,
 		"id":          n.Sequence,
-		"points":      NoblePoints,
+		"points":      engine.NoblePoints,
 		"requirement": transformMapColors(n.Cost),
 	}
 }
 
-func SerializePlayer(p *Player, hide bool) gin.H {
+func SerializeCity(c *engine.City) gin.H {
+	return gin.H{
+		"uuid":        c.Uuid,
+		"id":          c.Id,
+		"points":      c.Points,
+		"requirement": transformMapColors(c.Cost),
+	}
+}
+
+func SerializePlayer(p *engine.Player, hide bool) gin.H {
 	// 处理宝石数量
 	gems := transformMapColors(p.Gems)
-	gems[GoldKey] = p.Golds
+	gems[engine.GoldKey] = p.Golds
 	// 处理已购买的发展卡
 	cards := make(map[string][]gin.H)
 	for c, cardSlice := range p.Cards {
@@ -69,27 +89,76 @@ func SerializePlayer(p *Player, hide bool) gin.H {
 			reserved[i] = SerializeDevCard(card)
 		}
 	}
+	// 处理城市模式下获得的城市
+	var city gin.H
+	if p.City != nil {
+		city = SerializeCity(p.City)
+	}
+	// 处理东方卡牌预定的贵族和等待选择的能力
+	reservedNobles := make([]gin.H, len(p.ReservedNobles))
+	for i, n := range p.ReservedNobles {
+		reservedNobles[i] = SerializeNoble(n)
+	}
+	// 前哨站的宝石没有对应的卡牌
+	var pending gin.H
+	if ability, options := p.PendingChoice(); ability != "" {
+		var card gin.H
+		if p.Pending != nil {
+			card = SerializeDevCard(p.Pending)
+		}
+		pending = gin.H{
+			"card":    card,
+			"ability": ability,
+			"options": serializeOptions(ability, options),
+		}
+	}
 	return gin.H{
-		"uuid":     p.Uuid,
-		"id":       p.Id,
-		"name":     p.Name,
-		"gems":     gems,
-		"cards":    cards,
-		"nobles":   nobles,
-		"reserved": reserved,
-		"score":    p.Points,
+		"uuid":            p.Uuid,
+		"id":              p.Id,
+		"name":            p.Name,
+		"gems":            gems,
+		"cards":           cards,
+		"nobles":          nobles,
+		"city":            city,
+		"reserved":        reserved,
+		"reserved_nobles": reservedNobles,
+		"pending":         pending,
+		"posts":           p.Posts,
+		"strongholds":     p.Strongholds,
+		"score":           p.Points,
 	}
 }
 
-func SerializeGame(g *Game, pid int) gin.H {
+// serializeOptions 转换东方卡牌能力或前哨站宝石的选项，颜色转换为客户端使用的颜色
+func serializeOptions(ability string, options []string) []string {
+	result := make([]string, len(options))
+	for i, option := range options {
+		if ability == engine.AbilityJoin || ability == engine.PostGem {
+			option = ColorMap[option]
+		}
+		result[i] = option
+	}
+	return result
+}
+
+func SerializeGame(g *engine.Game, pid int) gin.H {
+	return serializeGame(g, pid, false)
+}
+
+// SerializeRevealedGame 序列化游戏并公开所有玩家预定的卡牌
+func SerializeRevealedGame(g *engine.Game) gin.H {
+	return serializeGame(g, -1, true)
+}
+
+func serializeGame(g *engine.Game, pid int, reveal bool) gin.H {
 	// 处理玩家
 	players := make([]gin.H, g.PlayerNum)
 	for i, p := range g.Players[:g.PlayerNum] {
-		players[i] = SerializePlayer(p, i != pid)
+		players[i] = SerializePlayer(p, !reveal && i != pid)
 	}
 	// 处理宝石数量
 	gems := transformMapColors(g.Gems)
-	gems[GoldKey] = g.Golds
+	gems[engine.GoldKey] = g.Golds
 	// 处理发展卡
 	table := make(gin.H)
 	piles := make(gin.H)
@@ -106,26 +175,285 @@ func SerializeGame(g *Game, pid int) gin.H {
 		// 处理牌堆
 		piles[levelStr] = len(g.Piles[level-1])
 	}
+	// 处理东方扩展的发展卡，标准规则下为空
+	orientTable := make(gin.H)
+	orientPiles := make(gin.H)
+	for i, row := range g.OrientTable {
+		levelStr := "level" + strconv.Itoa(i+1)
+		cards := make([]gin.H, len(row))
+		for j, card := range row {
+			if card != nil {
+				cards[j] = SerializeDevCard(card)
+			}
+		}
+		orientTable[levelStr] = cards
+		orientPiles[levelStr] = len(g.OrientPiles[i])
+	}
+	// 处理前哨站和每个前哨站已解锁的玩家，未启用时为空
+	posts := make([]gin.H, 0)
+	if g.Rules.Outposts {
+		for _, post := range engine.Outposts {
+			owners := make([]int, 0)
+			for _, p := range g.Players[:g.PlayerNum] {
+				if p.HasPost(post.Ability) {
+					owners = append(owners, p.Id)
+				}
+			}
+			posts = append(posts, gin.H{
+				"id":          post.Id,
+				"ability":     post.Ability,
+				"requirement": transformMapColors(post.Cost),
+				"owners":      owners,
+			})
+		}
+	}
 	// 处理贵族
 	nobles := make([]gin.H, len(g.Nobles))
 	for i, n := range g.Nobles {
 		nobles[i] = SerializeNoble(n)
 	}
-	// 处理赢家
+	// 处理城市，标准规则下为空
+	cities := make([]gin.H, len(g.Cities))
+	for i, c := range g.Cities {
+		cities[i] = SerializeCity(c)
+	}
+	// 处理赢家，平局时为空，由排名给出所有并列的玩家
 	var winnerId *int
 	if g.Winner != nil {
 		winnerId = &g.Winner.Id
 	}
+	// 复制要塞，序列化的状态会被缓存，不能引用游戏中会改变的 map
+	strongholds := make(map[string][]int, len(g.Strongholds))
+	for uuid, pids := range g.Strongholds {
+		strongholds[uuid] = append([]int{}, pids...)
+	}
+	standings := make([]gin.H, len(g.Standings))
+	for i, s := range g.Standings {
+		standings[i] = SerializeStanding(s)
+	}
+
+	res := gin.H{
+		"players":      players,
+		"gems":         gems,
+		"cards":        table,
+		"decks":        piles,
+		"orient_cards": orientTable,
+		"orient_decks": orientPiles,
+		"nobles":       nobles,
+		"cities":       cities,
+		"posts":        posts,
+		"strongholds":  strongholds,
+		"log":          g.Records(),
+		"winner":       winnerId,
+		"standings":    standings,
+		"turn":         g.ActivePlayerId,
+		"rules":        g.Rules,
+	}
+	// 种子决定了牌堆顺序，只在游戏结束后公开
+	if g.State == engine.EndedState {
+		res["seed"] = strconv.FormatInt(g.Seed, 10)
+	}
+	return res
+}
 
+// SerializeStanding 将最终排名中的一名玩家转换为 JSON
+func SerializeStanding(s engine.Standing) gin.H {
 	return gin.H{
-		"players": players,
-		"gems":    gems,
-		"cards":   table,
-		"decks":   piles,
-		"nobles":  nobles,
-		"log":     g.Records,
-		"winner":  winnerId,
-		"turn":    g.ActivePlayerId,
+		"pid":    s.Pid,
+		"rank":   s.Rank,
+		"points": s.Points,
+		"cards":  s.Cards,
+		"city":   s.City,
+	}
+}
+
+// SerializeDelta 比较同一视角下新旧两个状态，只保留变化的部分
+// players 只包含有变化的玩家，log 为从 log_from 开始替换的记录
+func SerializeDelta(old, cur gin.H) gin.H {
+	delta := make(gin.H)
+	for key, value := range cur {
+		switch key {
+		case "players":
+			oldPlayers, _ := old[key].([]gin.H)
+			changed := make([]gin.H, 0)
+			for i, p := range value.([]gin.H) {
+				if i >= len(oldPlayers) || !reflect.DeepEqual(oldPlayers[i], p) {
+					changed = append(changed, p)
+				}
+			}
+			if len(changed) > 0 {
+				delta[key] = changed
+			}
+		case "log":
+			oldLog, _ := old[key].([]engine.Record)
+			log := value.([]engine.Record)
+			i := 0
+			for i < len(oldLog) && i < len(log) && oldLog[i] == log[i] {
+				i++
+			}
+			if i < len(log) || i < len(oldLog) {
+				delta["log_from"] = i
+				delta[key] = log[i:]
+			}
+		default:
+			if !reflect.DeepEqual(old[key], value) {
+				delta[key] = value
+			}
+		}
+	}
+	return delta
+}
+
+// SerializeResult 将操作结果转换为 JSON
+func SerializeResult(r *engine.Result) gin.H {
+	if r == nil {
+		return make(gin.H)
+	} else if r.Error != "" {
+		return gin.H{"error": r.Error}
+	} else if r.Ability != "" {
+		return gin.H{"ability": r.Ability, "options": serializeOptions(r.Ability, r.Options)}
+	}
+	return gin.H{"nobles": r.Nobles}
+}
+
+// SerializeMoveError 将回合校验错误转换为 JSON
+func SerializeMoveError(err error) gin.H {
+	if e, ok := err.(*engine.MoveError); ok {
+		return gin.H{"error": e.Msg, "code": e.Code}
+	}
+	return gin.H{"error": err.Error()}
+}
+
+// SerializeBoundaries 序列化回放中每一步的位置
+func SerializeBoundaries(events []engine.Event, boundaries []int) []gin.H {
+	result := make([]gin.H, len(boundaries))
+	for i, b := range boundaries {
+		last := events[b-1]
+		// 第 0 步为开局，不属于任何玩家
+		pid := last.Pid
+		if i == 0 {
+			pid = -1
+		}
+		result[i] = gin.H{
+			"step":  i,
+			"event": b,
+			"pid":   pid,
+			"time":  last.Time.Format("2006-01-02 15:04:05"),
+		}
+	}
+	return result
+}
+
+// SerializeMove 将完整回合转换为 JSON，可以原样提交
+func SerializeMove(m engine.Move) gin.H {
+	res := gin.H{"type": m.Type}
+	if len(m.Gems) > 0 {
+		res["gems"] = transformMapColors(m.Gems)
+	}
+	if m.Card != "" {
+		res["card"] = m.Card
+	}
+	if m.Level > 0 {
+		res["level"] = m.Level
+	}
+	if len(m.Payment) > 0 {
+		res["payment"] = transformMapColors(m.Payment)
+	}
+	if len(m.Discards) > 0 {
+		res["discards"] = transformMapColors(m.Discards)
+	}
+	if m.Noble != "" {
+		res["noble"] = m.Noble
+	}
+	if m.Choice != "" {
+		res["choice"] = m.Choice
+		if color, exists := ColorMap[m.Choice]; exists {
+			res["choice"] = color
+		}
+	}
+	if m.PostGem != "" {
+		res["post_gem"] = ColorMap[m.PostGem]
+	}
+	return res
+}
+
+// SerializeHints 将回合建议和局面分析转换为 JSON
+func SerializeHints(hints []bot.Hint, a *bot.Analysis) gin.H {
+	moves := make([]gin.H, len(hints))
+	for i, h := range hints {
+		moves[i] = gin.H{
+			"move":    SerializeMove(h.Move),
+			"score":   float64(int(h.Score*10)) / 10,
+			"reasons": h.Reasons,
+		}
+	}
+	affordable := make([]gin.H, len(a.Affordable))
+	for i, card := range a.Affordable {
+		affordable[i] = SerializeDevCard(card)
+	}
+	oneTurn := make([]gin.H, len(a.OneTurn))
+	for i, card := range a.OneTurn {
+		oneTurn[i] = SerializeDevCard(card)
+	}
+	nobles := make([]gin.H, len(a.Nobles))
+	for i, n := range a.Nobles {
+		nobles[i] = gin.H{
+			"noble":   SerializeNoble(n.Noble),
+			"missing": transformMapColors(n.Missing),
+		}
+	}
+	cities := make([]gin.H, len(a.Cities))
+	for i, c := range a.Cities {
+		cities[i] = gin.H{
+			"city":    SerializeCity(c.City),
+			"points":  c.Points,
+			"missing": transformMapColors(c.Missing),
+		}
+	}
+	return gin.H{
+		"hints":      moves,
+		"affordable": affordable,
+		"one_turn":   oneTurn,
+		"nobles":     nobles,
+		"cities":     cities,
+	}
+}
+
+// SerializeReport 将赛后统计转换为 JSON
+func SerializeReport(r *engine.PlayerReport) gin.H {
+	cards := make(gin.H)
+	for i, colors := range r.CardsByLevel {
+		cards["level"+strconv.Itoa(i+1)] = transformMapColors(colors)
+	}
+	nobles := make([]gin.H, len(r.Nobles))
+	for i, n := range r.Nobles {
+		nobles[i] = gin.H{"noble": n.Noble, "turn": n.Turn}
+	}
+	return gin.H{
+		"id":     r.Id,
+		"name":   r.Name,
+		"points": r.Points,
+		"turns":  r.Turns,
+		"gems": gin.H{
+			"taken":     transformMapColors(r.GemsTaken),
+			"spent":     transformMapColors(r.GemsSpent),
+			"discarded": transformMapColors(r.GemsDiscarded),
+		},
+		"golds": gin.H{
+			"gained":    r.GoldsGained,
+			"spent":     r.GoldsSpent,
+			"discarded": r.GoldsDiscarded,
+		},
+		"cards":        cards,
+		"from_reserve": r.FromReserve,
+		"points_curve": r.PointsCurve,
+		"nobles":       nobles,
+		"wasted": gin.H{
+			"reserved":          r.Reserved,
+			"reserved_unbought": r.ReservedUnbought,
+			"discard_turns":     r.DiscardTurns,
+			"pass_turns":        r.PassTurns,
+		},
 	}
 }
 
@@ -134,26 +462,36 @@ func SerializeGameManager(m *GameManager) gin.H {
 		"uuid":        m.GameId,
 		"n_players":   m.GetPlayerNum(),
 		"in_progress": m.Started,
+		"options":     m.Options,
+		"rules":       m.GamePtr.Rules,
 	}
 }
 
 func SerializeChatList(chatList []*Chat) []gin.H {
 	result := make([]gin.H, len(chatList))
 	for i, chat := range chatList {
-		result[i] = gin.H{
-			"pid":  chat.Pid,
-			"name": chat.Name,
-			"msg":  chat.Msg,
-			"time": chat.SendTime.Format("2006-01-02 15:04:05"),
-		}
+		result[i] = SerializeChat(chat)
 	}
 	return result
 }
 
+func SerializeChat(chat *Chat) gin.H {
+	return gin.H{
+		"pid":  chat.Pid,
+		"name": chat.Name,
+		"msg":  chat.Msg,
+		"time": chat.SendTime.Format("2006-01-02 15:04:05"),
+	}
+}
+
 func transformMapColors(m map[string]int) map[string]int {
 	result := make(map[string]int)
 	for color, count := range m {
-		result[ColorMap[color]] = count
+		key, exists := ColorMap[color]
+		if !exists {
+			key = color
+		}
+		result[key] = count
 	}
 	return result
 }
@@ -4,14 +4,16 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"net/http"
+	"splendor-go/bot"
+	"splendor-go/engine"
 	"strconv"
 	"sync"
 	"time"
 )
 
 var (
-	GameMap = make(map[string]*GameManager)
-	GameNum = 0
+	GameMap = NewGameRegistry()
 )
 
 type Chat struct {
@@ -19,146 +21,586 @@ type Chat struct {
 	Name     string
 	Msg      string
 	SendTime time.Time
+	Version  int
 }
 
+// RoomOptions 创建房间时选择的选项，之后不再改变
+type RoomOptions struct {
+	Hints bool `json:"hints,omitempty"` // 玩家可以请求回合建议
+}
+
+// GameManager 管理一局游戏，除创建时确定的字段外，所有状态只在游戏自己的协程中读写
 type GameManager struct {
 	GameId      string
 	UuidStarter string
-	GamePtr     *Game
+	GamePtr     *engine.Game
 	Changed     map[int]bool
 	Ended       map[int]bool
 	ChatList    []*Chat
 	CreateTime  time.Time
+	Options     RoomOptions
 	Started     bool
-	ChangeLock  sync.RWMutex
+	Archived    bool
+	Version     int
+	Bots        map[int]bot.Bot
+	BotConfigs  map[int]bot.Config
+	botPending  bool
+	watchers    map[chan struct{}]bool
+	// views 最近 MaxDeltaVersions 个版本中每个玩家看到的状态，用于计算增量
+	views    map[int]map[int]gin.H
+	commands chan func()
+	quit     chan struct{}
+	stopOnce sync.Once
 }
 
-// NewGameManager 创建新游戏管理器
-func NewGameManager(gameId string) *GameManager {
-	return &GameManager{
+// NewGameManager 以给定种子、规则和房间选项创建新游戏管理器
+func NewGameManager(gameId string, seed int64, rules engine.Rules, options RoomOptions) *GameManager {
+	m := &GameManager{
 		GameId:      gameId,
 		UuidStarter: uuid.New().String(),
-		GamePtr:     NewGame(),
+		GamePtr:     engine.NewGame(seed, rules),
 		Changed:     make(map[int]bool),
 		Ended:       make(map[int]bool),
 		ChatList:    make([]*Chat, 0),
 		CreateTime:  time.Now(),
+		Options:     options,
 		Started:     false,
+		Bots:        make(map[int]bot.Bot),
+		BotConfigs:  make(map[int]bot.Config),
 	}
+	m.markVersion()
+	m.start()
+	return m
 }
 
-// Poll 轮询游戏状态
-func (m *GameManager) Poll(pid int) gin.H {
+// start 启动游戏的协程
+func (m *GameManager) start() {
+	m.commands = make(chan func())
+	m.quit = make(chan struct{})
+	go m.run()
+}
+
+func (m *GameManager) run() {
 	for {
-		m.ChangeLock.RLock()
-		changed := m.Changed[pid]
-		m.ChangeLock.RUnlock()
-		// 若状态未改变则继续等待
-		if !changed {
-			time.Sleep(PollInterval * time.Millisecond)
-		} else {
-			break
+		select {
+		case f := <-m.commands:
+			f()
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// Stop 关闭游戏的协程，之后的 Do 都返回 false
+func (m *GameManager) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.quit)
+	})
+}
+
+// Do 在游戏的协程中执行 f 并等待其完成，游戏已关闭时返回 false
+// f 中不能再调用 Do，f 中的 panic 会在调用者的协程中重新抛出
+func (m *GameManager) Do(f func()) bool {
+	var recovered any
+	ran := false
+	done := make(chan struct{})
+	cmd := func() {
+		defer func() {
+			recovered = recover()
+			close(done)
+		}()
+		// 关闭后才取到的命令不再执行
+		select {
+		case <-m.quit:
+			return
+		default:
 		}
+		f()
+		ran = true
+	}
+	select {
+	case m.commands <- cmd:
+	case <-m.quit:
+		return false
 	}
-	// 若游戏对于该玩家已结束则删除该玩家
-	m.ChangeLock.Lock()
+	<-done
+	if recovered != nil {
+		panic(recovered)
+	}
+	return ran
+}
+
+// call 在游戏的协程中执行 f，返回 HTTP 状态码和结果
+func (m *GameManager) call(f func() (int, gin.H)) (int, gin.H) {
+	status, res := http.StatusBadRequest, gin.H{"error": "Game not found"}
+	m.Do(func() {
+		status, res = f()
+	})
+	return status, res
+}
+
+// Poll 轮询游戏状态，状态未改变时等待
+func (m *GameManager) Poll(pid, since int) gin.H {
+	notify := m.Subscribe()
+	defer m.Unsubscribe(notify)
+	for {
+		var res gin.H
+		if !m.Do(func() {
+			if m.Changed[pid] {
+				res = m.deliver(pid, since)
+			}
+		}) {
+			return gin.H{"error": "Game not found"}
+		}
+		if res != nil {
+			return res
+		}
+		select {
+		case <-notify:
+		case <-m.quit:
+		}
+	}
+}
+
+// deliver 向玩家发送最新状态
+func (m *GameManager) deliver(pid, since int) gin.H {
+	m.markDelivered(pid)
+
+	res := m.view(pid, since, true)
+	res["result"] = make(gin.H)
+
+	return res
+}
+
+// markDelivered 标记玩家已获取最新状态，若游戏对于该玩家已结束则删除该玩家
+func (m *GameManager) markDelivered(pid int) {
 	if m.Ended[pid] {
 		delete(m.Ended, pid)
 		// 若所有玩家都已结束则删除游戏
 		if len(m.Ended) == 0 {
-			delete(GameMap, m.GameId)
+			deleteGame(m.GameId)
 		}
 	}
 	m.Changed[pid] = false
-	m.ChangeLock.Unlock()
+}
 
-	res := make(gin.H)
-	res["state"] = SerializeGame(m.GamePtr, pid)
-	res["result"] = make(gin.H)
-	res["chat"] = SerializeChatList(m.ChatList)
+// JoinGame 加入游戏
+func (m *GameManager) JoinGame() (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		num := m.GetPlayerNum()
+		if num >= engine.MaxPlayers {
+			return http.StatusOK, gin.H{
+				"error": "The game is full",
+			}
+		} else if m.Started {
+			return http.StatusOK, gin.H{
+				"error": "The game has already started",
+			}
+		}
+		pid, uid := m.GamePtr.AddPlayer("Player " + strconv.Itoa(num+1))
 
-	return res
+		m.Changed[pid] = false
+		m.Ended[pid] = false
+
+		m.ChangeStatus()
+
+		// 打印加入游戏的日志
+		timeStr := time.Now().Format("2006-01-02 15:04:05")
+		fmt.Printf("[%s] Game \"%s\" joined (%d players)\n",
+			timeStr, m.GameId, m.GetPlayerNum())
+		return http.StatusOK, gin.H{
+			"id":   pid,
+			"uuid": uid,
+		}
+	})
 }
 
-// JoinGame 加入游戏
-func (m *GameManager) JoinGame() gin.H {
-	num := m.GetPlayerNum()
-	if num >= MaxPlayers {
-		return gin.H{
-			"error": "The game is full",
+// AddBot 在空位上加入指定配置的机器人
+func (m *GameManager) AddBot(cfg bot.Config) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		num := m.GetPlayerNum()
+		if num >= engine.MaxPlayers {
+			return http.StatusBadRequest, gin.H{"error": "The game is full"}
+		} else if m.Started {
+			return http.StatusBadRequest, gin.H{"error": "The game has already started"}
 		}
-	} else if m.Started {
-		return gin.H{
-			"error": "The game has already started",
+		b, err := bot.New(cfg)
+		if err != nil {
+			return http.StatusBadRequest, gin.H{"error": "Invalid bot level"}
 		}
-	}
-	pid, uid := m.GamePtr.AddPlayer("Player " + strconv.Itoa(num+1))
+		pid, _ := m.GamePtr.AddPlayer(fmt.Sprintf("Bot %d (%s)", num+1, cfg.Level))
+		m.Bots[pid] = b
+		m.BotConfigs[pid] = cfg
 
-	m.ChangeLock.Lock()
-	m.Changed[pid] = false
-	m.Ended[pid] = false
-	m.ChangeLock.Unlock()
+		m.ChangeStatus()
+		return http.StatusOK, gin.H{
+			"id":    pid,
+			"level": cfg.Level,
+		}
+	})
+}
 
-	m.ChangeStatus()
-	return gin.H{
-		"id":   pid,
-		"uuid": uid,
+// scheduleBot 轮到机器人时，等待片刻后由机器人行动
+// 机器人在游戏副本上思考，不占用游戏的协程，思考期间其他请求可以正常处理
+func (m *GameManager) scheduleBot() {
+	if m.botPending || m.GamePtr.State != engine.PlayingState {
+		return
+	}
+	pid := m.GamePtr.ActivePlayerId
+	b, exists := m.Bots[pid]
+	if !exists {
+		return
 	}
+	m.botPending = true
+	game := m.GamePtr.Clone()
+	time.AfterFunc(BotDelay*time.Millisecond, func() {
+		// 这里没有其他地方恢复 panic，机器人出错只影响这局游戏
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Printf("Bot %d in game \"%s\" panicked: %v\n", pid, m.GameId, r)
+				m.recoverBot(pid)
+			}
+		}()
+		move := b.ChooseMove(game, pid)
+		m.Do(func() {
+			m.playBot(pid, move)
+		})
+	})
 }
 
-// WatchGame 观战游戏
-func (m *GameManager) WatchGame() gin.H {
-	pid, uid := m.GamePtr.AddSpectator()
-	m.ChangeLock.Lock()
-	m.Changed[pid] = false
-	m.ChangeLock.Unlock()
+// playBot 执行机器人选择的回合，与玩家的回合一样经过校验
+func (m *GameManager) playBot(pid int, move engine.Move) {
+	m.botPending = false
+	game := m.GamePtr
+	if game.State != engine.PlayingState || game.ActivePlayerId != pid {
+		return
+	}
+	if err := game.ApplyMove(pid, move); err != nil {
+		fmt.Printf("Bot %d in game \"%s\" made an invalid move: %v\n", pid, m.GameId, err)
+		// 退而求其次，选择任意合法的回合
+		if !m.playLegal(pid) {
+			return
+		}
+	}
 	m.ChangeStatus()
-	return gin.H{
-		"id":   pid,
-		"uuid": uid,
+}
+
+// recoverBot 机器人出错后清除等待标记，并改为执行任意合法的回合，再次出错时只记录错误
+func (m *GameManager) recoverBot(pid int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf("Bot %d in game \"%s\" can't recover: %v\n", pid, m.GameId, r)
+		}
+	}()
+	m.Do(func() {
+		m.botPending = false
+		game := m.GamePtr
+		if game.State == engine.PlayingState && game.ActivePlayerId == pid && m.playLegal(pid) {
+			m.ChangeStatus()
+		}
+	})
+}
+
+// playLegal 执行第一个合法的回合
+func (m *GameManager) playLegal(pid int) bool {
+	moves := m.GamePtr.LegalMoves(pid)
+	if len(moves) == 0 {
+		return false
+	}
+	if err := m.GamePtr.ApplyMove(pid, moves[0]); err != nil {
+		fmt.Printf("Bot %d in game \"%s\" can't play a legal move: %v\n", pid, m.GameId, err)
+		return false
 	}
+	return true
+}
+
+// WatchGame 观战游戏
+func (m *GameManager) WatchGame() (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		pid, uid := m.GamePtr.AddSpectator()
+		m.Changed[pid] = false
+		m.ChangeStatus()
+		return http.StatusOK, gin.H{
+			"id":   pid,
+			"uuid": uid,
+		}
+	})
 }
 
 // StartGame 开始游戏
-func (m *GameManager) StartGame() gin.H {
-	if m.GamePtr.StartGame() {
-		m.Started = true
+func (m *GameManager) StartGame() (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		if m.Started {
+			return http.StatusBadRequest, gin.H{"error": "Game has already started"}
+		}
+		if m.GamePtr.StartGame() {
+			m.Started = true
+			// 打印贵族
+			for _, noble := range m.GamePtr.AllNobles {
+				fmt.Println(noble.Caption)
+			}
+			m.ChangeStatus()
+			return http.StatusOK, make(gin.H)
+		}
+		return http.StatusOK, gin.H{
+			"error": "Cannot start the game",
+		}
+	})
+}
+
+// RenamePlayer 重命名玩家
+func (m *GameManager) RenamePlayer(pid int, name string) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		m.GamePtr.RenamePlayer(pid, name)
 		m.ChangeStatus()
-		return make(gin.H)
-	}
-	return gin.H{
-		"error": "Cannot start the game",
-	}
+		return http.StatusOK, gin.H{
+			// why?
+			"status": "ok",
+		}
+	})
+}
+
+// NextTurn 结束当前玩家的回合，返回 HTTP 状态码和结果
+func (m *GameManager) NextTurn(pid, since int) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		if pid != m.GamePtr.ActivePlayerId {
+			return http.StatusBadRequest, gin.H{"error": "Now is not your turn"}
+		}
+
+		m.GamePtr.Act(pid, engine.Action{Type: engine.ActionNext})
+		m.ChangeStatus()
+
+		res := m.view(pid, since, false)
+		res["result"] = make(gin.H)
+		return http.StatusOK, res
+	})
+}
+
+// Act 执行一次游戏操作，返回 HTTP 状态码和结果
+func (m *GameManager) Act(pid int, action, target string, since int) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		game := m.GamePtr
+		if pid != game.ActivePlayerId {
+			return http.StatusBadRequest, gin.H{"error": "Now is not your turn"}
+		}
+
+		act := engine.ActionType(action)
+		switch act {
+		case engine.ActionTake, engine.ActionDiscard, engine.ActionPostGem:
+			target = ReqColorMap[target]
+		case engine.ActionChoose:
+			// 选项可能是颜色，也可能是贵族或卡牌的 UUID
+			if color, exists := ReqColorMap[target]; exists {
+				target = color
+			}
+		case engine.ActionBuy, engine.ActionReserve, engine.ActionNobleVisit, engine.ActionStronghold, engine.ActionRaze:
+		default:
+			return http.StatusBadRequest, gin.H{"error": "Invalid action"}
+		}
+		result := game.Act(pid, engine.Action{Type: act, Target: target})
+		if result == nil {
+			m.ChangeStatus()
+		} else if result.Nobles != nil || result.Ability != "" {
+			// 等待选择贵族、东方卡牌能力或前哨站宝石时状态已改变，产生新版本但暂不通知其他玩家
+			m.nextVersion()
+			m.save()
+		}
+		res := m.view(pid, since, false)
+		res["result"] = SerializeResult(result)
+		return http.StatusOK, res
+	})
+}
+
+// SubmitTurn 一次性执行完整回合，返回 HTTP 状态码和结果
+func (m *GameManager) SubmitTurn(pid int, move engine.Move, since int) (int, gin.H) {
+	move = translateReqMove(move)
+	return m.call(func() (int, gin.H) {
+		result := make(gin.H)
+		if err := m.GamePtr.ApplyMove(pid, move); err != nil {
+			result = SerializeMoveError(err)
+		} else {
+			m.ChangeStatus()
+		}
+		res := m.view(pid, since, false)
+		res["result"] = result
+		return http.StatusOK, res
+	})
 }
 
 // Chat 发送消息
-func (m *GameManager) Chat(pid int, msg string) gin.H {
-	m.ChatList = append(m.ChatList, &Chat{
-		Pid:      pid,
-		Name:     m.GamePtr.Players[pid].Name,
-		Msg:      msg,
-		SendTime: time.Now(),
+func (m *GameManager) Chat(pid int, msg string, since int) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		m.ChatList = append(m.ChatList, &Chat{
+			Pid:      pid,
+			Name:     m.GamePtr.Players[pid].Name,
+			Msg:      msg,
+			SendTime: time.Now(),
+		})
+		m.ChangeStatus()
+		res := m.view(pid, since, true)
+		res["result"] = make(gin.H)
+		return http.StatusOK, res
 	})
-	m.ChangeStatus()
-	return gin.H{
-		"state":  SerializeGame(m.GamePtr, pid),
-		"result": make(gin.H),
-		"chat":   SerializeChatList(m.ChatList),
+}
+
+// Stat 获取游戏状态，不影响轮询
+func (m *GameManager) Stat(pid, since int) (int, gin.H) {
+	return m.call(func() (int, gin.H) {
+		return http.StatusOK, m.view(pid, since, true)
+	})
+}
+
+// Hint 为轮到的玩家列出建议的回合，只在房间开启建议时可用
+// 建议在游戏副本上计算，不占用游戏的协程
+func (m *GameManager) Hint(pid, n int) (int, gin.H) {
+	if !m.Options.Hints {
+		return http.StatusForbidden, gin.H{"error": "Hints are disabled in this room"}
+	}
+	var game *engine.Game
+	status, res := m.call(func() (int, gin.H) {
+		if m.GamePtr.State != engine.PlayingState {
+			return http.StatusBadRequest, gin.H{"error": "Game is not in progress"}
+		} else if pid != m.GamePtr.ActivePlayerId {
+			return http.StatusBadRequest, gin.H{"error": "Now is not your turn"}
+		}
+		game = m.GamePtr.Clone()
+		return http.StatusOK, nil
+	})
+	if game == nil {
+		return status, res
 	}
+	return http.StatusOK, SerializeHints(bot.Suggest(game, pid, n), bot.Analyze(game, pid))
+}
+
+// nextVersion 状态每次改变都产生一个新版本，新消息记录其所在的版本
+func (m *GameManager) nextVersion() {
+	m.Version++
+	for i := len(m.ChatList) - 1; i >= 0 && m.ChatList[i].Version == 0; i-- {
+		m.ChatList[i].Version = m.Version
+	}
+	m.markVersion()
 }
 
 // ChangeStatus 修改状态
 func (m *GameManager) ChangeStatus() {
-	m.ChangeLock.Lock()
-	defer m.ChangeLock.Unlock()
-	if m.GamePtr.State == EndedState {
+	m.nextVersion()
+	if m.GamePtr.State == engine.EndedState {
 		for i := range m.Ended {
 			m.Ended[i] = true
 		}
+		// 游戏结束时存档
+		if !m.Archived {
+			if err := archiveGame(m); err != nil {
+				fmt.Println(err)
+			}
+			m.Archived = true
+		}
 	}
 	for i := range m.Changed {
 		m.Changed[i] = true
 	}
+	// 通知推送连接，通道已满说明已有未处理的通知
+	for ch := range m.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	m.save()
+	m.scheduleBot()
+}
+
+// Subscribe 订阅状态变化，返回的通道在每次 ChangeStatus 时收到通知
+func (m *GameManager) Subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	m.Do(func() {
+		if m.watchers == nil {
+			m.watchers = make(map[chan struct{}]bool)
+		}
+		m.watchers[ch] = true
+	})
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func (m *GameManager) Unsubscribe(ch chan struct{}) {
+	m.Do(func() {
+		delete(m.watchers, ch)
+	})
+}
+
+// Save 保存游戏到存储
+func (m *GameManager) Save() {
+	m.Do(m.save)
+}
+
+// save 保存游戏到存储
+func (m *GameManager) save() {
+	if Store == nil {
+		return
+	}
+	err := Store.Save(&GameSnapshot{
+		GameId:      m.GameId,
+		UuidStarter: m.UuidStarter,
+		CreateTime:  m.CreateTime,
+		Options:     m.Options,
+		Started:     m.Started,
+		Archived:    m.Archived,
+		Version:     m.Version,
+		Bots:        m.BotConfigs,
+		Ended:       m.Ended,
+		ChatList:    m.ChatList,
+		Events:      m.GamePtr.Events,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// markVersion 为当前版本创建状态缓存，并丢弃过旧版本的缓存
+func (m *GameManager) markVersion() {
+	if m.views == nil {
+		m.views = make(map[int]map[int]gin.H)
+	}
+	m.views[m.Version] = make(map[int]gin.H)
+	delete(m.views, m.Version-MaxDeltaVersions)
+}
+
+// stateOf 当前版本下玩家看到的状态，每个版本只序列化一次
+func (m *GameManager) stateOf(pid int) gin.H {
+	cache := m.views[m.Version]
+	state, exists := cache[pid]
+	if !exists {
+		state = SerializeGame(m.GamePtr, pid)
+		cache[pid] = state
+	}
+	return state
+}
+
+// view 生成玩家看到的状态，since 为缓存中的旧版本时只返回此后的增量，否则返回完整状态
+func (m *GameManager) view(pid, since int, withChat bool) gin.H {
+	res := gin.H{"version": m.Version}
+	state := m.stateOf(pid)
+	if old, exists := m.views[since][pid]; since >= 0 && exists {
+		delta := SerializeDelta(old, state)
+		if withChat {
+			chats := make([]*Chat, 0)
+			for _, chat := range m.ChatList {
+				if chat.Version > since {
+					chats = append(chats, chat)
+				}
+			}
+			delta["chat"] = SerializeChatList(chats)
+		}
+		res["since"] = since
+		res["delta"] = delta
+		return res
+	}
+	res["state"] = state
+	if withChat {
+		res["chat"] = SerializeChatList(m.ChatList)
+	}
+	return res
 }
 
 // GetPlayerNum 获取玩家数量
@@ -166,16 +608,34 @@ func (m *GameManager) GetPlayerNum() int {
 	return m.GamePtr.PlayerNum
 }
 
+// deleteGame 从 GameMap 和存储中删除游戏并关闭游戏的协程
+func deleteGame(gameId string) {
+	if m := GameMap.Delete(gameId); m != nil {
+		m.Stop()
+	}
+	if Store != nil {
+		if err := Store.Delete(gameId); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// queryManager 验证玩家身份，成功时返回游戏管理器
 func queryManager(pid int, playerUuid, gameId string) *GameManager {
-	res, exists := GameMap[gameId]
+	res, exists := GameMap.Get(gameId)
 	if !exists {
 		return nil
 	}
-	// fmt.Println(res.GamePtr.Players)
-	if pid < 0 || pid >= len(res.GamePtr.Players) || res.GamePtr.Players[pid] == nil {
-		fmt.Printf("Players=%v, pid=%d\n", res.GamePtr.Players, pid)
-		return nil
-	} else if res.GamePtr.Players[pid].Uuid != playerUuid {
+	valid := false
+	res.Do(func() {
+		// fmt.Println(res.GamePtr.Players)
+		if pid < 0 || pid >= len(res.GamePtr.Players) || res.GamePtr.Players[pid] == nil {
+			fmt.Printf("Players=%v, pid=%d\n", res.GamePtr.Players, pid)
+		} else {
+			valid = res.GamePtr.Players[pid].Uuid == playerUuid
+		}
+	})
+	if !valid {
 		return nil
 	}
 	return res
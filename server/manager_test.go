@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"splendor-go/bot"
+	"splendor-go/engine"
+	"testing"
+)
+
+// client 客户端保存的状态，由完整状态和之后的增量得到
+type client struct {
+	version int
+	state   map[string]any
+}
+
+// normalize 经过一次 JSON 编码，与客户端收到的数据一致
+func normalize(t *testing.T, v any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := make(map[string]any)
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// update 按客户端的方式应用 Stat 的结果，返回是否收到的是增量
+func (c *client) update(t *testing.T, res gin.H) bool {
+	t.Helper()
+	res = normalize(t, res)
+	c.version = int(res["version"].(float64))
+	if state, exists := res["state"]; exists {
+		c.state = state.(map[string]any)
+		return false
+	}
+	delta := res["delta"].(map[string]any)
+	for key, value := range delta {
+		switch key {
+		case "players":
+			// 玩家按编号替换
+			players := c.state["players"].([]any)
+			for _, p := range value.([]any) {
+				id := int(p.(map[string]any)["id"].(float64))
+				if id < len(players) {
+					players[id] = p
+				} else {
+					players = append(players, p)
+				}
+			}
+			c.state["players"] = players
+		case "log_from":
+		case "log":
+			from := int(delta["log_from"].(float64))
+			c.state["log"] = append(c.state["log"].([]any)[:from], value.([]any)...)
+		case "chat":
+		default:
+			c.state[key] = value
+		}
+	}
+	return true
+}
+
+// check 客户端的状态应与当前完整的状态相同
+func (c *client) check(t *testing.T, m *GameManager, pid int) {
+	t.Helper()
+	var want map[string]any
+	m.Do(func() {
+		want = normalize(t, SerializeGame(m.GamePtr, pid))
+	})
+	got, _ := json.Marshal(c.state)
+	expected, _ := json.Marshal(want)
+	if string(got) != string(expected) {
+		t.Fatalf("player %d at version %d:\n got %s\nwant %s", pid, c.version, got, expected)
+	}
+}
+
+func TestDeltaMatchesFullState(t *testing.T) {
+	useFileStore(t)
+	m := NewGameManager("delta", 4, engine.Rules{Orient: true, Outposts: true, Strongholds: true}, RoomOptions{})
+	t.Cleanup(m.Stop)
+	for i := 0; i < 3; i++ {
+		m.JoinGame()
+	}
+	_, watcher := m.WatchGame()
+	m.StartGame()
+	viewers := []int{0, 1, 2, watcher["id"].(int)}
+	clients := make(map[int]*client)
+	for _, pid := range viewers {
+		clients[pid] = new(client)
+		_, res := m.Stat(pid, -1)
+		clients[pid].update(t, res)
+	}
+
+	// 第一个玩家预定一张卡牌，只有自己能看到
+	var reserver int
+	m.Do(func() {
+		g := m.GamePtr
+		reserver = g.ActivePlayerId
+		if err := g.ApplyMove(reserver, engine.Move{Type: engine.MoveReserve, Card: g.Table[0][0].Uuid}); err != nil {
+			t.Fatal(err)
+		}
+		m.ChangeStatus()
+	})
+	b, err := bot.New(bot.Config{Level: bot.LevelEasy, Seed: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for turn := 0; turn < 60; turn++ {
+		for _, pid := range viewers {
+			// 观众每隔几个版本才更新一次，增量跨越多个版本
+			if pid == watcher["id"] && turn%4 != 0 {
+				continue
+			}
+			c := clients[pid]
+			_, res := m.Stat(pid, c.version)
+			if !c.update(t, res) {
+				t.Fatalf("player %d got the full state since version %d", pid, c.version)
+			}
+			c.check(t, m, pid)
+			if turn == 0 {
+				reserved := c.state["players"].([]any)[reserver].(map[string]any)["reserved"].([]any)
+				if _, shown := reserved[0].(map[string]any)["color"]; shown != (pid == reserver) {
+					t.Fatalf("viewer %d sees the reserved card: %v", pid, shown)
+				}
+			}
+		}
+		ended := false
+		m.Do(func() {
+			g := m.GamePtr
+			if g.State != engine.PlayingState {
+				ended = true
+				return
+			}
+			pid := g.ActivePlayerId
+			if err := g.ApplyMove(pid, b.ChooseMove(g, pid)); err != nil {
+				t.Fatal(err)
+			}
+			m.ChangeStatus()
+		})
+		if ended {
+			break
+		}
+		if turn%5 == 0 {
+			m.Chat(0, "hi", -1)
+		}
+	}
+
+	// 缓存中已经没有的版本返回完整状态
+	c := clients[viewers[0]]
+	for i := 0; i < MaxDeltaVersions; i++ {
+		m.Chat(0, "spam", -1)
+	}
+	if _, res := m.Stat(viewers[0], c.version); c.update(t, res) {
+		t.Fatalf("got a delta since version %d, %d versions ago", c.version, MaxDeltaVersions)
+	}
+	c.check(t, m, viewers[0])
+
+	// 恢复后没有旧版本的缓存，返回完整状态，之后的版本可以继续使用增量
+	m.Chat(0, "bye", -1)
+	restored := restoreSaved(t, m)
+	if _, res := restored.Stat(viewers[0], c.version); c.update(t, res) {
+		t.Fatal("got a delta right after the restore")
+	}
+	c.check(t, restored, viewers[0])
+	restored.Chat(0, "back", -1)
+	if _, res := restored.Stat(viewers[0], c.version); !c.update(t, res) {
+		t.Fatal("got the full state after the restore")
+	}
+	c.check(t, restored, viewers[0])
+}
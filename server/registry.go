@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// GameRegistry 并发安全的游戏表
+type GameRegistry struct {
+	sync.RWMutex
+	games map[string]*GameManager
+}
+
+// NewGameRegistry 创建空的游戏表
+func NewGameRegistry() *GameRegistry {
+	return &GameRegistry{games: make(map[string]*GameManager)}
+}
+
+// Get 获取游戏
+func (r *GameRegistry) Get(gameId string) (*GameManager, bool) {
+	r.RLock()
+	defer r.RUnlock()
+	m, exists := r.games[gameId]
+	return m, exists
+}
+
+// Add 添加游戏，游戏名已存在时返回 false
+func (r *GameRegistry) Add(gameId string, m *GameManager) bool {
+	r.Lock()
+	defer r.Unlock()
+	if _, exists := r.games[gameId]; exists {
+		return false
+	}
+	r.games[gameId] = m
+	return true
+}
+
+// Delete 删除游戏，返回被删除的游戏
+func (r *GameRegistry) Delete(gameId string) *GameManager {
+	r.Lock()
+	defer r.Unlock()
+	m := r.games[gameId]
+	delete(r.games, gameId)
+	return m
+}
+
+// List 按游戏名顺序返回所有游戏，返回的切片可以在不持有锁的情况下遍历
+func (r *GameRegistry) List() []*GameManager {
+	r.RLock()
+	list := make([]*GameManager, 0, len(r.games))
+	for _, m := range r.games {
+		list = append(list, m)
+	}
+	r.RUnlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].GameId < list[j].GameId
+	})
+	return list
+}
+
+// Len 游戏数量
+func (r *GameRegistry) Len() int {
+	r.RLock()
+	defer r.RUnlock()
+	return len(r.games)
+}
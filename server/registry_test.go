@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"splendor-go/engine"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// 测试在 server 目录中运行
+	gin.SetMode(gin.TestMode)
+	engine.CardFile = "../resources/cards.json"
+	os.Exit(m.Run())
+}
+
+// request 发送请求并解析返回的 JSON
+func request(t *testing.T, r http.Handler, method, path string, body any) gin.H {
+	_, res := send(t, r, method, path, body)
+	return res
+}
+
+// send 发送请求，返回状态码和解析后的 JSON，body 为字符串时原样发送
+func send(t *testing.T, r http.Handler, method, path string, body any) (int, gin.H) {
+	var reader *bytes.Reader
+	switch b := body.(type) {
+	case nil:
+		reader = bytes.NewReader(nil)
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Error(err)
+			return 0, nil
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	res := make(gin.H)
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Errorf("%s %s: %v", method, path, err)
+		return w.Code, nil
+	}
+	return w.Code, res
+}
+
+// TestConcurrentGames 同时进行多局游戏，在 -race 下检查创建、加入、操作、轮询和删除之间没有数据竞争
+func TestConcurrentGames(t *testing.T) {
+	const games, players, turns = 6, 3, 15
+	r := newRouter()
+	var wg sync.WaitGroup
+	for i := 0; i < games; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gameId := fmt.Sprintf("race-%d", i)
+			created := request(t, r, http.MethodPost, fmt.Sprintf("/create/%s?seed=%d", gameId, i), nil)
+			starter, _ := created["start"].(string)
+			if starter == "" {
+				t.Errorf("%s: create failed: %v", gameId, created)
+				return
+			}
+			manager, _ := GameMap.Get(gameId)
+
+			// 同时加入玩家和观众，观众一直轮询到游戏被删除
+			var joinWg, pollWg sync.WaitGroup
+			ids := make([]string, players)
+			for pid := 0; pid < players; pid++ {
+				joinWg.Add(1)
+				go func() {
+					defer joinWg.Done()
+					res := request(t, r, http.MethodPost, "/join/"+gameId, nil)
+					if id, ok := res["id"].(float64); ok {
+						ids[int(id)] = res["uuid"].(string)
+					} else {
+						t.Errorf("%s: join failed: %v", gameId, res)
+					}
+				}()
+			}
+			watched := request(t, r, http.MethodPost, "/spectate/"+gameId, nil)
+			pollWg.Add(1)
+			go func() {
+				defer pollWg.Done()
+				sid, version := int(watched["id"].(float64)), -1
+				for {
+					path := fmt.Sprintf("/poll/%s?pid=%d&uuid=%s", gameId, sid, watched["uuid"])
+					if version >= 0 {
+						path += fmt.Sprintf("&since=%d", version)
+					}
+					res := request(t, r, http.MethodGet, path, nil)
+					v, ok := res["version"].(float64)
+					if !ok {
+						return
+					}
+					version = int(v)
+				}
+			}()
+			joinWg.Wait()
+			if res := request(t, r, http.MethodPost, fmt.Sprintf("/start/%s/%s", gameId, starter), nil); res["error"] != nil {
+				t.Errorf("%s: start failed: %v", gameId, res)
+				return
+			}
+
+			var playWg sync.WaitGroup
+			for pid := 0; pid < players; pid++ {
+				playWg.Add(1)
+				go func(pid int) {
+					defer playWg.Done()
+					rng := rand.New(rand.NewSource(int64(i*players + pid)))
+					query := fmt.Sprintf("pid=%d&uuid=%s", pid, ids[pid])
+					for done := 0; done < turns; {
+						var move *engine.Move
+						over := false
+						if !manager.Do(func() {
+							g := manager.GamePtr
+							if g.State != engine.PlayingState {
+								over = true
+							} else if g.ActivePlayerId == pid {
+								moves := g.LegalMoves(pid)
+								move = &moves[rng.Intn(len(moves))]
+							}
+						}) || over {
+							return
+						}
+						if move == nil {
+							request(t, r, http.MethodGet, fmt.Sprintf("/stat/%s?%s&since=0", gameId, query), nil)
+							time.Sleep(time.Millisecond)
+							continue
+						}
+						res := request(t, r, http.MethodPost, fmt.Sprintf("/game/%s/turn?%s", gameId, query), move)
+						if result, _ := res["result"].(map[string]any); result["error"] != nil {
+							t.Errorf("%s: turn of player %d failed: %v", gameId, pid, result)
+							return
+						}
+						request(t, r, http.MethodPost, fmt.Sprintf("/game/%s/chat?%s", gameId, query), gin.H{"msg": "gg"})
+						done++
+					}
+				}(pid)
+			}
+			// 游戏进行中不断列出所有游戏
+			stop, listed := make(chan struct{}), make(chan struct{})
+			go func() {
+				defer close(listed)
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						request(t, r, http.MethodGet, "/list", nil)
+					}
+				}
+			}()
+			playWg.Wait()
+			close(stop)
+			<-listed
+			deleteGame(gameId)
+			pollWg.Wait()
+			if _, exists := GameMap.Get(gameId); exists {
+				t.Errorf("%s: still exists after deletion", gameId)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
+	"splendor-go/bot"
+	"splendor-go/engine"
 	"strconv"
 	"time"
 )
@@ -24,22 +25,44 @@ func CreateGameRouter(c *gin.Context) {
 	// fmt.Println("This is create!")
 	gameId := c.Param("game")
 
-	if _, exists := GameMap[gameId]; exists {
+	// 可选的随机种子，未指定时使用当前时间
+	seed := time.Now().UnixNano()
+	if seedStr := c.Query("seed"); seedStr != "" {
+		var err error
+		if seed, err = strconv.ParseInt(seedStr, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"result": gin.H{"error": "Invalid seed"},
+			})
+			return
+		}
+	}
+
+	req, ok := createRequestOf(c)
+	if !ok {
+		return
+	}
+
+	manager := NewGameManager(gameId, seed, req.Rules, req.RoomOptions)
+
+	if !GameMap.Add(gameId, manager) {
+		manager.Stop()
 		c.JSON(http.StatusBadRequest, gin.H{
 			"result": gin.H{"error": "Game already exists, try another name"},
 		})
 		return
 	}
+	manager.Save()
 
-	manager := NewGameManager(gameId)
-
-	GameMap[gameId] = manager
-	GameNum++
+	// 游戏加入 GameMap 后其他请求就可以修改状态，序列化需要在游戏的协程中进行
+	var state gin.H
+	manager.Do(func() {
+		state = manager.stateOf(-1)
+	})
 
 	c.JSON(http.StatusOK, gin.H{
 		"game":  gameId,
 		"start": manager.UuidStarter,
-		"state": SerializeGame(manager.GamePtr, -1),
+		"state": state,
 	})
 }
 
@@ -48,7 +71,7 @@ func JoinGameRouter(c *gin.Context) {
 	// fmt.Println("This is join!")
 	gameId := c.Param("game")
 
-	manager, exists := GameMap[gameId]
+	manager, exists := GameMap.Get(gameId)
 	if !exists {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"result": gin.H{"error": "Game not found"},
@@ -56,12 +79,7 @@ func JoinGameRouter(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, manager.JoinGame())
-
-	// 打印加入游戏的日志
-	timeStr := time.Now().Format("2006-01-02 15:04:05")
-	fmt.Printf("[%s] Game \"%s\" joined (%d players)\n",
-		timeStr, gameId, manager.GetPlayerNum())
+	c.JSON(manager.JoinGame())
 }
 
 // WatchGameRouter 观战游戏
@@ -69,7 +87,7 @@ func WatchGameRouter(c *gin.Context) {
 	// fmt.Println("This is watch!")
 	gameId := c.Param("game")
 
-	manager, exists := GameMap[gameId]
+	manager, exists := GameMap.Get(gameId)
 	if !exists {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"result": gin.H{"error": "Game not found"},
@@ -77,7 +95,7 @@ func WatchGameRouter(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, manager.WatchGame())
+	c.JSON(manager.WatchGame())
 }
 
 // StartGameRouter 开始游戏
@@ -86,7 +104,7 @@ func StartGameRouter(c *gin.Context) {
 	gameId := c.Param("game")
 	uuidStarter := c.Param("starter")
 
-	manager, exists := GameMap[gameId]
+	manager, exists := GameMap.Get(gameId)
 	if !exists {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Game not found"})
 		return
@@ -95,11 +113,40 @@ func StartGameRouter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "You are not the starter"})
 		return
 	}
-	if manager.Started {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Game has already started"})
+	c.JSON(manager.StartGame())
+}
+
+// AddBotRouter 由房主在空位上加入机器人
+func AddBotRouter(c *gin.Context) {
+	gameId := c.Param("game")
+	uuidStarter := c.Param("starter")
+
+	manager, exists := GameMap.Get(gameId)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game not found"})
+		return
+	}
+	if manager.UuidStarter != uuidStarter {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You are not the starter"})
 		return
 	}
-	c.JSON(http.StatusOK, manager.StartGame())
+	// mcts 机器人可以指定每步的模拟次数和思考时间
+	cfg := bot.Config{Level: c.DefaultQuery("level", bot.LevelMedium)}
+	if playouts := c.Query("playouts"); playouts != "" {
+		var err error
+		if cfg.Playouts, err = strconv.Atoi(playouts); err != nil || cfg.Playouts <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playouts"})
+			return
+		}
+	}
+	if budget := c.Query("budget"); budget != "" {
+		var err error
+		if cfg.Budget, err = time.ParseDuration(budget); err != nil || cfg.Budget <= 0 || cfg.Budget > MaxBotBudget*time.Second {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid budget"})
+			return
+		}
+	}
+	c.JSON(manager.AddBot(cfg))
 }
 
 // ChatRouter 聊天
@@ -110,6 +157,10 @@ func ChatRouter(c *gin.Context) {
 	if manager == nil {
 		return
 	}
+	since, ok := sinceOf(c)
+	if !ok {
+		return
+	}
 
 	// 从 JSON 请求体中读取 msg 字段
 	var msgJSON gin.H
@@ -121,7 +172,7 @@ func ChatRouter(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, manager.Chat(pid, msgJSON["msg"].(string)))
+	c.JSON(manager.Chat(pid, msgJSON["msg"].(string), since))
 }
 
 // NextTurnRouter 下一个回合
@@ -132,19 +183,12 @@ func NextTurnRouter(c *gin.Context) {
 	if manager == nil {
 		return
 	}
-
-	if pid != manager.GamePtr.ActivePlayerId {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Now is not your turn"})
+	since, ok := sinceOf(c)
+	if !ok {
 		return
 	}
 
-	manager.GamePtr.NextTurn()
-	manager.ChangeStatus()
-
-	c.JSON(http.StatusOK, gin.H{
-		"state":  SerializeGame(manager.GamePtr, pid),
-		"result": make(gin.H),
-	})
+	c.JSON(manager.NextTurn(pid, since))
 }
 
 // ActionRouter 游戏操作
@@ -155,45 +199,33 @@ func ActionRouter(c *gin.Context) {
 	if manager == nil {
 		return
 	}
-
-	game := manager.GamePtr
-	// 加锁防止并发问题
-	game.Lock()
-	defer game.Unlock()
-
-	if pid != game.ActivePlayerId {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Now is not your turn"})
+	since, ok := sinceOf(c)
+	if !ok {
 		return
 	}
 
-	act := c.Param("action")
-	target := c.Param("target")
+	c.JSON(manager.Act(pid, c.Param("action"), c.Param("target"), since))
+}
 
-	var result gin.H
+// TurnRouter 一次性提交完整回合
+func TurnRouter(c *gin.Context) {
+	manager, pid := validatePlayer(c)
 
-	switch act {
-	case "take":
-		result = game.Take(ReqColorMap[target])
-	case "discard":
-		result = game.Discard(ReqColorMap[target])
-	case "buy":
-		result = game.Buy(target)
-	case "reserve":
-		result = game.Reserve(target)
-	case "noble_visit":
-		result = game.VisitNobleActively(target)
-	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid action"})
+	if manager == nil {
 		return
 	}
-	if result == nil {
-		manager.ChangeStatus()
-		result = make(gin.H)
+	since, ok := sinceOf(c)
+	if !ok {
+		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"state":  SerializeGame(game, pid),
-		"result": result,
-	})
+
+	var move engine.Move
+	if err := c.ShouldBindJSON(&move); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid move"})
+		return
+	}
+
+	c.JSON(manager.SubmitTurn(pid, move, since))
 }
 
 // RenamePlayerRouter 重命名玩家
@@ -205,21 +237,14 @@ func RenamePlayerRouter(c *gin.Context) {
 		return
 	}
 
-	name := c.Param("name")
-	manager.GamePtr.RenamePlayer(pid, name)
-	manager.ChangeStatus()
-
-	c.JSON(http.StatusOK, gin.H{
-		// why?
-		"status": "ok",
-	})
+	c.JSON(manager.RenamePlayer(pid, c.Param("name")))
 }
 
 // SuggestRouter 建议游戏名
 func SuggestRouter(c *gin.Context) {
 	// fmt.Println("This is suggest!")
 	word := randomSuggestion()
-	for _, exists := GameMap[word]; exists; {
+	for _, exists := GameMap.Get(word); exists; _, exists = GameMap.Get(word) {
 		word = randomSuggestion()
 	}
 
@@ -237,11 +262,31 @@ func StatRouter(c *gin.Context) {
 	if manager == nil {
 		return
 	}
+	since, ok := sinceOf(c)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"state": SerializeGame(manager.GamePtr, pid),
-		"chat":  SerializeChatList(manager.ChatList),
-	})
+	c.JSON(manager.Stat(pid, since))
+}
+
+// HintRouter 为轮到的玩家列出建议的回合
+func HintRouter(c *gin.Context) {
+	manager, pid := validatePlayer(c)
+
+	if manager == nil {
+		return
+	}
+	n := DefaultHints
+	if nStr := c.Query("n"); nStr != "" {
+		var err error
+		if n, err = strconv.Atoi(nStr); err != nil || n <= 0 || n > MaxHints {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid n"})
+			return
+		}
+	}
+
+	c.JSON(manager.Hint(pid, n))
 }
 
 // PollRouter 轮询游戏状态
@@ -251,37 +296,286 @@ func PollRouter(c *gin.Context) {
 	if manager == nil {
 		return
 	}
+	since, ok := sinceOf(c)
+	if !ok {
+		return
+	}
 
-	result := manager.Poll(pid)
+	result := manager.Poll(pid, since)
 
 	// 返回结果
 	c.JSON(http.StatusOK, result)
 }
 
-// ListRouter 游戏列表
-func ListRouter(c *gin.Context) {
-	// fmt.Println("This is list!")
-	for k, manager := range GameMap {
-		// 游戏未开始则 10 分钟后删除
-		if !manager.Started && manager.CreateTime.Add(DeleteWaitingGame*time.Minute).Before(time.Now()) {
-			delete(GameMap, k)
-			GameNum--
+// ReplayRouter 回放游戏的第 step 步，游戏结束后公开所有隐藏信息
+func ReplayRouter(c *gin.Context) {
+	gameId := c.Param("game")
+
+	var events []engine.Event
+	var ended bool
+	manager, exists := GameMap.Get(gameId)
+	if !exists || !manager.Do(func() {
+		game := manager.GamePtr
+		events = append([]engine.Event{}, game.Events...)
+		ended = game.State == engine.EndedState
+	}) {
+		events, ended = latestArchivedEvents(gameId)
+	}
+	if events == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game not found"})
+		return
+	}
+
+	boundaries := engine.MoveBoundaries(events)
+	if len(boundaries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game has not started"})
+		return
+	}
+	step := len(boundaries) - 1
+	if stepStr := c.Query("step"); stepStr != "" {
+		var err error
+		if step, err = strconv.Atoi(stepStr); err != nil || step < 0 || step >= len(boundaries) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid step"})
+			return
+		}
+	}
+
+	game, err := engine.Replay(events[:boundaries[step]])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var state gin.H
+	if ended {
+		state = SerializeRevealedGame(game)
+	} else {
+		state = SerializeGame(game, -1)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"state":    state,
+		"step":     step,
+		"steps":    SerializeBoundaries(events, boundaries),
+		"revealed": ended,
+	})
+}
+
+// ReportRouter 已结束游戏的赛后统计，游戏已删除时使用最近一局的存档
+func ReportRouter(c *gin.Context) {
+	gameId := c.Param("game")
+
+	var events []engine.Event
+	manager, exists := GameMap.Get(gameId)
+	if !exists || !manager.Do(func() {
+		events = append([]engine.Event{}, manager.GamePtr.Events...)
+	}) {
+		events, _ = latestArchivedEvents(gameId)
+	}
+	if events == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game not found"})
+		return
+	}
+
+	game, err := engine.Replay(events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if game.State != engine.EndedState {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Game has not ended"})
+		return
+	}
+	players := make([]gin.H, 0, game.PlayerNum)
+	for _, r := range game.Report() {
+		players = append(players, SerializeReport(r))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"game":    gameId,
+		"turns":   len(engine.MoveBoundaries(events)) - 1,
+		"players": players,
+	})
+}
+
+// ArchiveListRouter 搜索已结束游戏的存档
+func ArchiveListRouter(c *gin.Context) {
+	if GameArchive == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Archive is not available"})
+		return
+	}
+	query := ArchiveQuery{Player: c.Query("player")}
+	var err error
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.ParseInLocation("2006-01-02", from, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date"})
+			return
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.ParseInLocation("2006-01-02", to, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date"})
+			return
 		}
-		// 游戏已开始则 24 小时后删除
-		if manager.Started && manager.CreateTime.Add(DeletePlayingGame*time.Hour).Before(time.Now()) {
-			delete(GameMap, k)
-			GameNum--
+		// 包含结束日期当天
+		query.To = query.To.AddDate(0, 0, 1)
+	}
+	if players := c.Query("players"); players != "" {
+		if query.PlayerNum, err = strconv.Atoi(players); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid players"})
+			return
 		}
 	}
+	records, err := GameArchive.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"games": records,
+	})
+}
+
+// ArchiveRouter 获取一局游戏的完整存档
+func ArchiveRouter(c *gin.Context) {
+	if GameArchive == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Archive is not available"})
+		return
+	}
+	record, err := GameArchive.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Archive not found"})
+		return
+	}
+	// 中途放弃的游戏只能通过 /replay 以观众的视角回放
+	if record.Abandoned {
+		record.Events = nil
+	}
+	c.JSON(http.StatusOK, record)
+}
+
+// ListRouter 游戏列表
+func ListRouter(c *gin.Context) {
+	// fmt.Println("This is list!")
 	jsonList := make([]gin.H, 0)
-	for _, manager := range GameMap {
-		jsonList = append(jsonList, SerializeGameManager(manager))
+	for _, manager := range GameMap.List() {
+		manager.Do(func() {
+			jsonList = append(jsonList, SerializeGameManager(manager))
+		})
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"games": jsonList,
 	})
 }
 
+// translateReqMove 将请求中回合的颜色转换为引擎使用的颜色
+func translateReqMove(move engine.Move) engine.Move {
+	move.Gems = translateReqColors(move.Gems)
+	move.Payment = translateReqColors(move.Payment)
+	move.Discards = translateReqColors(move.Discards)
+	if color, exists := ReqColorMap[move.Choice]; exists {
+		move.Choice = color
+	}
+	if move.PostGem != "" {
+		move.PostGem = ReqColorMap[move.PostGem]
+	}
+	return move
+}
+
+// translateReqColors 将请求中的颜色转换为引擎使用的颜色
+func translateReqColors(m map[string]int) map[string]int {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]int)
+	for color, count := range m {
+		key, exists := ReqColorMap[color]
+		if !exists {
+			key = color
+		}
+		result[key] += count
+	}
+	return result
+}
+
+// CreateRequest 创建房间时可选的请求体，包括房间选项和规则
+type CreateRequest struct {
+	RoomOptions
+	Rules engine.Rules `json:"rules"`
+}
+
+// createRequestOf 读取创建房间时的选项和规则，选项、城市模式和东方扩展也可以放在查询参数中
+// 未指定的选项关闭，未指定的规则使用标准规则
+func createRequestOf(c *gin.Context) (CreateRequest, bool) {
+	var req CreateRequest
+	fail := func(msg string) (CreateRequest, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"result": gin.H{"error": msg},
+		})
+		return req, false
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			return fail("Invalid request body")
+		}
+	}
+	if hints := c.Query("hints"); hints != "" {
+		var err error
+		if req.Hints, err = strconv.ParseBool(hints); err != nil {
+			return fail("Invalid hints")
+		}
+	}
+	if cities := c.Query("cities"); cities != "" {
+		var err error
+		if req.Rules.Cities, err = strconv.ParseBool(cities); err != nil {
+			return fail("Invalid cities")
+		}
+	}
+	if orient := c.Query("orient"); orient != "" {
+		var err error
+		if req.Rules.Orient, err = strconv.ParseBool(orient); err != nil {
+			return fail("Invalid orient")
+		}
+	}
+	if posts := c.Query("outposts"); posts != "" {
+		var err error
+		if req.Rules.Outposts, err = strconv.ParseBool(posts); err != nil {
+			return fail("Invalid outposts")
+		}
+	}
+	if strongholds := c.Query("strongholds"); strongholds != "" {
+		var err error
+		if req.Rules.Strongholds, err = strconv.ParseBool(strongholds); err != nil {
+			return fail("Invalid strongholds")
+		}
+	}
+	if err := req.Rules.Validate(); err != nil {
+		return fail("Invalid rules: " + err.Error())
+	}
+	// 卡牌定义在启动时已加载
+	if set, err := engine.DefaultCardSet(); err != nil {
+		return fail("Invalid rules: " + err.Error())
+	} else if err := req.Rules.CheckCardSet(set); err != nil {
+		return fail("Invalid rules: " + err.Error())
+	}
+	return req, true
+}
+
+// sinceOf 读取可选的 since 参数，未指定时为 -1，表示需要完整状态
+func sinceOf(c *gin.Context) (int, bool) {
+	sinceStr := c.Query("since")
+	if sinceStr == "" {
+		return -1, true
+	}
+	since, err := strconv.Atoi(sinceStr)
+	if err != nil || since < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since"})
+		return -1, false
+	}
+	return since, true
+}
+
 func validatePlayer(c *gin.Context) (*GameManager, int) {
 	gameId := c.Param("game")
 	pid, err := strconv.Atoi(c.Query("pid"))
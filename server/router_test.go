@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"splendor-go/bot"
+	"splendor-go/engine"
+	"testing"
+)
+
+// newRoom 通过接口创建并开始一局 n 名玩家的游戏，返回游戏管理器和每个玩家的 UUID，测试结束时删除游戏
+func newRoom(t *testing.T, r http.Handler, gameId, query string, n int) (*GameManager, []string) {
+	t.Helper()
+	created := request(t, r, http.MethodPost, fmt.Sprintf("/create/%s?%s", gameId, query), nil)
+	starter, _ := created["start"].(string)
+	if starter == "" {
+		t.Fatalf("create failed: %v", created)
+	}
+	t.Cleanup(func() {
+		deleteGame(gameId)
+	})
+	uuids := make([]string, n)
+	for i := range uuids {
+		res := request(t, r, http.MethodPost, "/join/"+gameId, nil)
+		if uuids[i], _ = res["uuid"].(string); uuids[i] == "" {
+			t.Fatalf("join failed: %v", res)
+		}
+	}
+	if res := request(t, r, http.MethodPost, fmt.Sprintf("/start/%s/%s", gameId, starter), nil); res["error"] != nil {
+		t.Fatalf("start failed: %v", res)
+	}
+	manager, _ := GameMap.Get(gameId)
+	return manager, uuids
+}
+
+// activePlayer 当前轮到的玩家
+func activePlayer(m *GameManager) int {
+	pid := -1
+	m.Do(func() {
+		pid = m.GamePtr.ActivePlayerId
+	})
+	return pid
+}
+
+// playToEnd 由机器人代替所有玩家进行游戏直到结束
+func playToEnd(t *testing.T, m *GameManager) {
+	t.Helper()
+	b, err := bot.New(bot.Config{Level: bot.LevelMedium, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Do(func() {
+		g := m.GamePtr
+		for turn := 0; turn < 500 && g.State == engine.PlayingState; turn++ {
+			pid := g.ActivePlayerId
+			if err := g.ApplyMove(pid, b.ChooseMove(g, pid)); err != nil {
+				t.Errorf("turn %d: %v", turn, err)
+				return
+			}
+		}
+		m.ChangeStatus()
+	})
+	if activePlayer(m) != -1 {
+		t.Fatal("the game didn't end")
+	}
+}
+
+func TestTurnRouter(t *testing.T) {
+	r := newRouter()
+	take := gin.H{"type": "take_different", "gems": gin.H{"w": 1, "u": 1, "g": 1}}
+	tests := []struct {
+		name   string
+		other  bool // 由没有轮到的玩家提交
+		uuid   string
+		body   any
+		status int
+		code   string // 回合被拒绝时的原因代码
+		error  string
+	}{
+		{"legal take", false, "", take, http.StatusOK, "", ""},
+		{"not your turn", true, "", take, http.StatusOK, "not_your_turn", "Now is not your turn"},
+		{"two gems", false, "", gin.H{"type": "take_different", "gems": gin.H{"w": 1, "u": 1}}, http.StatusOK, "invalid_gems", "You must take 3 gems of different colors"},
+		{"unknown card", false, "", gin.H{"type": "buy", "card": "nope"}, http.StatusOK, "card_unavailable", "This card is not available"},
+		{"unknown type", false, "", gin.H{"type": "jump"}, http.StatusOK, "invalid_move", "Invalid move type"},
+		{"malformed body", false, "", "{", http.StatusBadRequest, "", "Invalid move"},
+		{"wrong uuid", false, "nope", take, http.StatusBadRequest, "", "Invalid gameId / pid / uuid"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameId := fmt.Sprintf("turn-%d", i)
+			m, uuids := newRoom(t, r, gameId, "seed=1", 2)
+			active := activePlayer(m)
+			pid := active
+			if tt.other {
+				pid = 1 - active
+			}
+			uid := uuids[pid]
+			if tt.uuid != "" {
+				uid = tt.uuid
+			}
+			status, res := send(t, r, http.MethodPost, fmt.Sprintf("/game/%s/turn?pid=%d&uuid=%s", gameId, pid, uid), tt.body)
+			if status != tt.status {
+				t.Fatalf("got status %d, want %d: %v", status, tt.status, res)
+			} else if status != http.StatusOK {
+				if res["error"] != tt.error {
+					t.Fatalf("got error %v, want %q", res["error"], tt.error)
+				}
+				return
+			}
+			result, _ := res["result"].(map[string]any)
+			if code, _ := result["code"].(string); code != tt.code {
+				t.Fatalf("got result %v, want code %q", result, tt.code)
+			} else if tt.error != "" && result["error"] != tt.error {
+				t.Fatalf("got error %v, want %q", result["error"], tt.error)
+			}
+			// 合法的回合轮到下一个玩家，被拒绝的回合不改变状态
+			want := active
+			if tt.code == "" {
+				want = 1 - active
+			}
+			if got := activePlayer(m); got != want {
+				t.Fatalf("player %d is active after the turn, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestReplayRouter(t *testing.T) {
+	r := newRouter()
+	ended, _ := newRoom(t, r, "replay-ended", "seed=2", 2)
+	// 第一步预定一张卡牌，结束后的回放应当公开这张卡牌
+	var first int
+	var reserved string
+	ended.Do(func() {
+		g := ended.GamePtr
+		first, reserved = g.ActivePlayerId, g.Table[0][0].Uuid
+		if err := g.ApplyMove(first, engine.Move{Type: engine.MoveReserve, Card: reserved}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	playToEnd(t, ended)
+	playing, uuids := newRoom(t, r, "replay-playing", "seed=2", 2)
+	pid := activePlayer(playing)
+	res := request(t, r, http.MethodPost, fmt.Sprintf("/game/replay-playing/turn?pid=%d&uuid=%s", pid, uuids[pid]), gin.H{"type": "reserve", "card": reserved})
+	if result, _ := res["result"].(map[string]any); result["error"] != nil {
+		t.Fatalf("reserve failed: %v", result)
+	}
+	request(t, r, http.MethodPost, "/create/replay-waiting", nil)
+	t.Cleanup(func() {
+		deleteGame("replay-waiting")
+	})
+	var steps int
+	ended.Do(func() {
+		steps = len(engine.MoveBoundaries(ended.GamePtr.Events))
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		error    string
+		step     int
+		steps    int // 回放的总步数，为 0 时不检查
+		revealed bool
+		reserved int  // 第一个玩家预定的卡牌数量
+		color    bool // 预定的卡牌是否公开
+	}{
+		{"ended game", "/replay/replay-ended", http.StatusOK, "", steps - 1, steps, true, -1, false},
+		{"start of the game", "/replay/replay-ended?step=0", http.StatusOK, "", 0, steps, true, 0, false},
+		{"revealed reserve", "/replay/replay-ended?step=1", http.StatusOK, "", 1, steps, true, 1, true},
+		{"hidden reserve", "/replay/replay-playing", http.StatusOK, "", 1, 2, false, 1, false},
+		{"step too large", fmt.Sprintf("/replay/replay-ended?step=%d", steps), http.StatusBadRequest, "Invalid step", 0, 0, false, 0, false},
+		{"negative step", "/replay/replay-ended?step=-1", http.StatusBadRequest, "Invalid step", 0, 0, false, 0, false},
+		{"not started", "/replay/replay-waiting", http.StatusBadRequest, "Game has not started", 0, 0, false, 0, false},
+		{"unknown game", "/replay/replay-nope", http.StatusBadRequest, "Game not found", 0, 0, false, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := send(t, r, http.MethodGet, tt.path, nil)
+			if status != tt.status {
+				t.Fatalf("got status %d, want %d: %v", status, tt.status, res)
+			} else if status != http.StatusOK {
+				if res["error"] != tt.error {
+					t.Fatalf("got error %v, want %q", res["error"], tt.error)
+				}
+				return
+			}
+			if res["step"] != float64(tt.step) || res["revealed"] != tt.revealed {
+				t.Fatalf("got step %v revealed %v, want %d %v", res["step"], res["revealed"], tt.step, tt.revealed)
+			} else if n := len(res["steps"].([]any)); n != tt.steps {
+				t.Fatalf("got %d steps, want %d", n, tt.steps)
+			}
+			state := res["state"].(map[string]any)
+			// 种子只在最后一步公开
+			if _, exists := state["seed"]; exists != (tt.step == steps-1 && tt.revealed) {
+				t.Fatalf("seed shown: %v at step %d", exists, tt.step)
+			}
+			if tt.reserved < 0 {
+				return
+			}
+			cards := state["players"].([]any)[first].(map[string]any)["reserved"].([]any)
+			if len(cards) != tt.reserved {
+				t.Fatalf("got %d reserved cards, want %d", len(cards), tt.reserved)
+			}
+			for _, card := range cards {
+				if _, exists := card.(map[string]any)["color"]; exists != tt.color {
+					t.Fatalf("reserved card shown: %v, want %v", exists, tt.color)
+				}
+			}
+		})
+	}
+}
+
+// TestHintRouter 每个座位得到的建议都是可以原样提交的合法回合
+func TestHintRouter(t *testing.T) {
+	r := newRouter()
+	m, uuids := newRoom(t, r, "hints", "seed=3&hints=true&orient=true", 2)
+	for turn := 0; turn < 8; turn++ {
+		pid := activePlayer(m)
+		status, res := send(t, r, http.MethodGet, fmt.Sprintf("/hint/hints?pid=%d&uuid=%s&n=%d", pid, uuids[pid], MaxHints), nil)
+		if status != http.StatusOK {
+			t.Fatalf("turn %d: got status %d: %v", turn, status, res)
+		}
+		hints := res["hints"].([]any)
+		if len(hints) == 0 {
+			t.Fatalf("turn %d: no hints", turn)
+		}
+		bodies := make([]json.RawMessage, len(hints))
+		for i, hint := range hints {
+			bodies[i], _ = json.Marshal(hint.(map[string]any)["move"])
+			var move engine.Move
+			if err := json.Unmarshal(bodies[i], &move); err != nil {
+				t.Fatal(err)
+			}
+			var err error
+			m.Do(func() {
+				err = m.GamePtr.ValidateMove(pid, translateReqMove(move))
+			})
+			if err != nil {
+				t.Fatalf("turn %d: hint %s is rejected: %v", turn, bodies[i], err)
+			}
+		}
+		// 第一条建议通过回合接口提交
+		res = request(t, r, http.MethodPost, fmt.Sprintf("/game/hints/turn?pid=%d&uuid=%s", pid, uuids[pid]), string(bodies[0]))
+		if result, _ := res["result"].(map[string]any); result["error"] != nil {
+			t.Fatalf("turn %d: hint %s failed: %v", turn, bodies[0], result)
+		}
+	}
+
+	_, off := newRoom(t, r, "hints-off", "seed=3", 2)
+	request(t, r, http.MethodPost, "/create/hints-waiting?hints=true", nil)
+	t.Cleanup(func() {
+		deleteGame("hints-waiting")
+	})
+	joined := request(t, r, http.MethodPost, "/join/hints-waiting", nil)
+	active := activePlayer(m)
+	tests := []struct {
+		name   string
+		game   string
+		pid    int
+		uuid   string
+		query  string
+		status int
+		error  string
+	}{
+		{"not your turn", "hints", 1 - active, uuids[1-active], "", http.StatusBadRequest, "Now is not your turn"},
+		{"too many", "hints", active, uuids[active], "&n=11", http.StatusBadRequest, "Invalid n"},
+		{"hints disabled", "hints-off", 0, off[0], "", http.StatusForbidden, "Hints are disabled in this room"},
+		{"not started", "hints-waiting", 0, joined["uuid"].(string), "", http.StatusBadRequest, "Game is not in progress"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, res := send(t, r, http.MethodGet, fmt.Sprintf("/hint/%s?pid=%d&uuid=%s%s", tt.game, tt.pid, tt.uuid, tt.query), nil)
+			if status != tt.status || res["error"] != tt.error {
+				t.Fatalf("got %d %v, want %d %q", status, res["error"], tt.status, tt.error)
+			}
+		})
+	}
+}
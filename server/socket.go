@@ -0,0 +1,111 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"splendor-go/engine"
+)
+
+// SocketMessage 客户端通过 WebSocket 发送的消息
+type SocketMessage struct {
+	Type   string       `json:"type"`
+	Action string       `json:"action"`
+	Target string       `json:"target"`
+	Move   *engine.Move `json:"move"`
+	Msg    string       `json:"msg"`
+}
+
+// SocketRouter 建立 WebSocket 连接，状态改变时立即推送，并接收玩家的操作
+func SocketRouter(c *gin.Context) {
+	manager, pid := validatePlayer(c)
+
+	if manager == nil {
+		return
+	}
+
+	websocket.Handler(func(ws *websocket.Conn) {
+		serveSocket(ws, manager, pid)
+	}).ServeHTTP(c.Writer, c.Request)
+}
+
+func serveSocket(ws *websocket.Conn, m *GameManager, pid int) {
+	defer func(ws *websocket.Conn) {
+		_ = ws.Close()
+	}(ws)
+	notify := m.Subscribe()
+	defer m.Unsubscribe(notify)
+
+	// 所有写操作都在当前协程中完成，读协程只负责把响应交给当前协程
+	// done 在读协程退出时关闭，stopped 在当前协程退出时关闭，避免读协程阻塞在已满的 replies 上
+	replies := make(chan gin.H, 8)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		defer close(done)
+		for {
+			var msg SocketMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			status, body := handleSocketMessage(m, pid, &msg)
+			body["type"] = "response"
+			body["status"] = status
+			select {
+			case replies <- body:
+			case <-stopped:
+				return
+			}
+		}
+	}()
+
+	// 连接建立后先推送一次完整状态
+	push := func() bool {
+		var state gin.H
+		if !m.Do(func() {
+			state = m.deliver(pid, -1)
+		}) {
+			return false
+		}
+		state["type"] = "state"
+		return websocket.JSON.Send(ws, state) == nil
+	}
+	if !push() {
+		return
+	}
+	for {
+		select {
+		case <-notify:
+			if !push() {
+				return
+			}
+		case reply := <-replies:
+			if websocket.JSON.Send(ws, reply) != nil {
+				return
+			}
+		case <-m.quit:
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleSocketMessage 处理客户端的消息，与对应的 HTTP 接口行为一致
+func handleSocketMessage(m *GameManager, pid int, msg *SocketMessage) (int, gin.H) {
+	switch msg.Type {
+	case "action":
+		return m.Act(pid, msg.Action, msg.Target, -1)
+	case "turn":
+		if msg.Move == nil {
+			return http.StatusBadRequest, gin.H{"error": "Invalid move"}
+		}
+		return m.SubmitTurn(pid, *msg.Move, -1)
+	case "next":
+		return m.NextTurn(pid, -1)
+	case "chat":
+		return m.Chat(pid, msg.Msg, -1)
+	}
+	return http.StatusBadRequest, gin.H{"error": "Invalid message type"}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialSocket 以玩家的身份连接游戏的 WebSocket
+func dialSocket(t *testing.T, srv *httptest.Server, gameId string, pid int, uid string) *websocket.Conn {
+	t.Helper()
+	url := fmt.Sprintf("ws%s/ws/%s?pid=%d&uuid=%s", strings.TrimPrefix(srv.URL, "http"), gameId, pid, uid)
+	ws, err := websocket.Dial(url, "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = ws.Close()
+	})
+	if err := ws.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+// receive 读取下一条消息
+func receive(t *testing.T, ws *websocket.Conn) gin.H {
+	t.Helper()
+	msg := make(gin.H)
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestSocketRouter(t *testing.T) {
+	r := newRouter()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	tests := []struct {
+		name   string
+		other  bool // 由没有轮到的玩家发送
+		msg    gin.H
+		status int
+		error  string
+		code   string // 回合被拒绝时的原因代码
+		push   bool   // 其他连接收到新的状态
+	}{
+		{"take a gem", false, gin.H{"type": "action", "action": "take", "target": "w"}, http.StatusOK, "", "", true},
+		{"illegal turn", false, gin.H{"type": "turn", "move": gin.H{"type": "take_different", "gems": gin.H{"w": 1, "u": 1}}}, http.StatusOK, "", "invalid_gems", false},
+		{"not your turn", true, gin.H{"type": "action", "action": "take", "target": "w"}, http.StatusBadRequest, "Now is not your turn", "", false},
+		{"turn without a move", false, gin.H{"type": "turn"}, http.StatusBadRequest, "Invalid move", "", false},
+		{"unknown type", false, gin.H{"type": "jump"}, http.StatusBadRequest, "Invalid message type", "", false},
+		{"chat", true, gin.H{"type": "chat", "msg": "hello"}, http.StatusOK, "", "", true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameId := fmt.Sprintf("socket-%d", i)
+			m, uuids := newRoom(t, r, gameId, "seed=1", 2)
+			pid := activePlayer(m)
+			if tt.other {
+				pid = 1 - pid
+			}
+			ws := dialSocket(t, srv, gameId, pid, uuids[pid])
+			watcher := dialSocket(t, srv, gameId, 1-pid, uuids[1-pid])
+			// 连接后先收到一次完整状态
+			var version float64
+			for _, conn := range []*websocket.Conn{ws, watcher} {
+				first := receive(t, conn)
+				if first["type"] != "state" || first["state"] == nil {
+					t.Fatalf("got %v, want the full state", first)
+				}
+				version = first["version"].(float64)
+			}
+
+			if err := websocket.JSON.Send(ws, tt.msg); err != nil {
+				t.Fatal(err)
+			}
+			// 状态的推送可能先于回复到达
+			var res gin.H
+			for res == nil {
+				if msg := receive(t, ws); msg["type"] == "response" {
+					res = msg
+				}
+			}
+			if res["status"] != float64(tt.status) {
+				t.Fatalf("got status %v, want %d: %v", res["status"], tt.status, res)
+			} else if tt.status != http.StatusOK {
+				if res["error"] != tt.error {
+					t.Fatalf("got error %v, want %q", res["error"], tt.error)
+				}
+				return
+			}
+			result, _ := res["result"].(map[string]any)
+			if code, _ := result["code"].(string); code != tt.code {
+				t.Fatalf("got result %v, want code %q", result, tt.code)
+			}
+			if !tt.push {
+				return
+			}
+			pushed := receive(t, watcher)
+			if pushed["type"] != "state" || pushed["version"].(float64) <= version {
+				t.Fatalf("got %v, want a newer state than version %v", pushed, version)
+			}
+			if msg, exists := tt.msg["msg"]; exists {
+				chat := pushed["chat"].([]any)
+				if len(chat) != 1 || chat[0].(map[string]any)["msg"] != msg {
+					t.Fatalf("got chat %v, want %q", chat, msg)
+				}
+			}
+		})
+	}
+
+	t.Run("wrong uuid", func(t *testing.T) {
+		newRoom(t, r, "socket-uuid", "seed=1", 2)
+		status, res := send(t, r, http.MethodGet, "/ws/socket-uuid?pid=0&uuid=nope", nil)
+		if status != http.StatusBadRequest || res["error"] != "Invalid gameId / pid / uuid" {
+			t.Fatalf("got %d %v", status, res)
+		}
+	})
+}
@@ -3,23 +3,82 @@ package main
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"os"
+	"path/filepath"
+	"splendor-go/engine"
+	"time"
 )
 
 func main() {
+	r := newRouter()
+
+	InitRoomWords()
+
+	// 卡牌定义有误时无法创建游戏，启动时就退出
+	if file := os.Getenv("SPLENDOR_CARD_FILE"); file != "" {
+		engine.CardFile = file
+	}
+	if _, err := engine.DefaultCardSet(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// 加载持久化的游戏
+	dataDir := os.Getenv("SPLENDOR_DATA_DIR")
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	if archive, err := NewFileArchive(filepath.Join(dataDir, "archive")); err != nil {
+		fmt.Println(err)
+	} else {
+		GameArchive = archive
+	}
+	if store, err := NewFileStore(filepath.Join(dataDir, "games")); err != nil {
+		fmt.Println(err)
+	} else {
+		Store = store
+		LoadGames()
+	}
+
+	// 后台清理长时间无操作的游戏
+	janitor := NewJanitor()
+	janitor.Interval = durationEnv("SPLENDOR_JANITOR_INTERVAL", janitor.Interval)
+	janitor.WaitingTimeout = durationEnv("SPLENDOR_WAITING_TIMEOUT", janitor.WaitingTimeout)
+	janitor.PlayingTimeout = durationEnv("SPLENDOR_PLAYING_TIMEOUT", janitor.PlayingTimeout)
+	janitor.EndedTimeout = durationEnv("SPLENDOR_ENDED_TIMEOUT", janitor.EndedTimeout)
+	go janitor.Run(make(chan struct{}))
+
+	err := r.Run(":8333")
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// newRouter 注册所有接口
+func newRouter() *gin.Engine {
 	r := gin.Default()
 
 	r.POST("/create/:game", CreateGameRouter)
 	r.POST("/join/:game", JoinGameRouter)
 	r.POST("/spectate/:game", WatchGameRouter)
 	r.POST("/start/:game/:starter", StartGameRouter)
+	r.POST("/addbot/:game/:starter", AddBotRouter)
 	r.POST("/game/:game/chat", ChatRouter)
 	r.POST("/game/:game/next", NextTurnRouter)
+	r.POST("/game/:game/turn", TurnRouter)
 	r.POST("/game/:game/:action/:target", ActionRouter)
 	r.POST("/rename/:game/:name", RenamePlayerRouter)
 	r.GET("/suggest", SuggestRouter)
 	r.GET("/stat/:game", StatRouter)
 	r.GET("/poll/:game", PollRouter)
+	r.GET("/hint/:game", HintRouter)
+	r.GET("/ws/:game", SocketRouter)
+	r.GET("/events/:game", EventsRouter)
 	r.GET("/list", ListRouter)
+	r.GET("/replay/:game", ReplayRouter)
+	r.GET("/report/:game", ReportRouter)
+	r.GET("/archive", ArchiveListRouter)
+	r.GET("/archive/:id", ArchiveRouter)
 
 	r.StaticFile("/", "./static/index.html")
 
@@ -30,11 +89,19 @@ func main() {
 	r.GET("/static/*filepath", func(c *gin.Context) {
 		c.File("./static" + c.Param("filepath"))
 	})
+	return r
+}
 
-	InitRoomWords()
-
-	err := r.Run(":8333")
-	if err != nil {
-		fmt.Println(err)
+// durationEnv 读取时长类型的环境变量，如 "30m"，未设置或无效时使用默认值
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		fmt.Printf("Invalid %s: %q, using %s\n", key, value, def)
+		return def
 	}
+	return d
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"splendor-go/bot"
+	"splendor-go/engine"
+	"strings"
+	"time"
+)
+
+var (
+	// Store 游戏存储，为 nil 时不进行持久化
+	Store GameStore
+)
+
+// GameStore 游戏的持久化存储
+type GameStore interface {
+	Save(s *GameSnapshot) error
+	Delete(gameId string) error
+	LoadAll() ([]*GameSnapshot, error)
+}
+
+// GameSnapshot 需要持久化的游戏管理器状态，游戏本身由事件重建
+type GameSnapshot struct {
+	GameId      string             `json:"game_id"`
+	UuidStarter string             `json:"uuid_starter"`
+	CreateTime  time.Time          `json:"create_time"`
+	Options     RoomOptions        `json:"options"`
+	Started     bool               `json:"started"`
+	Archived    bool               `json:"archived"`
+	Version     int                `json:"version"`
+	Bots        map[int]bot.Config `json:"bots,omitempty"`
+	Ended       map[int]bool       `json:"ended"`
+	ChatList    []*Chat            `json:"chat"`
+	Events      []engine.Event     `json:"events"`
+}
+
+// FileStore 基于文件的存储，每个游戏保存为目录下的一个 JSON 文件
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore 创建基于文件的存储
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// Save 保存游戏，先写入临时文件再重命名，避免写入一半时损坏
+func (s *FileStore) Save(snapshot *GameSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	path := s.pathOf(snapshot.GameId)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Delete 删除游戏
+func (s *FileStore) Delete(gameId string) error {
+	err := os.Remove(s.pathOf(gameId))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// LoadAll 加载所有游戏
+func (s *FileStore) LoadAll() ([]*GameSnapshot, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*GameSnapshot, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshot := new(GameSnapshot)
+		if err := json.Unmarshal(data, snapshot); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// pathOf 游戏名来自 URL，编码后再作为文件名
+func (s *FileStore) pathOf(gameId string) string {
+	return filepath.Join(s.Dir, base64.RawURLEncoding.EncodeToString([]byte(gameId))+".json")
+}
+
+// LoadGames 从存储中恢复所有游戏
+func LoadGames() {
+	if Store == nil {
+		return
+	}
+	snapshots, err := Store.LoadAll()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, s := range snapshots {
+		manager, err := restoreGameManager(s)
+		if err != nil {
+			fmt.Printf("Failed to restore game \"%s\": %v\n", s.GameId, err)
+			continue
+		}
+		GameMap.Add(s.GameId, manager)
+	}
+	fmt.Printf("Restored %d games\n", GameMap.Len())
+}
+
+func restoreGameManager(s *GameSnapshot) (*GameManager, error) {
+	game, err := engine.Replay(s.Events)
+	if err != nil {
+		return nil, err
+	}
+	m := &GameManager{
+		GameId:      s.GameId,
+		UuidStarter: s.UuidStarter,
+		GamePtr:     game,
+		Changed:     make(map[int]bool),
+		Ended:       s.Ended,
+		ChatList:    s.ChatList,
+		CreateTime:  s.CreateTime,
+		Options:     s.Options,
+		Started:     s.Started,
+		Archived:    s.Archived,
+		Version:     s.Version,
+		Bots:        make(map[int]bot.Bot),
+		BotConfigs:  make(map[int]bot.Config),
+	}
+	for pid, cfg := range s.Bots {
+		b, err := bot.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.Bots[pid] = b
+		m.BotConfigs[pid] = cfg
+	}
+	if m.Ended == nil {
+		m.Ended = make(map[int]bool)
+	}
+	if m.ChatList == nil {
+		m.ChatList = make([]*Chat, 0)
+	}
+	// 重新连接的玩家需要立即获取一次状态，在启动前设置以免与管理器竞争
+	for _, p := range game.Players {
+		if p != nil {
+			m.Changed[p.Id] = true
+		}
+	}
+	// 更早的版本无法重建，请求时返回完整状态
+	m.markVersion()
+	m.start()
+	// 恢复时正轮到机器人则继续
+	m.Do(m.scheduleBot)
+	return m, nil
+}
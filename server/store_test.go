@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"splendor-go/bot"
+	"splendor-go/engine"
+	"testing"
+	"time"
+)
+
+// useFileStore 测试期间使用临时目录中的文件存储
+func useFileStore(t *testing.T) *FileStore {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Store = store
+	t.Cleanup(func() {
+		Store = nil
+	})
+	return store
+}
+
+// managerState 游戏管理器中需要在恢复后保持不变的状态
+func managerState(t *testing.T, m *GameManager) string {
+	t.Helper()
+	var data []byte
+	var err error
+	m.Do(func() {
+		g := m.GamePtr
+		views := make(map[int]any)
+		taken := make(map[int]map[string]int)
+		for _, p := range g.Players {
+			if p != nil {
+				views[p.Id] = SerializeGame(g, p.Id)
+				taken[p.Id] = p.Taken
+			}
+		}
+		data, err = json.Marshal(gin.H{
+			"events":  g.Events,
+			"views":   views,
+			"taken":   taken,
+			"active":  g.ActivePlayerId,
+			"chat":    m.ChatList,
+			"bots":    m.BotConfigs,
+			"version": m.Version,
+			"started": m.Started,
+			"options": m.Options,
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// restoreSaved 关闭原来的游戏管理器，从存储中恢复游戏
+func restoreSaved(t *testing.T, m *GameManager) *GameManager {
+	t.Helper()
+	m.Stop()
+	snapshots, err := Store.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range snapshots {
+		if s.GameId != m.GameId {
+			continue
+		}
+		restored, err := restoreGameManager(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(restored.Stop)
+		return restored
+	}
+	t.Fatalf("game %s wasn't saved", m.GameId)
+	return nil
+}
+
+// newBotGame 开始一局一名玩家对一个机器人的游戏，开启所有会影响存档的扩展，返回玩家和机器人的编号
+func newBotGame(t *testing.T, gameId string) (*GameManager, int, int) {
+	t.Helper()
+	rules := engine.Rules{Orient: true, Cities: true, Outposts: true}
+	m := NewGameManager(gameId, 5, rules, RoomOptions{Hints: true})
+	t.Cleanup(m.Stop)
+	if _, res := m.JoinGame(); res["error"] != nil {
+		t.Fatal(res["error"])
+	}
+	_, res := m.AddBot(bot.Config{Level: bot.LevelEasy, Seed: 3})
+	if res["error"] != nil {
+		t.Fatal(res["error"])
+	}
+	if _, res := m.StartGame(); res["error"] != nil {
+		t.Fatal(res["error"])
+	}
+	human, botId := 0, res["id"].(int)
+	// 机器人先手时等它走完
+	waitFor(t, func() bool { return activePlayer(m) == human })
+	return m, human, botId
+}
+
+// waitFor 等待条件成立，最多等待几个机器人回合的时间
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * BotDelay * time.Millisecond)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRestoreMidTurn(t *testing.T) {
+	useFileStore(t)
+	m, human, _ := newBotGame(t, "store-mid-turn")
+	m.Chat(human, "hello", -1)
+	// 拿了一个宝石后存档，回合还没有结束
+	if _, res := m.Act(human, string(engine.ActionTake), "w", -1); res["error"] != nil {
+		t.Fatal(res["error"])
+	}
+	want := managerState(t, m)
+
+	restored := restoreSaved(t, m)
+	if got := managerState(t, restored); got != want {
+		t.Fatalf("restored state differs:\n got %s\nwant %s", got, want)
+	}
+	// 恢复后可以继续这个回合
+	for _, color := range []string{"u", "g"} {
+		if _, res := restored.Act(human, string(engine.ActionTake), color, -1); res["error"] != nil {
+			t.Fatal(res["error"])
+		}
+	}
+	if active := activePlayer(restored); active == human {
+		t.Fatal("the turn didn't end after taking three gems")
+	}
+}
+
+func TestRestoreBotTurn(t *testing.T) {
+	useFileStore(t)
+	m, human, botId := newBotGame(t, "store-bot-turn")
+	if _, res := m.SubmitTurn(human, engine.Move{Type: engine.MoveTakeDifferent, Gems: map[string]int{"w": 1, "u": 1, "g": 1}}, -1); res["result"].(gin.H)["error"] != nil {
+		t.Fatal(res["result"])
+	}
+	// 存档时轮到机器人，原来的管理器在机器人行动前关闭
+	want := managerState(t, m)
+	restored := restoreSaved(t, m)
+	var pending bool
+	restored.Do(func() {
+		pending = restored.botPending && restored.GamePtr.ActivePlayerId == botId
+	})
+	if !pending {
+		t.Fatal("the bot's turn isn't scheduled after the restore")
+	}
+	if got := managerState(t, restored); got != want {
+		t.Fatalf("restored state differs:\n got %s\nwant %s", got, want)
+	}
+	// 机器人在恢复后的游戏中完成回合
+	waitFor(t, func() bool { return activePlayer(restored) == human })
+	var changed bool
+	restored.Do(func() {
+		changed = restored.Changed[human]
+	})
+	if !changed {
+		t.Fatal("the player isn't notified of the bot's turn")
+	}
+}
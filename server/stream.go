@@ -0,0 +1,87 @@
+package main
+
+import (
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// EventsRouter 以 Server-Sent Events 推送状态和聊天，适合观众等只接收不操作的客户端
+func EventsRouter(c *gin.Context) {
+	manager, pid := validatePlayer(c)
+
+	if manager == nil {
+		return
+	}
+
+	// 断线重连时浏览器会带上 Last-Event-ID，首次连接也可以通过参数指定
+	lastId := -1
+	lastStr := c.GetHeader("Last-Event-ID")
+	if lastStr == "" {
+		lastStr = c.Query("last_event_id")
+	}
+	if lastStr != "" {
+		var err error
+		if lastId, err = strconv.Atoi(lastStr); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	notify := manager.Subscribe()
+	defer manager.Unsubscribe(notify)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	first := true
+	c.Stream(func(w io.Writer) bool {
+		if !first {
+			select {
+			case <-notify:
+			case <-manager.quit:
+				return false
+			case <-c.Request.Context().Done():
+				return false
+			}
+		}
+		first = false
+		var ok bool
+		lastId, ok = streamUpdates(c, manager, pid, lastId)
+		return ok
+	})
+}
+
+// streamUpdates 发送 lastId 之后的更新，返回最新的事件 ID，游戏已关闭时返回 false
+// 错过的聊天逐条发送，状态只发送最新的一次，只有最后一条事件带有 ID
+func streamUpdates(c *gin.Context, m *GameManager, pid, lastId int) (int, bool) {
+	var version int
+	events := make([]sse.Event, 0)
+	if !m.Do(func() {
+		version = m.Version
+		if version <= lastId {
+			return
+		}
+		m.markDelivered(pid)
+		for _, chat := range m.ChatList {
+			if chat.Version > lastId {
+				events = append(events, sse.Event{Event: "chat", Data: SerializeChat(chat)})
+			}
+		}
+		events = append(events, sse.Event{
+			Id:    strconv.Itoa(version),
+			Event: "state",
+			Data:  SerializeGame(m.GamePtr, pid),
+		})
+	}) {
+		return lastId, false
+	}
+	for _, e := range events {
+		c.Render(-1, e)
+	}
+	if len(events) == 0 {
+		return lastId, true
+	}
+	return version, true
+}
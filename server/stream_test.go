@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamEvent 一条 Server-Sent Event
+type streamEvent struct {
+	Id    string
+	Event string
+	Data  map[string]any
+}
+
+// readEvent 读取下一条事件
+func readEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+	var e streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if e.Event != "" {
+				return e
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, ":")
+		switch key {
+		case "id":
+			e.Id = value
+		case "event":
+			e.Event = value
+		case "data":
+			if err := json.Unmarshal([]byte(value), &e.Data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestEventsRouter(t *testing.T) {
+	r := newRouter()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	client := &http.Client{Timeout: 5 * time.Second}
+	msgs := []string{"one", "two", "three"}
+	tests := []struct {
+		name   string
+		after  int    // 客户端已经收到的聊天数量，为 -1 时不带 ID 连接
+		header bool   // 通过 Last-Event-ID 传递 ID，否则通过参数
+		chats  string // 连接后收到的聊天
+	}{
+		{"first connection", -1, false, "one two three"},
+		{"resume after the first chat", 1, true, "two three"},
+		{"resume by query", 2, false, "three"},
+		{"resume before any chat", 0, true, "one two three"},
+		{"up to date", 3, true, "four"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameId := fmt.Sprintf("events-%d", i)
+			m, uuids := newRoom(t, r, gameId, "seed=1", 2)
+			// versions[i] 为发送第 i 条聊天之后的版本
+			versions := make([]int, 0, len(msgs)+1)
+			version := func() int {
+				v := 0
+				m.Do(func() {
+					v = m.Version
+				})
+				return v
+			}
+			versions = append(versions, version())
+			for _, msg := range msgs {
+				m.Chat(0, msg, -1)
+				versions = append(versions, version())
+			}
+
+			req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/events/%s?pid=1&uuid=%s", srv.URL, gameId, uuids[1]), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.after >= 0 && tt.header {
+				req.Header.Set("Last-Event-ID", strconv.Itoa(versions[tt.after]))
+			} else if tt.after >= 0 {
+				req.URL.RawQuery += fmt.Sprintf("&last_event_id=%d", versions[tt.after])
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("got status %d", resp.StatusCode)
+			}
+			reader := bufio.NewReader(resp.Body)
+			// 已经是最新状态时不发送任何事件，直到状态再次改变，收到响应时连接已经订阅了状态变化
+			want := versions[len(versions)-1]
+			if tt.after == len(msgs) {
+				m.Chat(0, "four", -1)
+				want = version()
+			}
+			chats := make([]string, 0)
+			for {
+				e := readEvent(t, reader)
+				if e.Event == "chat" {
+					if e.Id != "" {
+						t.Fatalf("chat %v has an id", e.Data)
+					}
+					chats = append(chats, e.Data["msg"].(string))
+					continue
+				} else if e.Event != "state" {
+					t.Fatalf("unexpected event %q", e.Event)
+				}
+				if e.Id != strconv.Itoa(want) {
+					t.Fatalf("got state with id %s, want %d", e.Id, want)
+				} else if e.Data["players"] == nil {
+					t.Fatalf("got state %v", e.Data)
+				}
+				break
+			}
+			if got := strings.Join(chats, " "); got != tt.chats {
+				t.Fatalf("got chats %q, want %q", got, tt.chats)
+			}
+		})
+	}
+
+	t.Run("invalid id", func(t *testing.T) {
+		_, uuids := newRoom(t, r, "events-invalid", "seed=1", 2)
+		for _, path := range []string{
+			"/events/events-invalid?pid=0&uuid=" + uuids[0] + "&last_event_id=abc",
+			"/events/events-invalid?pid=0&uuid=nope",
+		} {
+			status, res := send(t, r, http.MethodGet, path, nil)
+			if status != http.StatusBadRequest || res["error"] == nil {
+				t.Fatalf("%s: got %d %v", path, status, res)
+			}
+		}
+	})
+}
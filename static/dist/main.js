@@ -1,4 +1,4 @@
-!function(e){var t={};function a(s){if(t[s])return t[s].exports;var i=t[s]={i:s,l:!1,exports:{}};return e[s].call(i.exports,i,i.exports,a),i.l=!0,i.exports}a.m=e,a.c=t,a.d=function(e,t,s){a.o(e,t)||Object.defineProperty(e,t,{enumerable:!0,get:s})},a.r=function(e){"undefined"!=typeof Symbol&&Symbol.toStringTag&&Object.defineProperty(e,Symbol.toStringTag,{value:"Module"}),Object.defineProperty(e,"__esModule",{value:!0})},a.t=function(e,t){if(1&t&&(e=a(e)),8&t)return e;if(4&t&&"object"==typeof e&&e&&e.__esModule)return e;var s=Object.create(null);if(a.r(s),Object.defineProperty(s,"default",{enumerable:!0,value:e}),2&t&&"string"!=typeof e)for(var i in e)a.d(s,i,function(t){return e[t]}.bind(null,i));return s},a.n=function(e){var t=e&&e.__esModule?function(){return e.default}:function(){return e};return a.d(t,"a",t),t},a.o=function(e,t){return Object.prototype.hasOwnProperty.call(e,t)},a.p="",a(a.s=0)}([function(e,t,a){"use strict";var s=this&&this.__awaiter||function(e,t,a,s){return new(a||(a=Promise))((function(i,n){function r(e){try{l(s.next(e))}catch(e){n(e)}}function o(e){try{l(s.throw(e))}catch(e){n(e)}}function l(e){var t;e.done?i(e.value):(t=e.value,t instanceof a?t:new a((function(e){e(t)}))).then(r,o)}l((s=s.apply(e,t||[])).next())}))};Object.defineProperty(t,"__esModule",{value:!0});const i=a(1),n=a(2);a(3);let r=e=>!1;!function(){let e=0;const t=["b","u","w","g","r"],a=t.concat(["*"]),o=["level3","level2","level1"];function l(e,t,s,n,r){return a.map(a=>{var o=a+"chip";return"*"===a&&(o="schip"),i.createElement("div",{className:"gem "+o,key:a+"_colors_"+r},i.createElement("div",{className:"bubble"},e[a]),i.createElement("div",{className:"underlay",onClick:s.bind(t,a)},n))})}function c(e,t){return e.map(e=>i.createElement(m,{key:e.uuid,noble:e,game:t}))}class d extends i.PureComponent{render(){const e=this.props.card,a=this.props.game;var s=a.buy.bind(a,e.uuid);const n=t=>{t.preventDefault(),a.reserve.bind(a)(e.uuid)};return e.color?i.createElement("div",{className:"card card-"+e.color+" card-"+e.level,id:e.uuid},i.createElement("div",{className:"reserve",onClick:n},i.createElement("img",{className:"floppy",src:"static/img/floppy.png"})),i.createElement("div",{className:"overlay",onClick:s}),i.createElement("div",{className:"underlay"},i.createElement("div",{className:"header"},i.createElement("div",{className:"color "+e.color+"gem"}),i.createElement("div",{className:"points"},e.points>0&&e.points)),i.createElement("div",{className:"costs"},t.map(t=>{if(e.cost[t]>0)return i.createElement("div",{key:e.uuid+"_cost_"+t,className:"cost "+t},e.cost[t])})))):i.createElement("div",{className:"deck "+e.level})}}class m extends i.PureComponent{render(){const e=this.props.noble,a=this.props.game,s=a.noble.bind(a,e.uuid);return i.createElement("div",{className:"noble",onClick:s,id:"noble"+e.id},i.createElement("div",{className:"side-bar"},i.createElement("div",{className:"points"},e.points>0&&e.points),i.createElement("div",{className:"requirement"},t.map(t=>{if(e.requirement[t]>0)return i.createElement("div",{key:e.uuid+"_req_"+t,className:"requires "+t},e.requirement[t])}))))}}class h extends i.PureComponent{constructor(){super(...arguments),this.state={editingName:null},this.editName=e=>{this.setState({editingName:e.target.value})},this.focusName=e=>{e.target.select()},this.submitName=()=>{this.props.game.rename(this.state.editingName),this.setState({editingName:null})},this.keypress=e=>{"Enter"===e.key&&this.submitName()}}render(){const e=this.props.game,s=this.props.pid,n=e.selectPlayer.bind(e,s),r={};a.map(e=>{r[e]={cards:0,gems:this.props.gems[e]}});const o=t.map(t=>{var a=this.props.cards[t].map(a=>(r[t].cards+=1,i.createElement("div",{key:s+"_card_"+a.uuid,className:"colorSetInner"},i.createElement(d,{key:a.uuid,card:a,game:e}))));return i.createElement("div",{key:s+"_set_"+t,className:"colorSet"},a,i.createElement("div",{className:a.length>0?"endcap":"spacer"}))}),m=a.map(e=>i.createElement("div",{className:"statSet",key:"stat"+e},i.createElement("div",{className:`stat stat${"*"===e?"y":e}`},r[e].gems+("*"==e?"":" / "+r[e].cards)),"*"===e?i.createElement(i.Fragment,null):i.createElement("div",null,i.createElement("img",{className:"labelImg",src:"static/img/labels.png"})))),h=e.props.pid===s?" you selected":"",u=e.props.pid===s?" (you)":"",p=l(this.props.gems,e,e.discard,"X",s),g=this.props.reserved?this.props.reserved.map(t=>i.createElement(d,{key:t.uuid+"_inner",card:t,game:e})):[],y=this.props.reserved?g.length:this.props.nreserved,f=c(this.props.nobles,e);return i.createElement("div",{className:"player"+h},i.createElement("div",{className:"playerHeader"},i.createElement("div",{className:"playerPoints"},this.props.points),null==this.state.editingName?i.createElement(i.Fragment,null,i.createElement("div",{className:"playerName",onClick:n},this.props.name),e.props.pid===s&&null==this.state.editingName?i.createElement("div",{className:"pencil",onClick:()=>this.setState({editingName:this.props.name})},"✏️"):i.createElement(i.Fragment,null)):i.createElement("div",{className:"playerName"},i.createElement("input",{className:"nameInput",type:"text",value:this.state.editingName,autoFocus:!0,onKeyPress:this.keypress,onFocus:this.focusName,onBlur:this.submitName,onChange:this.editName})),i.createElement("div",{className:"playerName2"},u),e.state.turn===s&&i.createElement("div",{className:"turnIndicator"},"←")),e.state.selectedPlayer===s?i.createElement("div",{className:"floater"},i.createElement("div",{className:"cards"},o),i.createElement("div",{className:"nobles"},f),i.createElement("div",{className:"gems"},p),i.createElement("div",{className:"reserveArea"},y>0&&i.createElement("div",null,i.createElement("div",{className:"reserveText"},"reserved"),i.createElement("div",{className:"reserveCards"},g)))):i.createElement("div",{className:"stats"},i.createElement("div",{className:"gem-stats"},m),i.createElement("div",{className:"reservedStat"},g)))}}class u extends i.PureComponent{render(){return i.createElement("div",null,i.createElement("div",{className:"deck "+this.props.name},i.createElement("div",{className:"remaining"},this.props.remaining),i.createElement("div",{className:"overlay"}),i.createElement("div",{className:"reserve",onClick:this.props.game.reserve.bind(this.props.game,this.props.name)},i.createElement("img",{className:"floppy",src:"static/img/floppy.png"}))),i.createElement("div",{className:"c_"+this.props.name+" face-up-cards"},i.createElement("div",{className:"cards-inner"},this.props.cards&&this.props.cards.map(e=>i.createElement(d,{key:e.uuid,card:e,game:this.props.game})))))}}class p extends i.PureComponent{constructor(){super(...arguments),this.state={players:[],gems:{},cards:{},chat:[],decks:{},nobles:[],log:[],turn:-1,winner:null,mode:"normal",error:null,selectedPlayer:-1,phase:"pregame",showChat:!1,showLog:!1,chatNotify:!1},this.isMyTurn=e=>e==this.props.pid,this.updateState=e=>{if(e.state){if(this.isMyTurn(e.state.turn)?("waiting"==this.state.mode&&(f.badge("!"),document.getElementById("notify").play()),this.setState({mode:"normal"})):this.setState({mode:"waiting"}),-1==this.state.selectedPlayer&&this.props.pid<4&&this.setState({selectedPlayer:this.props.pid}),this.setState({log:e.state.log,cards:e.state.cards,decks:e.state.decks,players:e.state.players,gems:e.state.gems,nobles:e.state.nobles,turn:e.state.turn}),null!==e.state.winner&&"postgame"!=this.state.phase&&(alert(e.state.players[e.state.winner].name+" wins!"),this.setState({phase:"postgame"})),e.chat){var t=this.state.chat;if(t&&t[t.length-1]&&e.chat[e.chat.length-1]){var a=t[t.length-1],s=e.chat[e.chat.length-1];a.msg!=s.msg&&s.pid!=this.props.pid&&(f.badge("."),document.getElementById("notify").play(),this.state.showChat||this.setState({chatNotify:!0}))}this.setState({chat:e.chat})}for(const e of document.getElementsByClassName("scroller"))e.scrollTop=e.scrollHeight}},this.loginArgs=()=>"?pid="+this.props.pid+"&uuid="+this.props.uuid,this.take=e=>{this.act("take",e)},this.discard=e=>{confirm("Are you sure you want to discard a gem?")&&this.act("discard",e)},this.selectPlayer=e=>{this.setState({selectedPlayer:e})},this.buy=e=>{this.act("buy",e)},this.reserve=e=>{this.act("reserve",e)},this.noble=e=>{this.act("noble_visit",e)},this.rename=e=>s(this,void 0,void 0,(function*(){const t=yield fetch(`/rename/${this.props.gid}/${e}${this.loginArgs()}`,{method:"POST"}),a=yield t.json();r(a)})),this.act=(e,t)=>s(this,void 0,void 0,(function*(){const a=yield fetch("/game/"+this.props.gid+"/"+e+"/"+t+this.loginArgs(),{method:"POST"}),s=yield a.json();r(s)||this.updateState(s)})),this.nextTurn=()=>s(this,void 0,void 0,(function*(){const e=yield fetch("/game/"+this.props.gid+"/next"+this.loginArgs(),{method:"POST"}),t=yield e.json();r(t)||this.updateState(t)})),this.poll=()=>s(this,void 0,void 0,(function*(){const e=yield fetch("/poll/"+this.props.gid+this.loginArgs()),t=yield e.json();r(t)||(this.updateState(t),this.poll())})),this.stat=()=>s(this,void 0,void 0,(function*(){const e=yield fetch(`/stat/${this.props.gid}${this.loginArgs()}`),t=yield e.json();r(t)||this.updateState(t)})),this.chat=e=>s(this,void 0,void 0,(function*(){const t=document.getElementById("chat-inner");if(13==e.which){const e=yield fetch("/game/"+this.props.gid+"/chat"+this.loginArgs(),{method:"POST",body:JSON.stringify({msg:t.value})});t.value="";const a=yield e.json();r(a)||this.updateState(a)}}))}componentDidMount(){this.stat(),this.poll()}render(){var e=this.state.players.map(e=>i.createElement(h,{selectedPlayer:this.state.selectedPlayer,key:e.uuid,pid:e.id,name:e.name,points:e.score,game:this,cards:e.cards,nobles:e.nobles,gems:e.gems,reserved:e.reserved,nreserved:e.reserved.length})),t=l(this.state.gems,this,this.take,"","game"),a=c(this.state.nobles,this),s=this.state.log.map((e,t)=>i.createElement("div",{key:"log-line-"+t,className:"line"},i.createElement("span",{className:"pid"},"["+e.pid+"] "),i.createElement("span",{className:"msg"},e.msg))),n=this.state.chat.map((e,t)=>i.createElement("div",{key:"chat-line-"+t,className:"line"},i.createElement("span",{className:`name name${e.pid}`},e.name+": "),i.createElement("span",{className:"msg"},e.msg))),r=o.map(e=>i.createElement(u,{key:e,game:this,name:e,cards:this.state.cards[e],remaining:this.state.decks[e]}));return i.createElement("div",null,i.createElement("div",{id:"game-board"},i.createElement("div",{id:"common-area"},i.createElement("div",{id:"noble-area",className:"split"},a),i.createElement("div",{id:"level-area",className:"split"},r),i.createElement("div",{className:"reserve-info"},i.createElement("div",{className:"reserve-info-inner"},i.createElement("div",null,"Click on card to buy, click on "),i.createElement("div",null,i.createElement("img",{className:"floppy",src:"static/img/floppy.png"})),i.createElement("div",null," to reserve."))),i.createElement("div",{id:"gem-area",className:"you"},t)),i.createElement("div",{id:"player-area"},e)),i.createElement("div",{id:"log-box",style:{bottom:this.state.showLog?-4:-514}},i.createElement("div",{className:"title",onClick:()=>this.setState({showLog:!this.state.showLog})},"::Log"),i.createElement("div",{className:"scroller"},s)),i.createElement("div",{id:"chat-box",onClick:()=>this.setState({chatNotify:!1}),style:{bottom:this.state.showChat?-4:-314}},i.createElement("div",{className:`title${this.state.chatNotify?" blinking":""}`,onClick:()=>this.setState({showChat:!this.state.showChat})},"::Chat"),i.createElement("div",{className:"scroller"},n),i.createElement("div",{id:"chat"},i.createElement("span",{id:"prompt"},">"),i.createElement("input",{id:"chat-inner",type:"text",onKeyPress:this.chat}))),this.state.turn>=0&&this.props.pid>=0&&this.props.pid<4&&i.createElement("button",{id:"pass-turn",onClick:this.nextTurn,style:{opacity:this.isMyTurn(this.state.turn)?1:.3}},"Pass turn"))}}const g=e=>i.createElement("div",{className:"error-box",style:{opacity:e.opacity}},i.createElement("div",{className:"error-box-inner"},e.error));class y extends i.PureComponent{constructor(){super(...arguments),this.state={startKey:null,loading:!0,lobby:!1,joined:!1,pid:-1,uuid:"",gid:"",gameName:"",errorOpacity:0,error:null},this.creating=!1,this.join=(e,t)=>s(this,void 0,void 0,(function*(){const a=this.readSession();if(a[e]&&!a[e].loading&&(a[e].joined||"spectate"===t))return this.setState(a[e]),void this.save();const s=yield fetch(`/${t}/${e}`,{method:"POST"}),i=yield s.json();404!==i.status?this.showError(i)||(this.setState({joined:"join"===t,loading:!1,pid:i.id,uuid:i.uuid,gid:e}),this.save()):this.createGame()})),this.showError=t=>{let a=null;return t?!!(t.error||t.result&&t.result.error)&&(a=t.error||t.result.error,404===t.status?(this.clear(),this.setState({loading:!0,joined:!1,pid:-1,uuid:""}),this.join(this.state.gid,"spectate"),!0):(this.setState({error:a,errorOpacity:1}),clearTimeout(e),e=setTimeout(()=>{this.setState({errorOpacity:0})},4e3),!0)):(a="Request failed",!0)},this.createGame=()=>s(this,void 0,void 0,(function*(){if(this.creating)return;this.creating=!0;const e=""===this.state.gid?this.state.gameName:this.state.gid,t=yield fetch(`/create/${e}`,{method:"POST"}),a=yield t.json();this.showError(a)||(history.replaceState(null,"Splendor",`/${a.game}`),this.join(a.game,"join"),this.setState({startKey:a.start,loading:!0,lobby:!1}))})),this.readSession=()=>{var e=window.localStorage.getItem("splendor");return null===e?{}:JSON.parse(e)},this.save=()=>{setTimeout(()=>{this.saveRaw(this.state)},100)},this.clear=()=>{this.saveRaw(null)},this.saveRaw=e=>{const t=this.readSession();null===e?delete t[this.state.gid]:t[this.state.gid]=e,window.localStorage.splendor=JSON.stringify(t)},this.startGame=()=>s(this,void 0,void 0,(function*(){const e=yield fetch(`/start/${this.state.gid}/${this.state.startKey}`,{method:"POST"}),t=yield e.json();this.showError(t)||(this.setState({startKey:null}),this.save())})),this.nameChange=e=>{this.setState({gameName:e.target.value})},this.keyPress=e=>{"Enter"===e.key&&this.createGame()}}componentDidMount(){if(r=this.showError,"/"===window.location.pathname)return void fetch("/suggest").then(e=>s(this,void 0,void 0,(function*(){const t=yield e.json();this.setState({lobby:!0,gameName:t.result.game,loading:!1})})));const e=window.location.pathname.substring(1);var t=this.readSession();this.setState(Object.assign(Object.assign({},t[e]),{gid:e})),this.state.loading&&this.join(e,"spectate")}render(){return this.state.loading?i.createElement("div",{id:"game"},i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity})):this.state.lobby?i.createElement("div",{className:"lobby"},i.createElement("div",{className:"main-title"},"Splendor"),i.createElement("div",{className:"desc"},"Play Splendor online with others. Enter a game name or use the suggested game name to start a game."),i.createElement("div",{className:"name"},i.createElement("input",{className:"game-name",type:"text",onChange:this.nameChange,onKeyPress:this.keyPress,value:this.state.gameName}),i.createElement("button",{onClick:this.createGame,className:"create-game"},"Create Game")),i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity})):i.createElement("div",{id:"game"},i.createElement("div",{id:"game-title"},i.createElement("div",{className:"link"},"Share this link with friends to join in or watch: ",i.createElement("a",{href:"."},`${document.location.href}`)),i.createElement("div",{className:"buttons"},!this.state.joined&&i.createElement("button",{className:"start-game",onClick:()=>this.join(this.state.gid,"join")},"Join Game"),this.state.startKey&&0==this.state.pid&&i.createElement("button",{className:"start-game",onClick:this.startGame},"Start Game"))),this.state.pid>=0&&this.state.gid&&this.state.uuid&&i.createElement(p,{key:this.state.pid,gid:this.state.gid,pid:this.state.pid,uuid:this.state.uuid}),i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity}))}}const f=new Favico({position:"up"});document.onclick=()=>{f.badge("")},n.render(i.createElement("div",null,i.createElement(y,null)),document.getElementById("content"))}()},function(e,t){e.exports=React},function(e,t){e.exports=ReactDOM},function(e,t,a){var s;
+!function(e){var t={};function a(s){if(t[s])return t[s].exports;var i=t[s]={i:s,l:!1,exports:{}};return e[s].call(i.exports,i,i.exports,a),i.l=!0,i.exports}a.m=e,a.c=t,a.d=function(e,t,s){a.o(e,t)||Object.defineProperty(e,t,{enumerable:!0,get:s})},a.r=function(e){"undefined"!=typeof Symbol&&Symbol.toStringTag&&Object.defineProperty(e,Symbol.toStringTag,{value:"Module"}),Object.defineProperty(e,"__esModule",{value:!0})},a.t=function(e,t){if(1&t&&(e=a(e)),8&t)return e;if(4&t&&"object"==typeof e&&e&&e.__esModule)return e;var s=Object.create(null);if(a.r(s),Object.defineProperty(s,"default",{enumerable:!0,value:e}),2&t&&"string"!=typeof e)for(var i in e)a.d(s,i,function(t){return e[t]}.bind(null,i));return s},a.n=function(e){var t=e&&e.__esModule?function(){return e.default}:function(){return e};return a.d(t,"a",t),t},a.o=function(e,t){return Object.prototype.hasOwnProperty.call(e,t)},a.p="",a(a.s=0)}([function(e,t,a){"use strict";var s=this&&this.__awaiter||function(e,t,a,s){return new(a||(a=Promise))((function(i,n){function r(e){try{l(s.next(e))}catch(e){n(e)}}function o(e){try{l(s.throw(e))}catch(e){n(e)}}function l(e){var t;e.done?i(e.value):(t=e.value,t instanceof a?t:new a((function(e){e(t)}))).then(r,o)}l((s=s.apply(e,t||[])).next())}))};Object.defineProperty(t,"__esModule",{value:!0});const i=a(1),n=a(2);a(3);let r=e=>!1;!function(){let e=0;const t=["b","u","w","g","r"],a=t.concat(["*"]),o=["level3","level2","level1"],ab={double:"×2",join:"join",reserve_noble:"noble",free_card:"free",sacrifice:"sacrifice"},ap={join:"Click a gem color to join your new card to",reserve_noble:"Click a noble to reserve it for yourself",free_card:"Click a card one level lower to take it for free",post_gem:"Click a gem color to take it from your outpost"},pn={post_gem:"+1 gem",post_reserve:"+1 reserve",post_points:"5 points",post_per_post:"1 point / post"};function l(e,t,s,n,r){return a.map(a=>{var o=a+"chip";return"*"===a&&(o="schip"),i.createElement("div",{className:"gem "+o,key:a+"_colors_"+r},i.createElement("div",{className:"bubble"},e[a]),i.createElement("div",{className:"underlay",onClick:s.bind(t,a)},n))})}function c(e,t){return e.map(e=>i.createElement(m,{key:e.uuid,noble:e,game:t}))}function cc(e){return e.map(e=>i.createElement(mc,{key:e.uuid,city:e}))}class d extends i.PureComponent{render(){const e=this.props.card,a=this.props.game;var s=a.buy.bind(a,e.uuid);const n=t=>{t.preventDefault(),a.reserve.bind(a)(e.uuid)},gd=this.props.guards||[];return e.color?i.createElement("div",{className:"card card-"+("*"===e.color?"join":e.color)+" card-"+e.level+(e.orient?" card-orient":""),id:e.uuid},i.createElement("div",{className:"reserve",onClick:n},i.createElement("img",{className:"floppy",src:"static/img/floppy.png"})),this.props.fortify&&i.createElement("div",{className:"fortify",title:"Place a stronghold",onClick:a.stronghold.bind(a,e.uuid)},"♜"),gd.length>0&&i.createElement("div",{className:"strongholds"},gd.map((t,s)=>i.createElement("div",{key:e.uuid+"_guard_"+s,className:"stronghold name"+t,title:"Raze this stronghold",onClick:a.raze.bind(a,e.uuid)},"♜"))),i.createElement("div",{className:"overlay",onClick:s}),i.createElement("div",{className:"underlay"},i.createElement("div",{className:"header"},i.createElement("div",{className:"color "+e.color+"gem"}),i.createElement("div",{className:"points"},e.points>0&&e.points),e.ability&&i.createElement("div",{className:"ability"},ab[e.ability])),i.createElement("div",{className:"costs"},t.map(t=>{if(e.cost[t]>0)return i.createElement("div",{key:e.uuid+"_cost_"+t,className:"cost "+t},e.cost[t])})))):i.createElement("div",{className:"deck "+e.level})}}class mc extends i.PureComponent{render(){const e=this.props.city;return i.createElement("div",{className:"noble city"},i.createElement("div",{className:"side-bar"},i.createElement("div",{className:"points"},e.points),i.createElement("div",{className:"requirement"},t.map(t=>{if(e.requirement[t]>0)return i.createElement("div",{key:e.uuid+"_req_"+t,className:"requires "+t},e.requirement[t])}))))}}class tp extends i.PureComponent{render(){const e=this.props.post,a=this.props.game.state.players;return i.createElement("div",{className:"noble post"},i.createElement("div",{className:"side-bar"},i.createElement("div",{className:"points"},pn[e.ability]),i.createElement("div",{className:"requirement"},t.map(t=>{if(e.requirement[t]>0)return i.createElement("div",{key:"post"+e.id+"_req_"+t,className:"requires "+t},e.requirement[t])}))),i.createElement("div",{className:"owners"},e.owners.map(t=>i.createElement("div",{key:"post"+e.id+"_owner_"+t,className:"owner name"+t},a[t]&&a[t].name))))}}class m extends i.PureComponent{render(){const e=this.props.noble,a=this.props.game,s=a.noble.bind(a,e.uuid);return i.createElement("div",{className:"noble",onClick:s,id:"noble"+e.id},i.createElement("div",{className:"side-bar"},i.createElement("div",{className:"points"},e.points>0&&e.points),i.createElement("div",{className:"requirement"},t.map(t=>{if(e.requirement[t]>0)return i.createElement("div",{key:e.uuid+"_req_"+t,className:"requires "+t},e.requirement[t])}))))}}class h extends i.PureComponent{constructor(){super(...arguments),this.state={editingName:null},this.editName=e=>{this.setState({editingName:e.target.value})},this.focusName=e=>{e.target.select()},this.submitName=()=>{this.props.game.rename(this.state.editingName),this.setState({editingName:null})},this.keypress=e=>{"Enter"===e.key&&this.submitName()}}render(){const e=this.props.game,s=this.props.pid,n=e.selectPlayer.bind(e,s),r={};a.map(e=>{r[e]={cards:0,gems:this.props.gems[e]}});const o=t.map(t=>{var a=this.props.cards[t].map(a=>(r[t].cards+="double"===a.ability?2:1,i.createElement("div",{key:s+"_card_"+a.uuid,className:"colorSetInner"},i.createElement(d,{key:a.uuid,card:a,game:e}))));return i.createElement("div",{key:s+"_set_"+t,className:"colorSet"},a,i.createElement("div",{className:a.length>0?"endcap":"spacer"}))}),m=a.map(e=>i.createElement("div",{className:"statSet",key:"stat"+e},i.createElement("div",{className:`stat stat${"*"===e?"y":e}`},r[e].gems+("*"==e?"":" / "+r[e].cards)),"*"===e?i.createElement(i.Fragment,null):i.createElement("div",null,i.createElement("img",{className:"labelImg",src:"static/img/labels.png"})))),h=e.props.pid===s?" you selected":"",u=e.props.pid===s?" (you)":"",p=l(this.props.gems,e,e.discard,"X",s),g=this.props.reserved?this.props.reserved.map(t=>i.createElement(d,{key:t.uuid+"_inner",card:t,game:e})):[],y=this.props.reserved?g.length:this.props.nreserved,f=c(this.props.nobles,e),rn=c(this.props.reservedNobles,e);return i.createElement("div",{className:"player"+h},i.createElement("div",{className:"playerHeader"},i.createElement("div",{className:"playerPoints"},this.props.points),null==this.state.editingName?i.createElement(i.Fragment,null,i.createElement("div",{className:"playerName",onClick:n},this.props.name),e.props.pid===s&&null==this.state.editingName?i.createElement("div",{className:"pencil",onClick:()=>this.setState({editingName:this.props.name})},"✏️"):i.createElement(i.Fragment,null)):i.createElement("div",{className:"playerName"},i.createElement("input",{className:"nameInput",type:"text",value:this.state.editingName,autoFocus:!0,onKeyPress:this.keypress,onFocus:this.focusName,onBlur:this.submitName,onChange:this.editName})),i.createElement("div",{className:"playerName2"},u),this.props.strongholds>0&&i.createElement("div",{className:"strongholdCount",title:"Strongholds left"},"♜",this.props.strongholds),e.state.turn===s&&i.createElement("div",{className:"turnIndicator"},"←")),e.state.selectedPlayer===s?i.createElement("div",{className:"floater"},i.createElement("div",{className:"cards"},o),i.createElement("div",{className:"nobles"},f,this.props.city&&i.createElement(mc,{city:this.props.city}),rn.length>0&&i.createElement("div",{className:"reservedNobles"},rn),this.props.posts.length>0&&i.createElement("div",{className:"playerPosts"},this.props.posts.map(e=>i.createElement("div",{key:s+"_post_"+e,className:"playerPost"},"post "+e)))),i.createElement("div",{className:"gems"},p),i.createElement("div",{className:"reserveArea"},y>0&&i.createElement("div",null,i.createElement("div",{className:"reserveText"},"reserved"),i.createElement("div",{className:"reserveCards"},g)))):i.createElement("div",{className:"stats"},i.createElement("div",{className:"gem-stats"},m),i.createElement("div",{className:"reservedStat"},g)))}}class u extends i.PureComponent{render(){return i.createElement("div",null,i.createElement("div",{className:"deck "+this.props.name+(this.props.orient?" orient":"")},i.createElement("div",{className:"remaining"},this.props.remaining),i.createElement("div",{className:"overlay"}),!this.props.orient&&i.createElement("div",{className:"reserve",onClick:this.props.game.reserve.bind(this.props.game,this.props.name)},i.createElement("img",{className:"floppy",src:"static/img/floppy.png"}))),i.createElement("div",{className:"c_"+this.props.name+" face-up-cards"},i.createElement("div",{className:"cards-inner"},this.props.cards&&this.props.cards.map(e=>i.createElement(d,{key:e.uuid,card:e,game:this.props.game,guards:this.props.strongholds[e.uuid],fortify:this.props.fortify})))))}}class p extends i.PureComponent{constructor(){super(...arguments),this.state={players:[],gems:{},cards:{},chat:[],decks:{},orient_cards:{},orient_decks:{},nobles:[],cities:[],posts:[],strongholds:{},rules:{},log:[],turn:-1,winner:null,mode:"normal",error:null,selectedPlayer:-1,phase:"pregame",showChat:!1,showLog:!1,chatNotify:!1},this.isMyTurn=e=>e==this.props.pid,this.updateState=e=>{if(e.state){if(this.isMyTurn(e.state.turn)?("waiting"==this.state.mode&&(f.badge("!"),document.getElementById("notify").play()),this.setState({mode:"normal"})):this.setState({mode:"waiting"}),-1==this.state.selectedPlayer&&this.props.pid<4&&this.setState({selectedPlayer:this.props.pid}),this.setState({log:e.state.log,cards:e.state.cards,decks:e.state.decks,orient_cards:e.state.orient_cards||{},orient_decks:e.state.orient_decks||{},players:e.state.players,gems:e.state.gems,nobles:e.state.nobles,cities:e.state.cities||[],posts:e.state.posts||[],strongholds:e.state.strongholds||{},rules:e.state.rules||{},turn:e.state.turn}),e.state.standings&&e.state.standings.length>0&&"postgame"!=this.state.phase&&(function(){var t=e.state.standings.filter(function(t){return 1==t.rank}).map(function(t){return e.state.players[t.pid].name});alert(t.length>1?t.join(" and ")+" tie!":t[0]+" wins!")}(),this.setState({phase:"postgame"})),e.chat){var t=this.state.chat;if(t&&t[t.length-1]&&e.chat[e.chat.length-1]){var a=t[t.length-1],s=e.chat[e.chat.length-1];a.msg!=s.msg&&s.pid!=this.props.pid&&(f.badge("."),document.getElementById("notify").play(),this.state.showChat||this.setState({chatNotify:!0}))}this.setState({chat:e.chat})}for(const e of document.getElementsByClassName("scroller"))e.scrollTop=e.scrollHeight}},this.loginArgs=()=>"?pid="+this.props.pid+"&uuid="+this.props.uuid,this.pending=()=>{const e=this.state.players[this.props.pid];return e&&e.pending?e.pending.ability:null},this.take=e=>{const t=this.pending();this.act("join"===t?"choose":"post_gem"===t?"post_gem":"take",e)},this.discard=e=>{confirm("Are you sure you want to discard a gem?")&&this.act("discard",e)},this.selectPlayer=e=>{this.setState({selectedPlayer:e})},this.buy=e=>{this.act("free_card"===this.pending()?"choose":"buy",e)},this.reserve=e=>{this.act("reserve",e)},this.noble=e=>{this.act("reserve_noble"===this.pending()?"choose":"noble_visit",e)},this.stronghold=e=>{this.act("stronghold",e)},this.raze=e=>{confirm("Are you sure you want to raze this stronghold?")&&this.act("raze",e)},this.rename=e=>s(this,void 0,void 0,(function*(){const t=yield fetch(`/rename/${this.props.gid}/${e}${this.loginArgs()}`,{method:"POST"}),a=yield t.json();r(a)})),this.act=(e,t)=>s(this,void 0,void 0,(function*(){const a=yield fetch("/game/"+this.props.gid+"/"+e+"/"+t+this.loginArgs(),{method:"POST"}),s=yield a.json();r(s)||this.updateState(s)})),this.nextTurn=()=>s(this,void 0,void 0,(function*(){const e=yield fetch("/game/"+this.props.gid+"/next"+this.loginArgs(),{method:"POST"}),t=yield e.json();r(t)||this.updateState(t)})),this.poll=()=>s(this,void 0,void 0,(function*(){const e=yield fetch("/poll/"+this.props.gid+this.loginArgs()),t=yield e.json();r(t)||(this.updateState(t),this.poll())})),this.stat=()=>s(this,void 0,void 0,(function*(){const e=yield fetch(`/stat/${this.props.gid}${this.loginArgs()}`),t=yield e.json();r(t)||this.updateState(t)})),this.chat=e=>s(this,void 0,void 0,(function*(){const t=document.getElementById("chat-inner");if(13==e.which){const e=yield fetch("/game/"+this.props.gid+"/chat"+this.loginArgs(),{method:"POST",body:JSON.stringify({msg:t.value})});t.value="";const a=yield e.json();r(a)||this.updateState(a)}}))}componentDidMount(){this.stat(),this.poll()}render(){var e=this.state.players.map(e=>i.createElement(h,{selectedPlayer:this.state.selectedPlayer,key:e.uuid,pid:e.id,name:e.name,points:e.score,game:this,cards:e.cards,nobles:e.nobles,reservedNobles:e.reserved_nobles||[],posts:e.posts||[],strongholds:e.strongholds||0,city:e.city,gems:e.gems,reserved:e.reserved,nreserved:e.reserved.length})),t=l(this.state.gems,this,this.take,"","game"),a=c(this.state.nobles,this),ac=cc(this.state.cities),po=(this.state.posts||[]).map(e=>i.createElement(tp,{key:"post"+e.id,post:e,game:this})),me=this.state.players[this.props.pid],fo=!!(this.state.rules&&this.state.rules.strongholds&&me&&me.strongholds>0&&this.isMyTurn(this.state.turn)),s=this.state.log.map((e,t)=>i.createElement("div",{key:"log-line-"+t,className:"line"},i.createElement("span",{className:"pid"},"["+e.pid+"] "),i.createElement("span",{className:"msg"},e.msg))),n=this.state.chat.map((e,t)=>i.createElement("div",{key:"chat-line-"+t,className:"line"},i.createElement("span",{className:`name name${e.pid}`},e.name+": "),i.createElement("span",{className:"msg"},e.msg))),r=o.map(e=>i.createElement(u,{key:e,game:this,name:e,cards:this.state.cards[e],remaining:this.state.decks[e],strongholds:this.state.strongholds,fortify:fo})),ol=o.filter(e=>this.state.orient_cards[e]).map(e=>i.createElement(u,{key:"orient_"+e,game:this,name:e,orient:!0,cards:this.state.orient_cards[e],remaining:this.state.orient_decks[e],strongholds:this.state.strongholds,fortify:fo})),pa=this.isMyTurn(this.state.turn)?this.pending():null;return i.createElement("div",null,i.createElement("div",{id:"game-board"},i.createElement("div",{id:"common-area"},i.createElement("div",{id:"noble-area",className:"split"},a,ac,po),i.createElement("div",{id:"level-area",className:"split"},r),ol.length>0&&i.createElement("div",{id:"orient-area",className:"split"},ol),i.createElement("div",{className:"reserve-info"},pa?i.createElement("div",{className:"reserve-info-inner prompt"},ap[pa]):i.createElement("div",{className:"reserve-info-inner"},i.createElement("div",null,"Click on card to buy, click on "),i.createElement("div",null,i.createElement("img",{className:"floppy",src:"static/img/floppy.png"})),i.createElement("div",null," to reserve."))),i.createElement("div",{id:"gem-area",className:"you"},t)),i.createElement("div",{id:"player-area"},e)),i.createElement("div",{id:"log-box",style:{bottom:this.state.showLog?-4:-514}},i.createElement("div",{className:"title",onClick:()=>this.setState({showLog:!this.state.showLog})},"::Log"),i.createElement("div",{className:"scroller"},s)),i.createElement("div",{id:"chat-box",onClick:()=>this.setState({chatNotify:!1}),style:{bottom:this.state.showChat?-4:-314}},i.createElement("div",{className:`title${this.state.chatNotify?" blinking":""}`,onClick:()=>this.setState({showChat:!this.state.showChat})},"::Chat"),i.createElement("div",{className:"scroller"},n),i.createElement("div",{id:"chat"},i.createElement("span",{id:"prompt"},">"),i.createElement("input",{id:"chat-inner",type:"text",onKeyPress:this.chat}))),this.state.turn>=0&&this.props.pid>=0&&this.props.pid<4&&i.createElement("button",{id:"pass-turn",onClick:this.nextTurn,style:{opacity:this.isMyTurn(this.state.turn)?1:.3}},"Pass turn"))}}const g=e=>i.createElement("div",{className:"error-box",style:{opacity:e.opacity}},i.createElement("div",{className:"error-box-inner"},e.error));class y extends i.PureComponent{constructor(){super(...arguments),this.state={startKey:null,loading:!0,lobby:!1,cities:!1,orient:!1,outposts:!1,strongholds:!1,joined:!1,pid:-1,uuid:"",gid:"",gameName:"",errorOpacity:0,error:null},this.creating=!1,this.join=(e,t)=>s(this,void 0,void 0,(function*(){const a=this.readSession();if(a[e]&&!a[e].loading&&(a[e].joined||"spectate"===t))return this.setState(a[e]),void this.save();const s=yield fetch(`/${t}/${e}`,{method:"POST"}),i=yield s.json();404!==i.status?this.showError(i)||(this.setState({joined:"join"===t,loading:!1,pid:i.id,uuid:i.uuid,gid:e}),this.save()):this.createGame()})),this.showError=t=>{let a=null;return t?!!(t.error||t.result&&t.result.error)&&(a=t.error||t.result.error,404===t.status?(this.clear(),this.setState({loading:!0,joined:!1,pid:-1,uuid:""}),this.join(this.state.gid,"spectate"),!0):(this.setState({error:a,errorOpacity:1}),clearTimeout(e),e=setTimeout(()=>{this.setState({errorOpacity:0})},4e3),!0)):(a="Request failed",!0)},this.createGame=()=>s(this,void 0,void 0,(function*(){if(this.creating)return;this.creating=!0;const e=""===this.state.gid?this.state.gameName:this.state.gid,n=[this.state.cities&&"cities=true",this.state.orient&&"orient=true",this.state.outposts&&"outposts=true",this.state.strongholds&&"strongholds=true"].filter(e=>e).join("&"),t=yield fetch(`/create/${e}${n?"?"+n:""}`,{method:"POST"}),a=yield t.json();this.showError(a)||(history.replaceState(null,"Splendor",`/${a.game}`),this.join(a.game,"join"),this.setState({startKey:a.start,loading:!0,lobby:!1}))})),this.readSession=()=>{var e=window.localStorage.getItem("splendor");return null===e?{}:JSON.parse(e)},this.save=()=>{setTimeout(()=>{this.saveRaw(this.state)},100)},this.clear=()=>{this.saveRaw(null)},this.saveRaw=e=>{const t=this.readSession();null===e?delete t[this.state.gid]:t[this.state.gid]=e,window.localStorage.splendor=JSON.stringify(t)},this.startGame=()=>s(this,void 0,void 0,(function*(){const e=yield fetch(`/start/${this.state.gid}/${this.state.startKey}`,{method:"POST"}),t=yield e.json();this.showError(t)||(this.setState({startKey:null}),this.save())})),this.nameChange=e=>{this.setState({gameName:e.target.value})},this.keyPress=e=>{"Enter"===e.key&&this.createGame()}}componentDidMount(){if(r=this.showError,"/"===window.location.pathname)return void fetch("/suggest").then(e=>s(this,void 0,void 0,(function*(){const t=yield e.json();this.setState({lobby:!0,gameName:t.result.game,loading:!1})})));const e=window.location.pathname.substring(1);var t=this.readSession();this.setState(Object.assign(Object.assign({},t[e]),{gid:e})),this.state.loading&&this.join(e,"spectate")}render(){return this.state.loading?i.createElement("div",{id:"game"},i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity})):this.state.lobby?i.createElement("div",{className:"lobby"},i.createElement("div",{className:"main-title"},"Splendor"),i.createElement("div",{className:"desc"},"Play Splendor online with others. Enter a game name or use the suggested game name to start a game."),i.createElement("div",{className:"name"},i.createElement("input",{className:"game-name",type:"text",onChange:this.nameChange,onKeyPress:this.keyPress,value:this.state.gameName}),i.createElement("button",{onClick:this.createGame,className:"create-game"},"Create Game")),i.createElement("label",{className:"option"},i.createElement("input",{type:"checkbox",checked:this.state.cities,onChange:e=>this.setState({cities:e.target.checked})}),"Cities of Splendor: claim a city to end the game"),i.createElement("label",{className:"option"},i.createElement("input",{type:"checkbox",checked:this.state.orient,onChange:e=>this.setState({orient:e.target.checked})}),"Orient: extra cards with special abilities"),i.createElement("label",{className:"option"},i.createElement("input",{type:"checkbox",checked:this.state.outposts,onChange:e=>this.setState({outposts:e.target.checked})}),"Outposts (house rule, not the Trading Posts module): unlock abilities by collecting cards"),i.createElement("label",{className:"option"},i.createElement("input",{type:"checkbox",checked:this.state.strongholds,onChange:e=>this.setState({strongholds:e.target.checked})}),"Strongholds (simplified rules): guard table cards from your opponents, three strongholds claim a card"),i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity})):i.createElement("div",{id:"game"},i.createElement("div",{id:"game-title"},i.createElement("div",{className:"link"},"Share this link with friends to join in or watch: ",i.createElement("a",{href:"."},`${document.location.href}`)),i.createElement("div",{className:"buttons"},!this.state.joined&&i.createElement("button",{className:"start-game",onClick:()=>this.join(this.state.gid,"join")},"Join Game"),this.state.startKey&&0==this.state.pid&&i.createElement("button",{className:"start-game",onClick:this.startGame},"Start Game"))),this.state.pid>=0&&this.state.gid&&this.state.uuid&&i.createElement(p,{key:this.state.pid,gid:this.state.gid,pid:this.state.pid,uuid:this.state.uuid}),i.createElement(g,{error:this.state.error,opacity:this.state.errorOpacity}))}}const f=new Favico({position:"up"});document.onclick=()=>{f.badge("")},n.render(i.createElement("div",null,i.createElement(y,null)),document.getElementById("content"))}()},function(e,t){e.exports=React},function(e,t){e.exports=ReactDOM},function(e,t,a){var s;
 /**
  * @license MIT
  * @fileOverview Favico animations
@@ -23,6 +23,8 @@ interface CardT {
   uuid: string
   cost: CostT
   level: string
+  orient?: boolean
+  ability?: string
 }
 
 interface CardsT {
@@ -40,12 +42,37 @@ interface NobleT {
   requirement: CostT
 }
 
+interface CityT {
+  id: string
+  points: number
+  uuid: string
+  requirement: CostT
+}
+
+interface OutpostT {
+  id: number
+  ability: string
+  requirement: CostT
+  owners: number[]
+}
+
+interface PendingT {
+  card: CardT | null
+  ability: string
+  options: string[]
+}
+
 interface PlayerT {
   id: number
   name: string
   uuid: string
   reserved: CardT[]
   nobles: NobleT[]
+  reserved_nobles?: NobleT[]
+  pending?: PendingT | null
+  posts?: number[]
+  strongholds?: number
+  city: CityT | null
   cards: CardsT
   gems: GemsT
   score: number
@@ -57,14 +84,29 @@ interface LogT {
   msg: string
 }
 
+interface StandingT {
+  pid: number
+  rank: number
+  points: number
+  cards: number
+  city?: boolean
+}
+
 interface GameT {
   players: PlayerT[]
   cards: { [level: string]: CardT[] }
   decks: { [level: string]: number }
+  orient_cards?: { [level: string]: CardT[] }
+  orient_decks?: { [level: string]: number }
   log: LogT[]
   gems: GemsT
   nobles: NobleT[]
+  cities: CityT[]
+  posts?: OutpostT[]
+  strongholds?: { [uuid: string]: number[] }
+  rules?: { strongholds?: boolean }
   winner: number | null
+  standings: StandingT[]
   turn: number
 }
 
@@ -102,6 +144,25 @@ let globalShowError = (resp: ServerResponse) => { return false }
   const colors = ['b', 'u', 'w', 'g', 'r']
   const gemColors = colors.concat(['*'])
   const levelNames = ['level1', 'level2', 'level3']
+  const abilityNames: { [ability: string]: string } = {
+    double: '×2',
+    join: 'join',
+    reserve_noble: 'noble',
+    free_card: 'free',
+    sacrifice: 'sacrifice',
+  }
+  const abilityPrompts: { [ability: string]: string } = {
+    join: 'Click a gem color to join your new card to',
+    reserve_noble: 'Click a noble to reserve it for yourself',
+    free_card: 'Click a card one level lower to take it for free',
+    post_gem: 'Click a gem color to take it from your outpost',
+  }
+  const postNames: { [ability: string]: string } = {
+    post_gem: '+1 gem',
+    post_reserve: '+1 reserve',
+    post_points: '5 points',
+    post_per_post: '1 point / post',
+  }
 
   function mapColors(gems: GemsT, game: Game, callback: (color: GemT) => void, symbol: string, uuid: string | number) {
     return gemColors.map((color: GemT) => {
@@ -124,7 +185,15 @@ let globalShowError = (resp: ServerResponse) => { return false }
     });
   }
 
-  class Card extends React.PureComponent<{ card: CardT, game: Game }, {}> {
+  function mapCities(cities: CityT[]) {
+    return cities.map((city) => {
+      return (
+        <City key={city.uuid} city={city}/>
+      );
+    });
+  }
+
+  class Card extends React.PureComponent<{ card: CardT, game: Game, guards?: number[], fortify?: boolean }, {}> {
     render() {
       const card = this.props.card
       const game = this.props.game
@@ -133,16 +202,27 @@ let globalShowError = (resp: ServerResponse) => { return false }
         e.preventDefault();
         game.reserve.bind(game)(card.uuid)
       }
+      const guards = this.props.guards || []
 
       if (card.color) {
         return (
           <div
-            className={"card card-" + card.color + " card-" + card.level}
+            className={"card card-" + (card.color === '*' ? 'join' : card.color) + " card-" + card.level + (card.orient ? " card-orient" : "")}
             id={card.uuid}
           >
             <div className="reserve" onClick={reserver}>
               <img className="floppy" src="client/img/floppy.png" />
             </div>
+            {this.props.fortify &&
+              <div className="fortify" title="Place a stronghold" onClick={game.stronghold.bind(game, card.uuid)}>&#9820;</div>
+            }
+            {guards.length > 0 &&
+              <div className="strongholds">
+                {guards.map((pid, i) =>
+                  <div key={card.uuid + "_guard_" + i} className={"stronghold name" + pid} title="Raze this stronghold" onClick={game.raze.bind(game, card.uuid)}>&#9820;</div>
+                )}
+              </div>
+            }
             <div className="overlay" onClick={buyer}></div>
             <div className="underlay">
               <div className="header">
@@ -151,6 +231,9 @@ let globalShowError = (resp: ServerResponse) => { return false }
                 <div className="points">
                   {card.points > 0 && card.points}
                 </div>
+                {card.ability &&
+                  <div className="ability">{abilityNames[card.ability]}</div>
+                }
               </div>
               <div className="costs">
                 {colors.map((color: ColorT) => {
@@ -209,6 +292,72 @@ let globalShowError = (resp: ServerResponse) => { return false }
     }
   }
 
+  class City extends React.PureComponent<{ city: CityT }, {}> {
+    render() {
+      const city = this.props.city
+
+      return (
+        <div className="noble city">
+          <div className="side-bar">
+            <div className="points">
+              {city.points}
+            </div>
+            <div className="requirement">
+              {colors.map((color: ColorT) => {
+                if(city.requirement[color] > 0) {
+                  return (
+                    <div
+                      key={city.uuid + "_req_" + color}
+                      className={"requires " + color}
+                    >
+                      {city.requirement[color]}
+                    </div>
+                  )
+                }
+              })}
+            </div>
+          </div>
+        </div>
+      );
+    }
+  }
+
+  class Outpost extends React.PureComponent<{ post: OutpostT, game: Game }, {}> {
+    render() {
+      const post = this.props.post
+      const players = this.props.game.state.players
+
+      return (
+        <div className="noble post">
+          <div className="side-bar">
+            <div className="points">
+              {postNames[post.ability]}
+            </div>
+            <div className="requirement">
+              {colors.map((color: ColorT) => {
+                if(post.requirement[color] > 0) {
+                  return (
+                    <div
+                      key={"post" + post.id + "_req_" + color}
+                      className={"requires " + color}
+                    >
+                      {post.requirement[color]}
+                    </div>
+                  )
+                }
+              })}
+            </div>
+          </div>
+          <div className="owners">
+            {post.owners.map((pid) =>
+              <div key={"post" + post.id + "_owner_" + pid} className={"owner name" + pid}>{players[pid] && players[pid].name}</div>
+            )}
+          </div>
+        </div>
+      );
+    }
+  }
+
   interface PlayerProps {
     game: Game
     pid: number
@@ -217,6 +366,10 @@ let globalShowError = (resp: ServerResponse) => { return false }
     name: string
     points: number
     nobles: NobleT[]
+    reservedNobles: NobleT[]
+    posts: number[]
+    strongholds: number
+    city: CityT | null
     reserved: CardT[]
     nreserved: number
     selectedPlayer: number
@@ -256,7 +409,7 @@ let globalShowError = (resp: ServerResponse) => { return false }
 
       const set = colors.map((color: ColorT) => {
         var cards = this.props.cards[color].map((card: CardT) => {
-          collection[color]['cards'] += 1;
+          collection[color]['cards'] += card.ability === 'double' ? 2 : 1;
           return (
             <div
               key={pid + "_card_" + card.uuid}
@@ -293,6 +446,7 @@ let globalShowError = (resp: ServerResponse) => { return false }
       }) : []
       const reservedCount = this.props.reserved ? reserved.length : this.props.nreserved
       const nobles = mapNobles(this.props.nobles, game)
+      const reservedNobles = mapNobles(this.props.reservedNobles, game)
 
       return (
         <div className={"player" + you}>
@@ -310,6 +464,9 @@ let globalShowError = (resp: ServerResponse) => { return false }
               </div>
             }
             <div className="playerName2">{youName}</div>
+            {this.props.strongholds > 0 &&
+              <div className="strongholdCount" title="Strongholds left">&#9820;{this.props.strongholds}</div>
+            }
             {game.state.turn === pid &&
               <div className="turnIndicator">&#8592;</div>
             }
@@ -321,6 +478,17 @@ let globalShowError = (resp: ServerResponse) => { return false }
               </div>
               <div className="nobles">
                 {nobles}
+                {this.props.city && <City city={this.props.city}/>}
+                {reservedNobles.length > 0 &&
+                  <div className="reservedNobles">{reservedNobles}</div>
+                }
+                {this.props.posts.length > 0 &&
+                  <div className="playerPosts">
+                    {this.props.posts.map((id) =>
+                      <div key={pid + "_post_" + id} className="playerPost">{"post " + id}</div>
+                    )}
+                  </div>
+                }
               </div>
               <div className="gems">
                 {gems}
@@ -350,23 +518,25 @@ let globalShowError = (resp: ServerResponse) => { return false }
     }
   }
 
-  class Level extends React.PureComponent<{ name: string, remaining: number, game: Game, cards: CardT[] }, {}> {
+  class Level extends React.PureComponent<{ name: string, remaining: number, game: Game, cards: CardT[], orient?: boolean, strongholds: { [uuid: string]: number[] }, fortify: boolean }, {}> {
     render() {
       return (
         <div>
-          <div className={"deck " + this.props.name}>
+          <div className={"deck " + this.props.name + (this.props.orient ? " orient" : "")}>
             <div className="remaining">
               {this.props.remaining}
             </div>
             <div className="overlay"></div>
-            <div className="reserve" onClick={this.props.game.reserve.bind(this.props.game, this.props.name)}>
-              <img className="floppy" src="client/img/floppy.png" />
-            </div>
+            {!this.props.orient &&
+              <div className="reserve" onClick={this.props.game.reserve.bind(this.props.game, this.props.name)}>
+                <img className="floppy" src="client/img/floppy.png" />
+              </div>
+            }
           </div>
           <div className={"c_" + this.props.name + " face-up-cards"}>
             <div className="cards-inner">
               {this.props.cards && this.props.cards.map((card) =>
-                <Card key={card.uuid} card={card} game={this.props.game}/>
+                <Card key={card.uuid} card={card} game={this.props.game} guards={this.props.strongholds[card.uuid]} fortify={this.props.fortify}/>
               )}
             </div>
           </div>
@@ -382,10 +552,17 @@ let globalShowError = (resp: ServerResponse) => { return false }
       cards: {},
       chat: [],
       decks: {},
+      orient_cards: {},
+      orient_decks: {},
       nobles: [],
+      cities: [],
+      posts: [],
+      strongholds: {},
+      rules: {},
       log: [],
       turn: -1,
       winner: null,
+      standings: [],
       mode: "normal",
       error: null,
       selectedPlayer: -1,
@@ -419,14 +596,21 @@ let globalShowError = (resp: ServerResponse) => { return false }
           log: r.state.log,
           cards: r.state.cards,
           decks: r.state.decks,
+          orient_cards: r.state.orient_cards || {},
+          orient_decks: r.state.orient_decks || {},
           players: r.state.players,
           gems: r.state.gems,
           nobles: r.state.nobles,
+          cities: r.state.cities || [],
+          posts: r.state.posts || [],
+          strongholds: r.state.strongholds || {},
+          rules: r.state.rules || {},
           turn: r.state.turn,
         });
 
-        if (r.state.winner !== null && this.state.phase != "postgame") {
-          alert(r.state.players[r.state.winner].name + " wins!");
+        if (r.state.standings && r.state.standings.length > 0 && this.state.phase != "postgame") {
+          var winners = r.state.standings.filter(s => s.rank == 1).map(s => r.state.players[s.pid].name);
+          alert(winners.length > 1 ? winners.join(" and ") + " tie!" : winners[0] + " wins!");
           this.setState({phase: "postgame"});
         }
 
@@ -454,8 +638,14 @@ let globalShowError = (resp: ServerResponse) => { return false }
       return '?pid=' + this.props.pid + '&uuid=' + this.props.uuid
     }
 
+    pending = () => {
+      const me = this.state.players[this.props.pid]
+      return me && me.pending ? me.pending.ability : null
+    }
+
     take = (color: string) => {
-      this.act('take', color)
+      const pending = this.pending()
+      this.act(pending === 'join' ? 'choose' : pending === 'post_gem' ? 'post_gem' : 'take', color)
     }
 
     discard = (color: string) => {
@@ -469,7 +659,7 @@ let globalShowError = (resp: ServerResponse) => { return false }
     }
 
     buy = (uuid: string) => {
-      this.act('buy', uuid)
+      this.act(this.pending() === 'free_card' ? 'choose' : 'buy', uuid)
     }
 
     reserve = (uuid: string) => {
@@ -477,7 +667,17 @@ let globalShowError = (resp: ServerResponse) => { return false }
     }
 
     noble = (uuid: string) => {
-      this.act('noble_visit', uuid);
+      this.act(this.pending() === 'reserve_noble' ? 'choose' : 'noble_visit', uuid);
+    }
+
+    stronghold = (uuid: string) => {
+      this.act('stronghold', uuid)
+    }
+
+    raze = (uuid: string) => {
+      if (confirm("Are you sure you want to raze this stronghold?")) {
+        this.act('raze', uuid)
+      }
     }
 
     rename = async (name: string) => {
@@ -543,6 +743,10 @@ let globalShowError = (resp: ServerResponse) => { return false }
             game={this}
             cards={player.cards}
             nobles={player.nobles}
+            reservedNobles={player.reserved_nobles || []}
+            posts={player.posts || []}
+            strongholds={player.strongholds || 0}
+            city={player.city}
             gems={player.gems}
             reserved={player.reserved}
             nreserved={player.reserved.length}
@@ -551,6 +755,14 @@ let globalShowError = (resp: ServerResponse) => { return false }
       });
       var gems = mapColors(this.state.gems, this, this.take, '', 'game');
       var nobles = mapNobles(this.state.nobles, this);
+      var cities = mapCities(this.state.cities);
+      var posts = (this.state.posts || []).map((post) => {
+        return (
+          <Outpost key={"post" + post.id} post={post} game={this}/>
+        );
+      });
+      const me = this.state.players[this.props.pid]
+      const fortify = !!(this.state.rules && this.state.rules.strongholds && me && me.strongholds > 0 && this.isMyTurn(this.state.turn))
       var log = this.state.log.map((logLine, i) => {
         return (
           <div key={"log-line-" + i} className="line">
@@ -575,23 +787,50 @@ let globalShowError = (resp: ServerResponse) => { return false }
             name = {level}
             cards = {this.state.cards[level]}
             remaining = {this.state.decks[level]}
+            strongholds = {this.state.strongholds}
+            fortify = {fortify}
           />
         )
       });
+      var orientLevels = levelNames.filter((level) => this.state.orient_cards[level]).map((level) => {
+        return (
+          <Level
+            key = {"orient_" + level}
+            game = {this}
+            name = {level}
+            orient = {true}
+            cards = {this.state.orient_cards[level]}
+            remaining = {this.state.orient_decks[level]}
+            strongholds = {this.state.strongholds}
+            fortify = {fortify}
+          />
+        )
+      });
+      var pending = this.isMyTurn(this.state.turn) ? this.pending() : null;
       return (
         <div>
           <div id="game-board">
             <div id="common-area">
               <div id="noble-area" className="split">
                 {nobles}
+                {cities}
+                {posts}
               </div>
               <div id="level-area" className="split">
                 {levels}
               </div>
-              <div className="reserve-info">
-                <div className="reserve-info-inner">
-                  <div>Click on card to buy, click on </div><div><img className="floppy" src="client/img/floppy.png" /></div><div> to reserve.</div>
+              {orientLevels.length > 0 &&
+                <div id="orient-area" className="split">
+                  {orientLevels}
                 </div>
+              }
+              <div className="reserve-info">
+                {pending ?
+                  <div className="reserve-info-inner prompt">{abilityPrompts[pending]}</div> :
+                  <div className="reserve-info-inner">
+                    <div>Click on card to buy, click on </div><div><img className="floppy" src="client/img/floppy.png" /></div><div> to reserve.</div>
+                  </div>
+                }
               </div>
               <div id="gem-area" className="you">
                 {gems}
@@ -636,6 +875,10 @@ let globalShowError = (resp: ServerResponse) => { return false }
     loading: boolean
     lobby: boolean
     gameName: string
+    cities: boolean
+    orient: boolean
+    outposts: boolean
+    strongholds: boolean
     joined: boolean
     pid: number
     uuid: string
@@ -649,6 +892,10 @@ let globalShowError = (resp: ServerResponse) => { return false }
       startKey: null,
       loading: true,
       lobby: false,
+      cities: false,
+      orient: false,
+      outposts: false,
+      strongholds: false,
       joined: false,
       pid: -1,
       uuid: '',
@@ -717,7 +964,13 @@ let globalShowError = (resp: ServerResponse) => { return false }
       if (this.creating) return
       this.creating = true
       const gameName = this.state.gid === '' ? this.state.gameName : this.state.gid
-      const resp = await fetch(`/create/${gameName}`, { method: "POST" })
+      const options = [
+        this.state.cities && "cities=true",
+        this.state.orient && "orient=true",
+        this.state.outposts && "outposts=true",
+        this.state.strongholds && "strongholds=true",
+      ].filter((o) => o).join("&")
+      const resp = await fetch(`/create/${gameName}${options ? "?" + options : ""}`, { method: "POST" })
       const json = await resp.json()
 
       if (this.showError(json)) return
@@ -809,6 +1062,22 @@ let globalShowError = (resp: ServerResponse) => { return false }
             <input className="game-name" type="text" onChange={this.nameChange} onKeyPress={this.keyPress} value={this.state.gameName} />
             <button onClick={this.createGame} className="create-game">Create Game</button>
           </div>
+          <label className="option">
+            <input type="checkbox" checked={this.state.cities} onChange={(e) => this.setState({ cities: e.target.checked })} />
+            Cities of Splendor: claim a city to end the game
+          </label>
+          <label className="option">
+            <input type="checkbox" checked={this.state.orient} onChange={(e) => this.setState({ orient: e.target.checked })} />
+            Orient: extra cards with special abilities
+          </label>
+          <label className="option">
+            <input type="checkbox" checked={this.state.outposts} onChange={(e) => this.setState({ outposts: e.target.checked })} />
+            Outposts (house rule, not the Trading Posts module): unlock abilities by collecting cards
+          </label>
+          <label className="option">
+            <input type="checkbox" checked={this.state.strongholds} onChange={(e) => this.setState({ strongholds: e.target.checked })} />
+            Strongholds (simplified rules): guard table cards from your opponents, three strongholds claim a card
+          </label>
           <ErrorMsg error={this.state.error} opacity={this.state.errorOpacity} />
         </div>
       }